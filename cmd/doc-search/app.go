@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cmrigney/mcp-document-search/internal/chat"
+	"github.com/cmrigney/mcp-document-search/internal/chunker"
+	"github.com/cmrigney/mcp-document-search/internal/config"
+	"github.com/cmrigney/mcp-document-search/internal/embeddings"
+	"github.com/cmrigney/mcp-document-search/internal/fetcher"
+	"github.com/cmrigney/mcp-document-search/internal/search"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// app holds the components shared by every subcommand
+type app struct {
+	cfg           *config.Config
+	db            *storage.Database
+	searchService *search.Service
+}
+
+// newApp loads configuration and wires the database, clients and search service
+func newApp(opts config.LoadOptions) (*app, error) {
+	// Load configuration
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.File != "" {
+		log.Printf("Loaded config file: %s", cfg.File)
+	}
+
+	// Ensure database directory exists
+	dbDir := filepath.Dir(cfg.DBPath)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// Initialize database
+	db, err := storage.NewDatabase(cfg.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	log.Printf("Database initialized at: %s", cfg.DBPath)
+
+	searchService, err := newSearchService(cfg, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &app{cfg: cfg, db: db, searchService: searchService}, nil
+}
+
+// newSearchService builds the embeddings client, chunker and fetcher and
+// returns the search service using them
+func newSearchService(cfg *config.Config, db *storage.Database) (*search.Service, error) {
+	// Initialize embeddings client
+	embOpts := embeddings.DefaultOptions()
+	embOpts.Endpoint = cfg.EmbeddingURL
+	embOpts.Model = cfg.EmbeddingModel
+	embOpts.Concurrency = cfg.EmbeddingConcurrency
+	embOpts.RequestsPerMinute = cfg.EmbeddingRequestsPerMinute
+	embOpts.TokensPerMinute = cfg.EmbeddingTokensPerMinute
+	embOpts.MaxRetries = cfg.EmbeddingMaxRetries
+	embOpts.PricePerMillionTokens = cfg.EmbeddingPricePerMillion
+	embClient := embeddings.NewClientWithOptions(cfg.OpenAIAPIKey, embOpts)
+	log.Printf("OpenAI embeddings client initialized (concurrency: %d, rpm: %d, tpm: %d)", embOpts.Concurrency, embOpts.RequestsPerMinute, embOpts.TokensPerMinute)
+
+	// Initialize embedding cache
+	if cfg.EmbeddingCacheSize > 0 || cfg.EmbeddingCachePersist {
+		var store embeddings.PersistentStore
+		if cfg.EmbeddingCachePersist {
+			store = db
+		}
+		embClient.SetCache(embeddings.NewCache(cfg.EmbeddingCacheSize, store))
+		log.Printf("Embedding cache initialized (size: %d, persistent: %t)", cfg.EmbeddingCacheSize, cfg.EmbeddingCachePersist)
+	}
+
+	// Initialize chunker
+	c := chunker.NewChunker(cfg.ChunkSize, cfg.Overlap)
+	log.Printf("Chunker initialized (size: %d, overlap: %d)", cfg.ChunkSize, cfg.Overlap)
+
+	// Initialize URL fetcher
+	f := fetcher.NewFetcher()
+	if err := f.SetExtractMode(cfg.HTMLExtractMode); err != nil {
+		return nil, fmt.Errorf("failed to configure fetcher: %w", err)
+	}
+	if err := f.SetMaxBodySize(int64(cfg.FetchMaxBodyBytes)); err != nil {
+		return nil, fmt.Errorf("failed to configure fetcher: %w", err)
+	}
+	log.Printf("URL fetcher initialized (extract mode: %s, max body: %d bytes)", cfg.HTMLExtractMode, cfg.FetchMaxBodyBytes)
+
+	// Restrict which hosts and addresses URLs may point at
+	err := f.SetNetworkPolicy(fetcher.NetworkPolicy{
+		AllowPrivateNetworks: cfg.AllowPrivateNetworks,
+		AllowedNetworks:      cfg.AllowedNetworks,
+		AllowedHosts:         cfg.URLAllowHosts,
+		DeniedHosts:          cfg.URLDenyHosts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure network policy: %w", err)
+	}
+	if cfg.AllowPrivateNetworks {
+		log.Println("Warning: private network access is allowed for URL fetching")
+	}
+
+	// Configure per-host credentials for authenticated URLs
+	if cfg.URLAuthFile != "" {
+		authCfg, err := fetcher.LoadAuthConfig(cfg.URLAuthFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load URL auth config: %w", err)
+		}
+		if err := f.SetAuth(authCfg); err != nil {
+			return nil, fmt.Errorf("failed to configure URL auth: %w", err)
+		}
+		log.Printf("URL auth configured for %d hosts", f.AuthHostCount())
+	}
+
+	// Initialize search service
+	searchService := search.NewService(db, embClient, c, f)
+	log.Println("Search service initialized")
+
+	// Restrict which local files file_path may read
+	err = searchService.SetFilePolicy(search.FilePolicy{
+		AllowedRoots:   cfg.FileAllowedRoots,
+		DeniedPatterns: cfg.FileDenyPatterns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure file policy: %w", err)
+	}
+	if len(cfg.FileAllowedRoots) == 0 {
+		log.Println("Warning: files.allowed_roots (FILE_ALLOWED_ROOTS) is not set, file_path may read any file not matching a denied pattern")
+	} else {
+		log.Printf("File indexing restricted to: %s", strings.Join(cfg.FileAllowedRoots, ", "))
+	}
+
+	// Backups and scheduled snapshots are written to the backup directory
+	err = searchService.SetBackupPolicy(search.BackupPolicy{
+		Dir:    cfg.BackupDir,
+		Retain: cfg.BackupRetain,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure backups: %w", err)
+	}
+
+	// Budget for search results rendered as markdown or citations
+	searchService.SetOutputMaxChars(cfg.SearchOutputMaxChars)
+
+	// Initialize chat client for query transformation
+	searchService.SetChatClient(chat.NewClient(cfg.ChatAPIKey, cfg.ChatURL, cfg.ChatModel))
+	log.Printf("Chat client initialized (model: %s)", cfg.ChatModel)
+
+	return searchService, nil
+}
+
+// Close releases the database
+func (a *app) Close() error {
+	return a.db.Close()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cmrigney/mcp-document-search/internal/search"
+)
+
+// runExport writes the index to an archive file, or stdout for "-"
+func runExport(args []string) error {
+	fs, common := newFlagSet("export", "export [flags] <file|->")
+
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("exactly one output file is required (use - for stdout)")
+	}
+	path := rest[0]
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	var resp *search.ExportResponse
+	// The summary goes to stderr when the archive itself is on stdout
+	summary := os.Stdout
+	if path == "-" {
+		summary = os.Stderr
+		resp, err = a.searchService.Export(ctx, os.Stdout)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Write next to the destination and rename, so a failed export never
+		// leaves a truncated archive behind
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".doc-search-export-*")
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+
+		resp, err = a.searchService.Export(ctx, tmp)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+
+	if common.json {
+		return printJSONTo(summary, resp)
+	}
+	fmt.Fprintf(summary, "Exported %d documents (%d chunks, %s)\n", resp.Documents, resp.Chunks, resp.EmbeddingModel)
+	return nil
+}
+
+// runImport loads an archive file, or stdin for "-", into the index
+func runImport(args []string) error {
+	fs, common := newFlagSet("import", "import [flags] <file|->")
+	mode := fs.String("mode", search.ImportModeMerge, "merge keeps existing documents unless the archive copy is newer; replace removes all existing documents first")
+
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("exactly one archive file is required (use - for stdin)")
+	}
+
+	var in io.Reader = os.Stdin
+	if rest[0] != "-" {
+		f, err := os.Open(rest[0])
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	resp, err := a.searchService.Import(ctx, in, search.ImportRequest{Mode: *mode})
+	if err != nil {
+		return err
+	}
+
+	if common.json {
+		return printJSON(resp)
+	}
+	fmt.Println(resp.Message)
+	return nil
+}
+
+// runBackup writes a snapshot to the given path, or a timestamped snapshot
+// in the backup directory when no path is given
+func runBackup(args []string) error {
+	fs, common := newFlagSet("backup", "backup [flags] [file]")
+	force := fs.Bool("force", false, "Overwrite an existing backup file")
+
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return fmt.Errorf("at most one backup file may be given")
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	var resp *search.BackupResponse
+	if len(rest) == 1 {
+		resp, err = a.searchService.BackupTo(ctx, rest[0], *force)
+	} else {
+		resp, err = a.searchService.Backup(ctx, search.BackupRequest{Overwrite: *force})
+	}
+	if err != nil {
+		return err
+	}
+
+	if common.json {
+		return printJSON(resp)
+	}
+	fmt.Println(resp.Message)
+	return nil
+}
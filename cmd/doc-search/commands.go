@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cmrigney/mcp-document-search/internal/config"
+	"github.com/cmrigney/mcp-document-search/internal/search"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// configFlags selects the config file and overrides individual keys
+type configFlags struct {
+	path      string
+	overrides stringList
+}
+
+// addConfigFlags registers --config and --set
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	cf := &configFlags{}
+	fs.StringVar(&cf.path, "config", "", "Config file (.yaml, .yml or .toml)")
+	fs.Var(&cf.overrides, "set", "Override a config key, e.g. --set chunking.size=500 (repeatable)")
+	return cf
+}
+
+// loadOptions returns the config load options given on the command line
+func (cf *configFlags) loadOptions() config.LoadOptions {
+	return config.LoadOptions{Path: cf.path, Overrides: cf.overrides}
+}
+
+// cliFlags holds the flags shared by every CLI subcommand
+type cliFlags struct {
+	json    bool
+	verbose bool
+	config  *configFlags
+}
+
+// newFlagSet creates a flag set with --json, --verbose, --config and --set
+func newFlagSet(name, usage string) (*flag.FlagSet, *cliFlags) {
+	common := &cliFlags{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&common.json, "json", false, "Print machine-readable JSON")
+	fs.BoolVar(&common.verbose, "verbose", false, "Log initialization and progress to stderr")
+	common.config = addConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: doc-search %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, common
+}
+
+// parseArgs parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments. Everything
+// after a "--" terminator is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if end := terminatorIndex(fs, args); end >= 0 {
+			if err := fs.Parse(args[:end]); err != nil {
+				return nil, err
+			}
+			return append(positional, args[end+1:]...), nil
+		}
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// terminatorIndex returns the index of a "--" ending the run of flags at
+// the start of args, or -1. A "--" given as a flag's value is not a
+// terminator.
+func terminatorIndex(fs *flag.FlagSet, args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return i
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			return -1
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			// Parse reports the unknown flag
+			return -1
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		// Skip the flag's value
+		i++
+	}
+	return -1
+}
+
+// setFlags returns the names of the flags given on the command line, so
+// unset flags fall back to the service defaults
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// openApp initializes the application, silencing startup logs unless verbose
+func openApp(common *cliFlags) (*app, error) {
+	if !common.verbose {
+		log.SetOutput(io.Discard)
+	}
+	return newApp(common.config.loadOptions())
+}
+
+// signalContext returns a context cancelled on interrupt
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// isURL reports whether an index target should be fetched rather than read
+func isURL(target string) bool {
+	lower := strings.ToLower(target)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// runIndex indexes files, URLs, a sitemap, a crawl or stdin content
+func runIndex(args []string) error {
+	fs, common := newFlagSet("index", "index [flags] <file|url>...")
+	source := fs.String("source", "", "Index content under this source name, read from --content or stdin")
+	content := fs.String("content", "", "Content to index with --source (default: read stdin)")
+	sitemap := fs.String("sitemap", "", "Index every page listed in this sitemap URL")
+	reindex := fs.Bool("reindex", false, "Re-index documents that are already indexed")
+	refresh := fs.Duration("refresh-interval", 0, "Re-check a url in the background at this interval (e.g. 24h)")
+	crawl := fs.Bool("crawl", false, "Crawl same-origin links from each url")
+	maxDepth := fs.Int("max-depth", 0, "Maximum crawl depth (default 2)")
+	maxPages := fs.Int("max-pages", 0, "Maximum pages to fetch when crawling or indexing a sitemap")
+	delay := fs.Duration("crawl-delay", 0, "Minimum delay between crawl requests (default 500ms)")
+	concurrency := fs.Int("concurrency", 0, "Sitemap pages fetched in parallel (default 4)")
+	var include, exclude stringList
+	fs.Var(&include, "include", "Only crawl URLs matching this regular expression (repeatable)")
+	fs.Var(&exclude, "exclude", "Skip crawled URLs matching this regular expression (repeatable)")
+
+	targets, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	set := setFlags(fs)
+	refreshSet := set["refresh-interval"]
+
+	modes := 0
+	for _, given := range []bool{len(targets) > 0, *source != "", *sitemap != ""} {
+		if given {
+			modes++
+		}
+	}
+	if modes != 1 {
+		return fmt.Errorf("provide exactly one of: files/urls, --sitemap, or --source")
+	}
+	if *content != "" && *source == "" {
+		return fmt.Errorf("--content requires --source")
+	}
+	if refreshSet && (*crawl || *sitemap != "") {
+		return fmt.Errorf("--refresh-interval is only supported when indexing single urls")
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	switch {
+	case *sitemap != "":
+		resp, err := a.searchService.IndexSitemap(ctx, search.SitemapRequest{
+			SitemapURL:  *sitemap,
+			MaxPages:    *maxPages,
+			Concurrency: *concurrency,
+			Reindex:     *reindex,
+		})
+		if err != nil {
+			return fmt.Errorf("indexing failed: %w", err)
+		}
+		if common.json {
+			if err := printJSON(resp); err != nil {
+				return err
+			}
+		} else {
+			printPages(resp.FailedSitemaps)
+			printPages(resp.Pages)
+			fmt.Println(resp.Message)
+		}
+		return pagesError(resp.PagesFailed + len(resp.FailedSitemaps))
+
+	case *crawl:
+		var crawls []*search.CrawlResponse
+		failed := 0
+		for _, target := range targets {
+			req := search.CrawlRequest{
+				URL:      target,
+				MaxPages: *maxPages,
+				Include:  include,
+				Exclude:  exclude,
+				Reindex:  *reindex,
+			}
+			if set["max-depth"] {
+				req.MaxDepth = maxDepth
+			}
+			if set["crawl-delay"] {
+				req.Delay = delay
+			}
+			resp, err := a.searchService.Crawl(ctx, req)
+			if err != nil {
+				return fmt.Errorf("crawling %s failed: %w", target, err)
+			}
+			crawls = append(crawls, resp)
+			failed += resp.PagesFailed
+			if !common.json {
+				printPages(resp.Pages)
+				fmt.Println(resp.Message)
+			}
+		}
+		if common.json {
+			if err := printJSON(map[string]any{"crawls": crawls}); err != nil {
+				return err
+			}
+		}
+		return pagesError(failed)
+
+	case *source != "":
+		text := *content
+		if text == "" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			text = string(data)
+		}
+		targets = []string{*source}
+		return indexTargets(ctx, a.searchService, common, targets, func(string) search.IndexRequest {
+			return search.IndexRequest{Content: text, Source: *source, Reindex: *reindex}
+		})
+
+	default:
+		return indexTargets(ctx, a.searchService, common, targets, func(target string) search.IndexRequest {
+			req := search.IndexRequest{Reindex: *reindex}
+			if isURL(target) {
+				req.URL = target
+				if refreshSet {
+					req.RefreshInterval = refresh
+				}
+			} else {
+				req.FilePath = target
+			}
+			return req
+		})
+	}
+}
+
+// indexResults is the --json output of index for files, urls and content
+type indexResults struct {
+	Results   []*search.IndexResponse `json:"results"`
+	Indexed   int                     `json:"indexed"`
+	Unchanged int                     `json:"unchanged"`
+	Skipped   int                     `json:"skipped"`
+	Failed    int                     `json:"failed"`
+}
+
+// indexTargets indexes each target in turn. Already indexed documents are
+// reported as skipped; other failures are reported and counted so one bad
+// target does not stop the rest.
+func indexTargets(ctx context.Context, svc *search.Service, common *cliFlags, targets []string, request func(string) search.IndexRequest) error {
+	var results indexResults
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		resp, err := svc.Index(ctx, request(target))
+		switch {
+		case errors.Is(err, search.ErrAlreadyIndexed):
+			resp = &search.IndexResponse{Source: target, Status: search.PageStatusSkipped, Message: err.Error()}
+		case err != nil:
+			resp = &search.IndexResponse{Source: target, Status: search.PageStatusFailed, Message: err.Error()}
+		}
+
+		switch resp.Status {
+		case search.PageStatusIndexed:
+			results.Indexed++
+		case search.PageStatusUnchanged:
+			results.Unchanged++
+		case search.PageStatusSkipped:
+			results.Skipped++
+		default:
+			results.Failed++
+		}
+		results.Results = append(results.Results, resp)
+
+		if !common.json {
+			printIndexResponse(resp)
+		}
+	}
+
+	if common.json {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else if len(targets) > 1 {
+		fmt.Printf("\n%d indexed, %d unchanged, %d skipped, %d failed\n", results.Indexed, results.Unchanged, results.Skipped, results.Failed)
+	}
+	return pagesError(results.Failed)
+}
+
+// pagesError returns an error when any page or target failed, so scripts
+// can rely on the exit status
+func pagesError(failed int) error {
+	if failed > 0 {
+		return fmt.Errorf("%d failed", failed)
+	}
+	return nil
+}
+
+// runSearch runs a semantic search
+func runSearch(args []string) error {
+	fs, common := newFlagSet("search", "search [flags] <query>")
+	topK := fs.Int("top-k", 5, "Number of results to return")
+	minScore := fs.Float64("min-score", 0.3, "Minimum similarity score 0-1")
+	sourceFilter := fs.String("source", "", "Only return results from this source")
+	queryMode := fs.String("query-mode", "", "Query transformation: hyde or multi")
+	numQueries := fs.Int("num-queries", 0, "Number of rephrasings for multi mode (default 3)")
+	format := fs.String("format", "", "Print results as markdown or citations, within search.output_max_chars")
+	maxChars := fs.Int("max-chars", 0, "Fit result content into this many characters, merging adjacent chunks")
+	maxTokens := fs.Int("max-tokens", 0, "Fit result content into about this many tokens")
+
+	words, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(words, " "))
+	if query == "" {
+		return fmt.Errorf("query is required")
+	}
+	if *format != "" && *format != search.FormatMarkdown && *format != search.FormatCitations {
+		return fmt.Errorf("invalid format %q (use %s or %s)", *format, search.FormatMarkdown, search.FormatCitations)
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	resp, err := a.searchService.Search(ctx, search.SearchRequest{
+		Query:        query,
+		TopK:         *topK,
+		MinScore:     *minScore,
+		SourceFilter: *sourceFilter,
+		QueryMode:    *queryMode,
+		NumQueries:   *numQueries,
+		MaxChars:     *maxChars,
+		MaxTokens:    *maxTokens,
+	})
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	if common.json {
+		return printJSON(resp)
+	}
+	if *format != "" {
+		text, _, err := a.searchService.RenderResults(query, resp.Results, *format)
+		if err != nil {
+			return err
+		}
+		fmt.Print(text)
+		return nil
+	}
+	printSearchResponse(resp)
+	return nil
+}
+
+// runList lists indexed documents
+func runList(args []string) error {
+	fs, common := newFlagSet("list", "list [flags]")
+	sourceType := fs.String("type", "", "Filter by source type: file, url or content")
+
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	resp, err := a.searchService.List(context.Background(), search.ListRequest{SourceType: *sourceType})
+	if err != nil {
+		return fmt.Errorf("list failed: %w", err)
+	}
+
+	if common.json {
+		return printJSON(resp)
+	}
+	printDocuments(resp)
+	return nil
+}
+
+// runDelete deletes indexed documents by source
+func runDelete(args []string) error {
+	fs, common := newFlagSet("delete", "delete [flags] <source>...")
+
+	sources, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("at least one source is required")
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var results []*search.DeleteResponse
+	failed := 0
+	for _, source := range sources {
+		resp, err := a.searchService.Delete(context.Background(), search.DeleteRequest{Source: source})
+		if err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		if !resp.Deleted {
+			failed++
+		}
+		results = append(results, resp)
+		if !common.json {
+			fmt.Println(resp.Message)
+		}
+	}
+
+	if common.json {
+		if err := printJSON(map[string]any{"results": results}); err != nil {
+			return err
+		}
+	}
+	return pagesError(failed)
+}
+
+// runStats prints index, spend and cache statistics
+func runStats(args []string) error {
+	fs, common := newFlagSet("stats", "stats [flags]")
+
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+
+	a, err := openApp(common)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	resp, err := a.searchService.Stats(context.Background(), search.StatsRequest{})
+	if err != nil {
+		return fmt.Errorf("stats failed: %w", err)
+	}
+
+	if common.json {
+		return printJSON(resp)
+	}
+	printStats(resp)
+	return nil
+}
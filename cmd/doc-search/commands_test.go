@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs, common := newFlagSet("search", "search [flags] <query>")
+	topK := fs.Int("top-k", 5, "")
+
+	words, err := parseArgs(fs, []string{"how", "--top-k", "3", "do", "I", "--json", "--", "--deploy"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if got := strings.Join(words, " "); got != "how do I --deploy" {
+		t.Errorf("Expected positional arguments %q, got %q", "how do I --deploy", got)
+	}
+	if *topK != 3 || !common.json {
+		t.Errorf("Expected top-k=3 and json=true, got top-k=%d json=%v", *topK, common.json)
+	}
+	if set := setFlags(fs); !set["top-k"] || set["verbose"] {
+		t.Errorf("Unexpected set flags: %v", set)
+	}
+
+	// Flag-like words after "--" stay positional
+	fs, common = newFlagSet("search", "search [flags] <query>")
+	words, err = parseArgs(fs, []string{"--", "what", "is", "--json"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if got := strings.Join(words, " "); got != "what is --json" || common.json {
+		t.Errorf("Expected %q with json unset, got %q json=%v", "what is --json", got, common.json)
+	}
+
+	// A flag whose value is "--" does not end the flags
+	fs, common = newFlagSet("search", "search [flags] <query>")
+	source := fs.String("source", "", "")
+	words, err = parseArgs(fs, []string{"--source", "--", "deploy", "--json", "steps"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if got := strings.Join(words, " "); got != "deploy steps" || *source != "--" || !common.json {
+		t.Errorf("Expected %q with source=-- and json set, got %q source=%q json=%v", "deploy steps", got, *source, common.json)
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected bool
+	}{
+		{"https://example.com/docs", true},
+		{"HTTP://example.com", true},
+		{"docs/guide.md", false},
+		{"/abs/path/http.md", false},
+		{"ftp://example.com/file", false},
+	}
+
+	for _, tt := range tests {
+		if got := isURL(tt.target); got != tt.expected {
+			t.Errorf("isURL(%q) = %v, expected %v", tt.target, got, tt.expected)
+		}
+	}
+}
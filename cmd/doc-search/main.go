@@ -2,66 +2,107 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
-	"github.com/cmrigney/mcp-document-search/internal/chunker"
-	"github.com/cmrigney/mcp-document-search/internal/config"
-	"github.com/cmrigney/mcp-document-search/internal/embeddings"
-	"github.com/cmrigney/mcp-document-search/internal/fetcher"
-	"github.com/cmrigney/mcp-document-search/internal/search"
-	"github.com/cmrigney/mcp-document-search/internal/storage"
 	"github.com/cmrigney/mcp-document-search/pkg/server"
 )
 
+// command is a doc-search subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "Run the MCP server on stdio (default)", runServe},
+	{"index", "Index files, URLs, sitemaps or stdin content", runIndex},
+	{"search", "Search indexed documents", runSearch},
+	{"list", "List indexed documents", runList},
+	{"delete", "Delete indexed documents", runDelete},
+	{"stats", "Show index size, embedding spend and cache statistics", runStats},
+	{"export", "Write the index to a portable archive", runExport},
+	{"import", "Load documents from an archive written by export", runImport},
+	{"backup", "Write a consistent snapshot of the database", runBackup},
+}
+
 func main() {
 	// Enable sqlite-vec for all future database connections
 	sqlite_vec.Auto()
-	log.Println("sqlite-vec enabled (statically linked)")
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	// Without a subcommand, start the MCP server as before
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Ensure database directory exists
-	dbDir := filepath.Dir(cfg.DBPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		log.Fatalf("Failed to create database directory: %v", err)
+	if name == "help" {
+		usage()
+		return
 	}
 
-	// Initialize database
-	db, err := storage.NewDatabase(cfg.DBPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "doc-search %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer db.Close()
-
-	log.Printf("Database initialized at: %s", cfg.DBPath)
 
-	// Initialize embeddings client
-	embClient := embeddings.NewClient(cfg.OpenAIAPIKey)
-	log.Println("OpenAI embeddings client initialized")
+	fmt.Fprintf(os.Stderr, "doc-search: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	// Initialize chunker
-	c := chunker.NewChunker(cfg.ChunkSize, cfg.Overlap)
-	log.Printf("Chunker initialized (size: %d, overlap: %d)", cfg.ChunkSize, cfg.Overlap)
+// usage prints the list of subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: doc-search <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'doc-search <command> -h' for the flags of a command.")
+	fmt.Fprintln(os.Stderr, "Configuration is read from a config file, the environment and --set, see README.md.")
+}
 
-	// Initialize URL fetcher
-	f := fetcher.NewFetcher()
-	log.Println("URL fetcher initialized")
+// runServe starts the MCP stdio server
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlags := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
 
-	// Initialize search service
-	searchService := search.NewService(db, embClient, c, f)
-	log.Println("Search service initialized")
+	log.Println("sqlite-vec enabled (statically linked)")
+	a, err := newApp(configFlags.loadOptions())
+	if err != nil {
+		return err
+	}
+	defer a.Close()
 
 	// Create and start MCP server
-	mcpServer := server.NewServer(searchService)
+	mcpServer := server.NewServer(a.searchService)
 	defer mcpServer.Close()
 
 	log.Println("Starting MCP server on stdio...")
@@ -79,16 +120,58 @@ func main() {
 		errChan <- mcpServer.Run(ctx)
 	}()
 
+	// Background schedulers write to the database, so shutdown waits for
+	// them before it closes
+	var schedulers sync.WaitGroup
+
+	// Refresh url documents in the background
+	if a.cfg.RefreshCheckInterval > 0 {
+		schedulers.Add(1)
+		go func() {
+			defer schedulers.Done()
+			a.searchService.RunRefreshScheduler(ctx, a.cfg.RefreshCheckInterval)
+		}()
+		log.Printf("Refresh scheduler started (check interval: %s)", a.cfg.RefreshCheckInterval)
+	}
+
+	// Snapshot the database in the background
+	if a.cfg.BackupInterval > 0 {
+		schedulers.Add(1)
+		go func() {
+			defer schedulers.Done()
+			a.searchService.RunBackupScheduler(ctx, a.cfg.BackupInterval)
+		}()
+		log.Printf("Backup scheduler started (interval: %s, retain: %d, dir: %s)", a.cfg.BackupInterval, a.cfg.BackupRetain, a.cfg.BackupDir)
+	}
+
+	// Run background index jobs, resuming any left unfinished
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		a.searchService.RunJobs(ctx)
+	}()
+	log.Println("Background job runner started")
+
 	// Wait for shutdown signal or error
+	var runErr error
 	select {
 	case err := <-errChan:
 		if err != nil {
-			log.Fatalf("Server error: %v", err)
+			runErr = fmt.Errorf("server error: %w", err)
 		}
 	case sig := <-sigChan:
 		log.Printf("Received signal %v, shutting down...", sig)
-		cancel()
+	}
+
+	// Let running jobs record that they were interrupted, and let an
+	// in-progress refresh or snapshot finish, before the database closes
+	cancel()
+	<-jobsDone
+	schedulers.Wait()
+	if runErr != nil {
+		return runErr
 	}
 
 	log.Println("Server shutdown complete")
+	return nil
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cmrigney/mcp-document-search/internal/search"
+)
+
+// maxSpendSources caps the per-source spend rows printed by stats
+const maxSpendSources = 10
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	return printJSONTo(os.Stdout, v)
+}
+
+// printJSONTo writes v to w as indented JSON
+func printJSONTo(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to format results: %w", err)
+	}
+	return nil
+}
+
+// printIndexResponse prints one line per indexed target
+func printIndexResponse(resp *search.IndexResponse) {
+	switch resp.Status {
+	case search.PageStatusIndexed:
+		fmt.Printf("%-9s %s (%d chunks, %d tokens, $%.4f)\n", resp.Status, resp.Source, resp.ChunkCount, resp.EmbeddingTokens, resp.EstimatedCost)
+	case search.PageStatusUnchanged:
+		fmt.Printf("%-9s %s\n", resp.Status, resp.Source)
+	default:
+		fmt.Printf("%-9s %s: %s\n", resp.Status, resp.Source, resp.Message)
+	}
+}
+
+// printPages prints the per-page outcome of a crawl or sitemap
+func printPages(pages []search.PageResult) {
+	for _, page := range pages {
+		switch {
+		case page.Error != "":
+			fmt.Printf("%-9s %s: %s\n", page.Status, page.URL, page.Error)
+		case page.ChunkCount > 0:
+			fmt.Printf("%-9s %s (%d chunks)\n", page.Status, page.URL, page.ChunkCount)
+		default:
+			fmt.Printf("%-9s %s\n", page.Status, page.URL)
+		}
+	}
+}
+
+// printSearchResponse prints ranked results with their full chunk text
+func printSearchResponse(resp *search.SearchResponse) {
+	if len(resp.ExpandedQueries) > 0 {
+		fmt.Println("Queries:")
+		for _, q := range resp.ExpandedQueries {
+			fmt.Printf("  - %s\n", q)
+		}
+		fmt.Println()
+	}
+
+	for i, result := range resp.Results {
+		chunks := fmt.Sprintf("#%d", result.ChunkIndex)
+		if result.LastChunkIndex > result.ChunkIndex {
+			chunks = fmt.Sprintf("#%d-%d", result.ChunkIndex, result.LastChunkIndex)
+		}
+		fmt.Printf("%d. %s %s (score %.4f)\n", i+1, result.Source, chunks, result.Score)
+		for _, line := range strings.Split(strings.TrimSpace(result.Content), "\n") {
+			fmt.Printf("   %s\n", line)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("%d results", resp.Count)
+	if resp.Omitted > 0 {
+		fmt.Printf(", %d omitted to fit the budget", resp.Omitted)
+	}
+	if resp.Usage != nil {
+		fmt.Printf(" (%d query tokens)", resp.Usage.Tokens)
+	}
+	fmt.Println()
+}
+
+// printDocuments prints indexed documents as a table
+func printDocuments(resp *search.ListResponse) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tTYPE\tCHUNKS\tSIZE\tINDEXED\tREFRESH\tTITLE")
+	for _, doc := range resp.Documents {
+		refresh := "-"
+		if doc.RefreshInterval != "" {
+			refresh = doc.RefreshInterval
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", doc.Source, doc.SourceType, doc.ChunkCount, doc.ContentSize, doc.IndexedAt, refresh, doc.Title)
+	}
+	w.Flush()
+	fmt.Printf("\n%d documents\n", resp.Count)
+}
+
+// printStats prints index size, spend and cache statistics
+func printStats(resp *search.StatsResponse) {
+	fmt.Printf("Documents:   %d\n", resp.DocumentCount)
+	fmt.Printf("Chunks:      %d\n", resp.ChunkCount)
+	fmt.Printf("Tokens:      %d\n", resp.TotalTokens)
+	fmt.Printf("Est. cost:   $%.4f\n", resp.TotalCost)
+
+	if len(resp.Operations) > 0 {
+		fmt.Println("\nSpend by operation:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, op := range resp.Operations {
+			fmt.Fprintf(w, "  %s\t%d tokens\t$%.4f\n", op.Operation, op.Tokens, op.Cost)
+		}
+		w.Flush()
+	}
+
+	if len(resp.SpendBySource) > 0 {
+		fmt.Println("\nTop sources by indexing spend:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, src := range resp.SpendBySource {
+			if i == maxSpendSources {
+				fmt.Fprintf(w, "  ... %d more\t\t\n", len(resp.SpendBySource)-maxSpendSources)
+				break
+			}
+			fmt.Fprintf(w, "  %s\t%d tokens\t$%.4f\n", src.Source, src.Tokens, src.Cost)
+		}
+		w.Flush()
+	}
+
+	if cache := resp.EmbeddingCache; cache != nil {
+		fmt.Println("\nEmbedding cache:")
+		fmt.Printf("  entries %d/%d, hits %d (persistent %d), misses %d, deduplicated %d\n",
+			cache.Entries, cache.Capacity, cache.Hits, cache.PersistentHits, cache.Misses, cache.Deduplicated)
+	}
+}
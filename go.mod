@@ -3,14 +3,17 @@ module github.com/cmrigney/mcp-document-search
 go 1.25.3
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/asg017/sqlite-vec-go-bindings v0.1.6
+	github.com/google/jsonschema-go v0.3.0
 	github.com/mattn/go-sqlite3 v1.14.18
 	github.com/modelcontextprotocol/go-sdk v1.2.0
 	golang.org/x/net v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/google/jsonschema-go v0.3.0 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
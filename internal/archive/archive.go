@@ -0,0 +1,422 @@
+// Package archive reads and writes portable index archives.
+//
+// An archive is a gzip-compressed tar file with three entries, in order:
+//
+//	manifest.json    format version, embedding model and counts
+//	vectors.bin      little-endian float32 embeddings, one after another
+//	documents.jsonl  one document per line with its metadata and chunks;
+//	                 each chunk refers to its vector by position
+//
+// Vectors come before documents so a reader can spool them to a temporary
+// file and then stream documents without holding the index in memory.
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+const (
+	// FormatName identifies doc-search archives
+	FormatName = "doc-search-archive"
+	// FormatVersion is the archive layout written by this version
+	FormatVersion = 1
+
+	manifestName  = "manifest.json"
+	vectorsName   = "vectors.bin"
+	documentsName = "documents.jsonl"
+)
+
+// Manifest describes an archive
+type Manifest struct {
+	Format             string    `json:"format"`
+	Version            int       `json:"version"`
+	CreatedAt          time.Time `json:"created_at"`
+	EmbeddingModel     string    `json:"embedding_model"`
+	EmbeddingDimension int       `json:"embedding_dimension"`
+	Documents          int       `json:"documents"`
+	Chunks             int       `json:"chunks"`
+}
+
+// documentRecord is one line of documents.jsonl
+type documentRecord struct {
+	Source      string    `json:"source"`
+	SourceType  string    `json:"source_type"`
+	Title       string    `json:"title,omitempty"`
+	IndexedAt   time.Time `json:"indexed_at"`
+	ContentSize int       `json:"content_size"`
+
+	EmbeddingTokens int     `json:"embedding_tokens"`
+	EmbeddingCost   float64 `json:"embedding_cost"`
+
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	RefreshIntervalSeconds int64      `json:"refresh_interval_seconds,omitempty"`
+	LastCheckedAt          *time.Time `json:"last_checked_at,omitempty"`
+	LastCheckStatus        string     `json:"last_check_status,omitempty"`
+	LastCheckError         string     `json:"last_check_error,omitempty"`
+
+	Chunks []chunkRecord `json:"chunks"`
+}
+
+// chunkRecord is a chunk within a documentRecord
+type chunkRecord struct {
+	Index       int    `json:"index"`
+	Content     string `json:"content"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Vector      int    `json:"vector"`
+}
+
+// Writer writes an archive. Documents and vectors are spooled to temporary
+// files and assembled into the tar on Close, since tar headers need sizes.
+type Writer struct {
+	out      io.Writer
+	manifest Manifest
+
+	docsFile    *os.File
+	docs        *bufio.Writer
+	vectorsFile *os.File
+	vectors     *bufio.Writer
+}
+
+// NewWriter starts an archive for embeddings from model
+func NewWriter(out io.Writer, model string, dimension int) (*Writer, error) {
+	docsFile, err := os.CreateTemp("", "doc-search-documents-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	vectorsFile, err := os.CreateTemp("", "doc-search-vectors-*.bin")
+	if err != nil {
+		removeTemp(docsFile)
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	return &Writer{
+		out: out,
+		manifest: Manifest{
+			Format:             FormatName,
+			Version:            FormatVersion,
+			CreatedAt:          time.Now().UTC(),
+			EmbeddingModel:     model,
+			EmbeddingDimension: dimension,
+		},
+		docsFile:    docsFile,
+		docs:        bufio.NewWriter(docsFile),
+		vectorsFile: vectorsFile,
+		vectors:     bufio.NewWriter(vectorsFile),
+	}, nil
+}
+
+// Add appends a document and its chunks
+func (w *Writer) Add(doc storage.Document, chunks []storage.Chunk) error {
+	record := documentRecord{
+		Source:      doc.Source,
+		SourceType:  doc.SourceType,
+		Title:       doc.Title,
+		IndexedAt:   doc.IndexedAt.UTC(),
+		ContentSize: doc.ContentSize,
+
+		EmbeddingTokens: doc.EmbeddingTokens,
+		EmbeddingCost:   doc.EmbeddingCost,
+
+		ETag:         doc.ETag,
+		LastModified: doc.LastModified,
+
+		RefreshIntervalSeconds: int64(doc.RefreshInterval / time.Second),
+		LastCheckStatus:        doc.LastCheckStatus,
+		LastCheckError:         doc.LastCheckError,
+
+		Chunks: make([]chunkRecord, len(chunks)),
+	}
+	if !doc.LastCheckedAt.IsZero() {
+		checked := doc.LastCheckedAt.UTC()
+		record.LastCheckedAt = &checked
+	}
+
+	for i, chunk := range chunks {
+		if len(chunk.Embedding) != w.manifest.EmbeddingDimension {
+			return fmt.Errorf("chunk %d of %s has %d dimensions, expected %d", chunk.ChunkIndex, doc.Source, len(chunk.Embedding), w.manifest.EmbeddingDimension)
+		}
+		if err := binary.Write(w.vectors, binary.LittleEndian, chunk.Embedding); err != nil {
+			return fmt.Errorf("failed to write vector: %w", err)
+		}
+		record.Chunks[i] = chunkRecord{
+			Index:       chunk.ChunkIndex,
+			Content:     chunk.Content,
+			StartOffset: chunk.StartOffset,
+			EndOffset:   chunk.EndOffset,
+			Vector:      w.manifest.Chunks,
+		}
+		w.manifest.Chunks++
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode document: %w", err)
+	}
+	if _, err := w.docs.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+	w.manifest.Documents++
+	return nil
+}
+
+// Close writes the compressed archive and removes the temporary files
+func (w *Writer) Close() (*Manifest, error) {
+	defer removeTemp(w.docsFile)
+	defer removeTemp(w.vectorsFile)
+
+	if err := w.docs.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write documents: %w", err)
+	}
+	if err := w.vectors.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write vectors: %w", err)
+	}
+
+	manifestJSON, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w.out)
+	tw := tar.NewWriter(gz)
+
+	if err := writeEntry(tw, manifestName, int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		return nil, err
+	}
+	if err := writeFileEntry(tw, vectorsName, w.vectorsFile); err != nil {
+		return nil, err
+	}
+	if err := writeFileEntry(tw, documentsName, w.docsFile); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	manifest := w.manifest
+	return &manifest, nil
+}
+
+// Reader reads an archive written by Writer
+type Reader struct {
+	manifest    Manifest
+	tr          *tar.Reader
+	gz          *gzip.Reader
+	vectorsFile *os.File
+	docs        *json.Decoder
+	read        int
+}
+
+// NewReader reads the manifest and spools the vectors of an archive
+func NewReader(in io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("not a doc-search archive: %w", err)
+	}
+	r := &Reader{gz: gz, tr: tar.NewReader(gz)}
+
+	// Manifest
+	if err := r.expect(manifestName); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := json.NewDecoder(io.LimitReader(r.tr, 1<<20)).Decode(&r.manifest); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if r.manifest.Format != FormatName {
+		r.Close()
+		return nil, fmt.Errorf("not a doc-search archive (format %q)", r.manifest.Format)
+	}
+	if r.manifest.Version < 1 || r.manifest.Version > FormatVersion {
+		r.Close()
+		return nil, fmt.Errorf("unsupported archive version %d (this build reads up to version %d)", r.manifest.Version, FormatVersion)
+	}
+	if r.manifest.EmbeddingDimension <= 0 {
+		r.Close()
+		return nil, fmt.Errorf("invalid embedding dimension %d in manifest", r.manifest.EmbeddingDimension)
+	}
+
+	// Vectors
+	if err := r.expect(vectorsName); err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.vectorsFile, err = os.CreateTemp("", "doc-search-vectors-*.bin")
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	n, err := io.Copy(r.vectorsFile, r.tr)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to read vectors: %w", err)
+	}
+	if expected := int64(r.manifest.Chunks) * int64(r.manifest.EmbeddingDimension) * 4; n != expected {
+		r.Close()
+		return nil, fmt.Errorf("vectors.bin has %d bytes, expected %d for %d chunks", n, expected, r.manifest.Chunks)
+	}
+
+	// Documents are streamed by Next
+	if err := r.expect(documentsName); err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.docs = json.NewDecoder(bufio.NewReader(r.tr))
+
+	return r, nil
+}
+
+// expect advances to the next tar entry and checks its name
+func (r *Reader) expect(name string) error {
+	hdr, err := r.tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from archive: %w", name, err)
+	}
+	if hdr.Name != name {
+		return fmt.Errorf("unexpected archive entry %q, expected %q", hdr.Name, name)
+	}
+	return nil
+}
+
+// Manifest returns the archive manifest
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Next returns the next document and its chunks, or io.EOF after the last one
+func (r *Reader) Next() (storage.Document, []storage.Chunk, error) {
+	var record documentRecord
+	if err := r.docs.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			if r.read != r.manifest.Documents {
+				return storage.Document{}, nil, fmt.Errorf("archive has %d documents, manifest lists %d", r.read, r.manifest.Documents)
+			}
+			return storage.Document{}, nil, io.EOF
+		}
+		return storage.Document{}, nil, fmt.Errorf("failed to read document %d: %w", r.read+1, err)
+	}
+	r.read++
+
+	if record.Source == "" || record.SourceType == "" {
+		return storage.Document{}, nil, fmt.Errorf("document %d has no source or source type", r.read)
+	}
+
+	doc := storage.Document{
+		Source:      record.Source,
+		SourceType:  record.SourceType,
+		Title:       record.Title,
+		IndexedAt:   record.IndexedAt,
+		ContentSize: record.ContentSize,
+		ChunkCount:  len(record.Chunks),
+
+		EmbeddingTokens: record.EmbeddingTokens,
+		EmbeddingCost:   record.EmbeddingCost,
+
+		ETag:         record.ETag,
+		LastModified: record.LastModified,
+
+		RefreshInterval: time.Duration(record.RefreshIntervalSeconds) * time.Second,
+		LastCheckStatus: record.LastCheckStatus,
+		LastCheckError:  record.LastCheckError,
+	}
+	if record.LastCheckedAt != nil {
+		doc.LastCheckedAt = *record.LastCheckedAt
+	}
+
+	chunks := make([]storage.Chunk, len(record.Chunks))
+	for i, c := range record.Chunks {
+		embedding, err := r.vector(c.Vector)
+		if err != nil {
+			return storage.Document{}, nil, fmt.Errorf("chunk %d of %s: %w", c.Index, record.Source, err)
+		}
+		chunks[i] = storage.Chunk{
+			ChunkIndex:  c.Index,
+			Content:     c.Content,
+			StartOffset: c.StartOffset,
+			EndOffset:   c.EndOffset,
+			Embedding:   embedding,
+		}
+	}
+	return doc, chunks, nil
+}
+
+// vector reads the embedding at position i of vectors.bin
+func (r *Reader) vector(i int) ([]float32, error) {
+	if i < 0 || i >= r.manifest.Chunks {
+		return nil, fmt.Errorf("vector %d out of range", i)
+	}
+	dim := r.manifest.EmbeddingDimension
+	buf := make([]byte, dim*4)
+	if _, err := r.vectorsFile.ReadAt(buf, int64(i)*int64(dim)*4); err != nil {
+		return nil, fmt.Errorf("failed to read vector %d: %w", i, err)
+	}
+	embedding := make([]float32, dim)
+	for j := range embedding {
+		embedding[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[j*4:]))
+	}
+	return embedding, nil
+}
+
+// Close releases the reader's temporary file
+func (r *Reader) Close() error {
+	if r.vectorsFile != nil {
+		removeTemp(r.vectorsFile)
+		r.vectorsFile = nil
+	}
+	return r.gz.Close()
+}
+
+// writeFileEntry adds a spooled temporary file to the tar
+func writeFileEntry(tw *tar.Writer, name string, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind %s: %w", name, err)
+	}
+	return writeEntry(tw, name, info.Size(), f)
+}
+
+// writeEntry adds a regular file entry to the tar
+func writeEntry(tw *tar.Writer, name string, size int64, content io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now().UTC(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	if _, err := io.Copy(tw, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeTemp closes and deletes a temporary file
+func removeTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+const testDimension = 4
+
+func testChunks(n int, seed float32) []storage.Chunk {
+	chunks := make([]storage.Chunk, n)
+	for i := range chunks {
+		embedding := make([]float32, testDimension)
+		for j := range embedding {
+			embedding[j] = seed + float32(i) + float32(j)/10
+		}
+		chunks[i] = storage.Chunk{ChunkIndex: i, Content: "chunk", StartOffset: i * 5, EndOffset: i*5 + 5, Embedding: embedding}
+	}
+	return chunks
+}
+
+func TestRoundTrip(t *testing.T) {
+	indexedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	docs := []storage.Document{
+		{
+			Source: "https://example.com/a", SourceType: "url", Title: "A", IndexedAt: indexedAt, ContentSize: 10,
+			EmbeddingTokens: 42, EmbeddingCost: 0.001, ETag: `"abc"`, LastModified: "Sat, 01 Mar 2025 11:00:00 GMT",
+			RefreshInterval: 24 * time.Hour, LastCheckedAt: indexedAt.Add(time.Hour), LastCheckStatus: "unchanged",
+		},
+		{Source: "/docs/b.md", SourceType: "file", IndexedAt: indexedAt},
+	}
+	chunks := [][]storage.Chunk{testChunks(2, 1), testChunks(3, 10)}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "test-model", testDimension)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for i := range docs {
+		if err := w.Add(docs[i], chunks[i]); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	manifest, err := w.Close()
+	if err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if manifest.Documents != 2 || manifest.Chunks != 5 {
+		t.Errorf("Unexpected manifest counts: %+v", manifest)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer r.Close()
+
+	if m := r.Manifest(); m.EmbeddingModel != "test-model" || m.EmbeddingDimension != testDimension || m.Version != FormatVersion {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+
+	for i := range docs {
+		doc, got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		want := docs[i]
+		want.ChunkCount = len(chunks[i])
+		if doc != want {
+			t.Errorf("Document %d mismatch:\n got %+v\nwant %+v", i, doc, want)
+		}
+		if len(got) != len(chunks[i]) {
+			t.Fatalf("Document %d: expected %d chunks, got %d", i, len(chunks[i]), len(got))
+		}
+		for j := range got {
+			if got[j].ChunkIndex != chunks[i][j].ChunkIndex || got[j].EndOffset != chunks[i][j].EndOffset {
+				t.Errorf("Chunk %d/%d mismatch: %+v", i, j, got[j])
+			}
+			for k := range got[j].Embedding {
+				if got[j].Embedding[k] != chunks[i][j].Embedding[k] {
+					t.Fatalf("Embedding %d/%d differs at %d", i, j, k)
+				}
+			}
+		}
+	}
+	if _, _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF after last document, got %v", err)
+	}
+}
+
+func TestWriterRejectsWrongDimension(t *testing.T) {
+	w, err := NewWriter(io.Discard, "test-model", testDimension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	chunk := storage.Chunk{Embedding: []float32{1, 2}}
+	if err := w.Add(storage.Document{Source: "a", SourceType: "file"}, []storage.Chunk{chunk}); err == nil {
+		t.Error("Expected error for wrong embedding dimension")
+	}
+}
+
+// buildArchive assembles an archive from raw entries
+func buildArchive(t *testing.T, manifest Manifest, vectors []byte, documents string) *bytes.Buffer {
+	t.Helper()
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	entries := []struct {
+		name string
+		data []byte
+	}{
+		{manifestName, manifestJSON},
+		{vectorsName, vectors},
+		{documentsName, []byte(documents)},
+	}
+	for _, e := range entries {
+		if err := writeEntry(tw, e.name, int64(len(e.data)), bytes.NewReader(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestReaderRejectsInvalidArchives(t *testing.T) {
+	valid := Manifest{Format: FormatName, Version: FormatVersion, EmbeddingModel: "m", EmbeddingDimension: 1, Documents: 1, Chunks: 1}
+	vector := make([]byte, 4)
+	doc := `{"source":"a","source_type":"file","chunks":[{"index":0,"content":"x","vector":0}]}` + "\n"
+
+	tests := []struct {
+		name      string
+		manifest  func(m *Manifest)
+		vectors   []byte
+		documents string
+		expected  string
+	}{
+		{name: "wrong format", manifest: func(m *Manifest) { m.Format = "zip" }, expected: "not a doc-search archive"},
+		{name: "newer version", manifest: func(m *Manifest) { m.Version = FormatVersion + 1 }, expected: "unsupported archive version"},
+		{name: "truncated vectors", vectors: []byte{0, 0}, expected: "vectors.bin has 2 bytes"},
+		{
+			name:      "vector out of range",
+			documents: `{"source":"a","source_type":"file","chunks":[{"index":0,"content":"x","vector":3}]}` + "\n",
+			expected:  "vector 3 out of range",
+		},
+		{name: "missing documents", documents: "\n", expected: "manifest lists 1"},
+		{name: "missing source", documents: `{"source_type":"file","chunks":[]}` + "\n", expected: "no source"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := valid
+			if tt.manifest != nil {
+				tt.manifest(&manifest)
+			}
+			vectors, documents := vector, doc
+			if tt.vectors != nil {
+				vectors = tt.vectors
+			}
+			if tt.documents != "" {
+				documents = tt.documents
+			}
+
+			r, err := NewReader(buildArchive(t, manifest, vectors, documents))
+			if err == nil {
+				defer r.Close()
+				for err == nil {
+					_, _, err = r.Next()
+				}
+			}
+			if err == nil || errors.Is(err, io.EOF) || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestReaderRejectsNonArchive(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("plain text")); err == nil {
+		t.Error("Expected error for non-gzip input")
+	}
+}
@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChatURL   = "https://api.openai.com/v1/chat/completions"
+	defaultChatModel = "gpt-4o-mini"
+)
+
+// Client handles calls to an OpenAI-compatible chat completions API
+type Client struct {
+	apiKey     string
+	endpoint   string
+	model      string
+	httpClient *http.Client
+}
+
+// NewClient creates a new chat client. Empty endpoint and model fall back to
+// the OpenAI chat completions URL and gpt-4o-mini.
+func NewClient(apiKey, endpoint, model string) *Client {
+	if endpoint == "" {
+		endpoint = defaultChatURL
+	}
+	if model == "" {
+		model = defaultChatModel
+	}
+	return &Client{
+		apiKey:   apiKey,
+		endpoint: endpoint,
+		model:    model,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// Message represents a single chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// completionRequest represents the chat completions API request
+type completionRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+}
+
+// completionResponse represents the chat completions API response
+type completionResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+// apiError represents an error from the chat API
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// Complete sends a system and user prompt and returns the model's reply
+func (c *Client) Complete(ctx context.Context, systemPrompt, userPrompt string, temperature float64) (string, error) {
+	reqBody := completionRequest{
+		Model: c.model,
+		Messages: []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		Temperature: temperature,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check the status first: error pages from proxies are often not JSON
+	if resp.StatusCode != http.StatusOK {
+		var errResp completionResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+			return "", fmt.Errorf("chat API error: %s (type: %s, status: %s)", errResp.Error.Message, errResp.Error.Type, resp.Status)
+		}
+		return "", fmt.Errorf("HTTP error: %d %s, body: %s", resp.StatusCode, resp.Status, string(body))
+	}
+
+	var compResp completionResponse
+	if err := json.Unmarshal(body, &compResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if compResp.Error != nil {
+		return "", fmt.Errorf("chat API error: %s (type: %s)", compResp.Error.Message, compResp.Error.Type)
+	}
+
+	if len(compResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned")
+	}
+
+	return strings.TrimSpace(compResp.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	client := NewClient("test-api-key", "", "")
+	if client.endpoint != defaultChatURL {
+		t.Errorf("Expected endpoint %q, got %q", defaultChatURL, client.endpoint)
+	}
+	if client.model != defaultChatModel {
+		t.Errorf("Expected model %q, got %q", defaultChatModel, client.model)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-api-key" {
+			t.Errorf("Expected bearer auth header, got %q", got)
+		}
+
+		var req completionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Model != "test-model" {
+			t.Errorf("Expected model 'test-model', got %q", req.Model)
+		}
+		if len(req.Messages) != 2 || req.Messages[1].Content != "auth timeout" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  drafted answer \n"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", server.URL, "test-model")
+	reply, err := client.Complete(context.Background(), "system", "auth timeout", 0)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if reply != "drafted answer" {
+		t.Errorf("Expected trimmed reply 'drafted answer', got %q", reply)
+	}
+}
+
+func TestCompleteAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad model","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", server.URL, "test-model")
+	_, err := client.Complete(context.Background(), "system", "query", 0)
+	if err == nil || !strings.Contains(err.Error(), "bad model") || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected the API error message and status, got %v", err)
+	}
+}
+
+func TestCompleteNonJSONErrorPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html><body>upstream unavailable</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key", server.URL, "test-model")
+	_, err := client.Complete(context.Background(), "system", "query", 0)
+	if err == nil || !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), "upstream unavailable") {
+		t.Errorf("Expected the status and body of the error page, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "failed to parse") {
+		t.Errorf("Expected no parse error for a non-JSON error page, got %v", err)
+	}
+}
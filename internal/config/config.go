@@ -2,8 +2,9 @@ package config
 
 import (
 	"fmt"
-	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 // Config holds application configuration
@@ -12,50 +13,253 @@ type Config struct {
 	DBPath       string
 	ChunkSize    int
 	Overlap      int
+
+	// EmbeddingURL is the OpenAI-compatible embeddings endpoint and
+	// EmbeddingModel the model it is asked for
+	EmbeddingURL   string
+	EmbeddingModel string
+
+	// Chat endpoint used for query transformation (HyDE / multi-query)
+	ChatAPIKey string
+	ChatURL    string
+	ChatModel  string
+
+	// Embedding cache: in-memory LRU size (0 disables) and SQLite persistence
+	EmbeddingCacheSize    int
+	EmbeddingCachePersist bool
+
+	// Embedding request pipeline: parallel batches, rate limits and retries
+	EmbeddingConcurrency       int
+	EmbeddingRequestsPerMinute int
+	EmbeddingTokensPerMinute   int
+	EmbeddingMaxRetries        int
+
+	// EmbeddingPricePerMillion is the USD price per 1M tokens used for cost estimates
+	EmbeddingPricePerMillion float64
+
+	// HTMLExtractMode selects how HTML pages are turned into text: "readability" or "raw"
+	HTMLExtractMode string
+
+	// FetchMaxBodyBytes caps the size of a downloaded URL response
+	FetchMaxBodyBytes int
+
+	// URLAuthFile is a JSON file with per-host credentials for URL fetching
+	URLAuthFile string
+
+	// Network policy for URL fetching: private addresses are blocked unless
+	// allowed, and host lists restrict which hosts may be fetched
+	AllowPrivateNetworks bool
+	AllowedNetworks      []string
+	URLAllowHosts        []string
+	URLDenyHosts         []string
+
+	// File indexing sandbox: files must live under one of the allowed roots
+	// (empty allows any path) and never match a denied pattern
+	FileAllowedRoots []string
+	FileDenyPatterns []string
+
+	// SearchOutputMaxChars is the character budget for search results
+	// rendered as markdown or citations (0 is unlimited)
+	SearchOutputMaxChars int
+
+	// RefreshCheckInterval is how often the scheduler looks for url documents
+	// due for refresh (0 disables scheduled refresh)
+	RefreshCheckInterval time.Duration
+
+	// Database snapshots: where the backup tool and scheduler write (empty
+	// means a backups directory next to the database), how often scheduled
+	// snapshots run (0 disables) and how many are kept (0 keeps all)
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupRetain   int
+
+	// File is the config file that was loaded, empty if none
+	File string
+
+	// origins records which layer set each key, for error messages
+	origins map[string]string
+}
+
+// LoadOptions selects the config file and command-line overrides
+type LoadOptions struct {
+	// Path is an explicit config file. When empty, DOC_SEARCH_CONFIG and
+	// then SearchPaths are tried.
+	Path string
+	// Overrides are "key=value" settings from the command line
+	Overrides []string
 }
 
-// LoadConfig loads configuration from environment variables
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		DBPath:         "db_data/doc_search.db",
+		ChunkSize:      1000,
+		Overlap:        100,
+		EmbeddingURL:   "https://api.openai.com/v1/embeddings",
+		EmbeddingModel: "text-embedding-3-small",
+		ChatURL:        "https://api.openai.com/v1/chat/completions",
+		ChatModel:      "gpt-4o-mini",
+
+		EmbeddingCacheSize: 1000,
+
+		EmbeddingConcurrency:       4,
+		EmbeddingRequestsPerMinute: 3000,
+		EmbeddingTokensPerMinute:   1000000,
+		EmbeddingMaxRetries:        5,
+
+		EmbeddingPricePerMillion: 0.02,
+
+		HTMLExtractMode:   "readability",
+		FetchMaxBodyBytes: 10 << 20,
+
+		SearchOutputMaxChars: 8000,
+
+		RefreshCheckInterval: time.Minute,
+
+		BackupRetain: 7,
+
+		origins: make(map[string]string),
+	}
+}
+
+// LoadConfig loads configuration from the default config file search path
+// and environment variables
 func LoadConfig() (*Config, error) {
-	cfg := &Config{
-		OpenAIAPIKey: os.Getenv("OPENAI_API_KEY"),
-		DBPath:       getEnvOrDefault("DB_PATH", "db_data/doc_search.db"),
-		ChunkSize:    getEnvAsIntOrDefault("CHUNK_SIZE", 1000),
-		Overlap:      getEnvAsIntOrDefault("OVERLAP", 100),
+	return Load(LoadOptions{})
+}
+
+// Load builds the configuration from defaults, a config file, environment
+// variables and command-line overrides, each layer taking precedence over
+// the previous one
+func Load(opts LoadOptions) (*Config, error) {
+	cfg := Default()
+
+	path, err := findConfigFile(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
 	}
 
-	// Validate required fields
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyOverrides(opts.Overrides); err != nil {
+		return nil, err
+	}
+
+	// The chat endpoint reuses the OpenAI key unless one was given
+	if _, ok := cfg.origins["chat.api_key"]; !ok {
+		cfg.ChatAPIKey = cfg.OpenAIAPIKey
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	// Snapshots default to a directory next to the database
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = filepath.Join(filepath.Dir(cfg.DBPath), "backups")
+	}
+	return cfg, nil
+}
+
+// applyOverrides applies "key=value" settings given on the command line
+func (cfg *Config) applyOverrides(overrides []string) error {
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --set %q: expected key=value", override)
+		}
+		if err := cfg.set(key, value, "--set "+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks value ranges, naming the offending key and where it was set
+func (cfg *Config) validate() error {
 	if cfg.OpenAIAPIKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable is required")
+		return fmt.Errorf("openai_api_key is required: set the OPENAI_API_KEY environment variable or openai_api_key in the config file")
 	}
 
 	// Validate chunk size and overlap
 	if cfg.ChunkSize <= 0 {
-		return nil, fmt.Errorf("CHUNK_SIZE must be positive, got %d", cfg.ChunkSize)
+		return cfg.invalid("chunking.size", "must be positive, got %d", cfg.ChunkSize)
 	}
 	if cfg.Overlap < 0 {
-		return nil, fmt.Errorf("OVERLAP must be non-negative, got %d", cfg.Overlap)
+		return cfg.invalid("chunking.overlap", "must be non-negative, got %d", cfg.Overlap)
 	}
 	if cfg.Overlap >= cfg.ChunkSize {
-		return nil, fmt.Errorf("OVERLAP must be less than CHUNK_SIZE (overlap: %d, chunk_size: %d)", cfg.Overlap, cfg.ChunkSize)
+		return cfg.invalid("chunking.overlap", "must be less than chunking.size (overlap: %d, size: %d)", cfg.Overlap, cfg.ChunkSize)
 	}
-
-	return cfg, nil
-}
-
-// getEnvOrDefault returns environment variable value or default
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if cfg.EmbeddingCacheSize < 0 {
+		return cfg.invalid("embeddings.cache_size", "must be non-negative, got %d", cfg.EmbeddingCacheSize)
 	}
-	return defaultValue
+	if strings.TrimSpace(cfg.EmbeddingModel) == "" {
+		return cfg.invalid("embeddings.model", "must not be empty")
+	}
+	if cfg.EmbeddingConcurrency <= 0 {
+		return cfg.invalid("embeddings.concurrency", "must be positive, got %d", cfg.EmbeddingConcurrency)
+	}
+	if cfg.EmbeddingRequestsPerMinute < 0 {
+		return cfg.invalid("embeddings.requests_per_minute", "must be non-negative (0 disables the limit), got %d", cfg.EmbeddingRequestsPerMinute)
+	}
+	if cfg.EmbeddingTokensPerMinute < 0 {
+		return cfg.invalid("embeddings.tokens_per_minute", "must be non-negative (0 disables the limit), got %d", cfg.EmbeddingTokensPerMinute)
+	}
+	if cfg.EmbeddingPricePerMillion < 0 {
+		return cfg.invalid("embeddings.price_per_million", "must be non-negative, got %g", cfg.EmbeddingPricePerMillion)
+	}
+	if cfg.EmbeddingMaxRetries < 0 {
+		return cfg.invalid("embeddings.max_retries", "must be non-negative, got %d", cfg.EmbeddingMaxRetries)
+	}
+	if cfg.HTMLExtractMode != "readability" && cfg.HTMLExtractMode != "raw" {
+		return cfg.invalid("fetcher.extract_mode", "must be readability or raw, got %q", cfg.HTMLExtractMode)
+	}
+	if cfg.FetchMaxBodyBytes <= 0 {
+		return cfg.invalid("fetcher.max_body_bytes", "must be positive, got %d", cfg.FetchMaxBodyBytes)
+	}
+	if cfg.SearchOutputMaxChars < 0 {
+		return cfg.invalid("search.output_max_chars", "must be non-negative (0 is unlimited), got %d", cfg.SearchOutputMaxChars)
+	}
+	if cfg.RefreshCheckInterval < 0 {
+		return cfg.invalid("refresh.check_interval", "must be non-negative, got %s", cfg.RefreshCheckInterval)
+	}
+	if cfg.BackupInterval < 0 {
+		return cfg.invalid("backup.interval", "must be non-negative, got %s", cfg.BackupInterval)
+	}
+	if cfg.BackupRetain < 0 {
+		return cfg.invalid("backup.retain", "must be non-negative (0 keeps every snapshot), got %d", cfg.BackupRetain)
+	}
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"database.path", cfg.DBPath},
+		{"embeddings.url", cfg.EmbeddingURL},
+		{"chat.url", cfg.ChatURL},
+		{"chat.model", cfg.ChatModel},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return cfg.invalid(r.key, "must not be empty")
+		}
+	}
+
+	return nil
 }
 
-// getEnvAsIntOrDefault returns environment variable as int or default
-func getEnvAsIntOrDefault(key string, defaultValue int) int {
-	if valueStr := os.Getenv(key); valueStr != "" {
-		if value, err := strconv.Atoi(valueStr); err == nil {
-			return value
-		}
+// invalid formats a validation error for key, noting which layer set it
+func (cfg *Config) invalid(key, format string, args ...any) error {
+	msg := fmt.Sprintf("%s %s", key, fmt.Sprintf(format, args...))
+	if origin, ok := cfg.origins[key]; ok {
+		msg += fmt.Sprintf(" (set by %s)", origin)
 	}
-	return defaultValue
+	return fmt.Errorf("invalid configuration: %s", msg)
 }
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate clears every config environment variable and runs the test from
+// an empty directory so no config file is picked up
+func isolate(t *testing.T) {
+	t.Helper()
+	for _, s := range Default().settings() {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	isolate(t)
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ChunkSize != 1000 || cfg.Overlap != 100 || cfg.RefreshCheckInterval != time.Minute || cfg.SearchOutputMaxChars != 8000 {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+	if cfg.EmbeddingModel != "text-embedding-3-small" {
+		t.Errorf("Expected the default embedding model, got %q", cfg.EmbeddingModel)
+	}
+	if cfg.ChatAPIKey != "sk-test" {
+		t.Errorf("Expected chat key to default to the OpenAI key, got %q", cfg.ChatAPIKey)
+	}
+	if cfg.File != "" {
+		t.Errorf("Expected no config file, got %q", cfg.File)
+	}
+	if cfg.BackupDir != filepath.Join("db_data", "backups") || cfg.BackupRetain != 7 || cfg.BackupInterval != 0 {
+		t.Errorf("Unexpected backup defaults: dir=%q retain=%d interval=%s", cfg.BackupDir, cfg.BackupRetain, cfg.BackupInterval)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	isolate(t)
+	path := writeConfig(t, "config.yaml", `
+openai_api_key: sk-file
+chunking:
+  size: 500
+  overlap: 50
+embeddings:
+  concurrency: 2
+  model: my-embedder
+fetcher:
+  allow_hosts: [docs.example.com, "*.wiki.example.com"]
+refresh:
+  check_interval: 5m
+`)
+	t.Setenv("CHUNK_SIZE", "800")
+	t.Setenv("EMBEDDING_CONCURRENCY", "3")
+
+	cfg, err := Load(LoadOptions{Path: path, Overrides: []string{"embeddings.concurrency=8"}})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.File != path {
+		t.Errorf("Expected config file %q, got %q", path, cfg.File)
+	}
+	if cfg.OpenAIAPIKey != "sk-file" {
+		t.Errorf("Expected key from file, got %q", cfg.OpenAIAPIKey)
+	}
+	if cfg.Overlap != 50 {
+		t.Errorf("Expected overlap 50 from file, got %d", cfg.Overlap)
+	}
+	if cfg.ChunkSize != 800 {
+		t.Errorf("Expected env to override file chunk size, got %d", cfg.ChunkSize)
+	}
+	if cfg.EmbeddingConcurrency != 8 {
+		t.Errorf("Expected --set to override env concurrency, got %d", cfg.EmbeddingConcurrency)
+	}
+	if cfg.EmbeddingModel != "my-embedder" {
+		t.Errorf("Expected embedding model from file, got %q", cfg.EmbeddingModel)
+	}
+	if strings.Join(cfg.URLAllowHosts, ",") != "docs.example.com,*.wiki.example.com" {
+		t.Errorf("Unexpected allow hosts: %v", cfg.URLAllowHosts)
+	}
+	if cfg.RefreshCheckInterval != 5*time.Minute {
+		t.Errorf("Expected 5m refresh interval, got %s", cfg.RefreshCheckInterval)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	isolate(t)
+	path := writeConfig(t, "config.toml", `
+openai_api_key = "sk-toml"
+
+[embeddings]
+price_per_million = 0.13
+cache_persist = true
+
+[files]
+allowed_roots = ["/srv/docs"]
+`)
+
+	cfg, err := Load(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.EmbeddingPricePerMillion != 0.13 || !cfg.EmbeddingCachePersist {
+		t.Errorf("Unexpected embedding settings: price=%g persist=%v", cfg.EmbeddingPricePerMillion, cfg.EmbeddingCachePersist)
+	}
+	if len(cfg.FileAllowedRoots) != 1 || cfg.FileAllowedRoots[0] != "/srv/docs" {
+		t.Errorf("Unexpected allowed roots: %v", cfg.FileAllowedRoots)
+	}
+}
+
+func TestLoadSearchPath(t *testing.T) {
+	isolate(t)
+	if err := os.WriteFile("doc-search.yaml", []byte("openai_api_key: sk-found\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.File != "doc-search.yaml" || cfg.OpenAIAPIKey != "sk-found" {
+		t.Errorf("Expected doc-search.yaml to be loaded, got file=%q key=%q", cfg.File, cfg.OpenAIAPIKey)
+	}
+}
+
+func TestLoadErrorsNameKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides []string
+		expected  []string
+	}{
+		{
+			name:     "unknown file key",
+			file:     "chunking:\n  sise: 10\n",
+			expected: []string{`unknown key "chunking.sise"`, "config.yaml"},
+		},
+		{
+			name:     "wrong type in file",
+			file:     "chunking:\n  size: large\n",
+			expected: []string{"chunking.size", "expected an integer"},
+		},
+		{
+			name:     "invalid env value",
+			env:      map[string]string{"CHUNK_SIZE": "abc"},
+			expected: []string{"chunking.size", "CHUNK_SIZE", `"abc"`},
+		},
+		{
+			name:     "invalid duration",
+			env:      map[string]string{"REFRESH_CHECK_INTERVAL": "10"},
+			expected: []string{"refresh.check_interval", "REFRESH_CHECK_INTERVAL"},
+		},
+		{
+			name:     "range check names origin",
+			file:     "chunking:\n  overlap: 2000\n",
+			expected: []string{"chunking.overlap must be less than chunking.size", "config.yaml"},
+		},
+		{
+			name:      "unknown override",
+			overrides: []string{"chunk_size=5"},
+			expected:  []string{`unknown key "chunk_size"`, "--set"},
+		},
+		{
+			name:      "malformed override",
+			overrides: []string{"chunking.size"},
+			expected:  []string{"expected key=value"},
+		},
+		{
+			name:      "empty embedding model",
+			overrides: []string{"embeddings.model= "},
+			expected:  []string{"embeddings.model must not be empty", "--set embeddings.model"},
+		},
+		{
+			name:     "invalid extract mode",
+			env:      map[string]string{"HTML_EXTRACT_MODE": "full"},
+			expected: []string{"fetcher.extract_mode", "HTML_EXTRACT_MODE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			t.Setenv("OPENAI_API_KEY", "sk-test")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			opts := LoadOptions{Overrides: tt.overrides}
+			if tt.file != "" {
+				opts.Path = writeConfig(t, "config.yaml", tt.file)
+			}
+
+			_, err := Load(opts)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	isolate(t)
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+
+	if _, err := Load(LoadOptions{Path: "/nonexistent/doc-search.yaml"}); err == nil {
+		t.Error("Expected error for missing config file")
+	}
+
+	t.Setenv(ConfigFileEnv, "/nonexistent/doc-search.toml")
+	if _, err := Load(LoadOptions{}); err == nil {
+		t.Error("Expected error for missing DOC_SEARCH_CONFIG file")
+	}
+}
+
+func TestLoadRequiresAPIKey(t *testing.T) {
+	isolate(t)
+	_, err := Load(LoadOptions{})
+	if err == nil || !strings.Contains(err.Error(), "openai_api_key") {
+		t.Errorf("Expected missing key error, got %v", err)
+	}
+}
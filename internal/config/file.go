@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names an environment variable pointing at a config file
+const ConfigFileEnv = "DOC_SEARCH_CONFIG"
+
+// SearchPaths returns the config files tried, in order, when no path is
+// given: the working directory, then the user config directory
+func SearchPaths() []string {
+	paths := []string{"doc-search.yaml", "doc-search.yml", "doc-search.toml"}
+	if dir, err := os.UserConfigDir(); err == nil {
+		for _, name := range []string{"config.yaml", "config.yml", "config.toml"} {
+			paths = append(paths, filepath.Join(dir, "doc-search", name))
+		}
+	}
+	return paths
+}
+
+// findConfigFile returns the config file to load. An explicit path or
+// DOC_SEARCH_CONFIG must exist; the search path is optional.
+func findConfigFile(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("failed to open config file: %w", err)
+		}
+		return path, nil
+	}
+
+	for _, candidate := range SearchPaths() {
+		_, err := os.Stat(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to open config file: %w", err)
+		}
+	}
+	return "", nil
+}
+
+// applyFile loads a YAML or TOML config file
+func (cfg *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := yaml.Unmarshal(data, &tree); err != nil {
+				return fmt.Errorf("failed to parse config file %s: %w", path, err)
+			}
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &tree); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q (use .yaml, .yml or .toml)", ext)
+	}
+
+	values := make(map[string]any)
+	if err := flatten("", tree, values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// Apply in key order so the first error is deterministic
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := cfg.set(key, values[key], path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatten turns nested sections into dotted keys
+func flatten(prefix string, tree map[string]any, out map[string]any) error {
+	for name, v := range tree {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if section, ok := v.(map[string]any); ok {
+			if err := flatten(key, section, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if v == nil {
+			return fmt.Errorf("%s has no value", key)
+		}
+		out[key] = v
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting maps a config file key and an environment variable to a field
+type setting struct {
+	key   string
+	env   string
+	value value
+}
+
+// value parses a raw setting into its field. Raw values are strings from
+// the environment and the command line, or typed values from a config file.
+type value interface {
+	set(raw any) error
+}
+
+// settings returns every tunable, keyed as in the config file
+func (cfg *Config) settings() []setting {
+	return []setting{
+		{"openai_api_key", "OPENAI_API_KEY", stringValue{&cfg.OpenAIAPIKey}},
+		{"database.path", "DB_PATH", stringValue{&cfg.DBPath}},
+
+		{"chunking.size", "CHUNK_SIZE", intValue{&cfg.ChunkSize}},
+		{"chunking.overlap", "OVERLAP", intValue{&cfg.Overlap}},
+
+		{"embeddings.url", "EMBEDDING_API_URL", stringValue{&cfg.EmbeddingURL}},
+		{"embeddings.model", "EMBEDDING_MODEL", stringValue{&cfg.EmbeddingModel}},
+		{"embeddings.concurrency", "EMBEDDING_CONCURRENCY", intValue{&cfg.EmbeddingConcurrency}},
+		{"embeddings.requests_per_minute", "EMBEDDING_RPM", intValue{&cfg.EmbeddingRequestsPerMinute}},
+		{"embeddings.tokens_per_minute", "EMBEDDING_TPM", intValue{&cfg.EmbeddingTokensPerMinute}},
+		{"embeddings.max_retries", "EMBEDDING_MAX_RETRIES", intValue{&cfg.EmbeddingMaxRetries}},
+		{"embeddings.price_per_million", "EMBEDDING_PRICE_PER_MILLION", floatValue{&cfg.EmbeddingPricePerMillion}},
+		{"embeddings.cache_size", "EMBEDDING_CACHE_SIZE", intValue{&cfg.EmbeddingCacheSize}},
+		{"embeddings.cache_persist", "EMBEDDING_CACHE_PERSIST", boolValue{&cfg.EmbeddingCachePersist}},
+
+		{"chat.api_key", "CHAT_API_KEY", stringValue{&cfg.ChatAPIKey}},
+		{"chat.url", "CHAT_API_URL", stringValue{&cfg.ChatURL}},
+		{"chat.model", "CHAT_MODEL", stringValue{&cfg.ChatModel}},
+
+		{"fetcher.extract_mode", "HTML_EXTRACT_MODE", stringValue{&cfg.HTMLExtractMode}},
+		{"fetcher.max_body_bytes", "FETCH_MAX_BODY_BYTES", intValue{&cfg.FetchMaxBodyBytes}},
+		{"fetcher.auth_file", "URL_AUTH_FILE", stringValue{&cfg.URLAuthFile}},
+		{"fetcher.allow_private_networks", "ALLOW_PRIVATE_NETWORKS", boolValue{&cfg.AllowPrivateNetworks}},
+		{"fetcher.allowed_networks", "ALLOWED_NETWORKS", listValue{&cfg.AllowedNetworks}},
+		{"fetcher.allow_hosts", "URL_ALLOW_HOSTS", listValue{&cfg.URLAllowHosts}},
+		{"fetcher.deny_hosts", "URL_DENY_HOSTS", listValue{&cfg.URLDenyHosts}},
+
+		{"files.allowed_roots", "FILE_ALLOWED_ROOTS", listValue{&cfg.FileAllowedRoots}},
+		{"files.deny_patterns", "FILE_DENY_PATTERNS", listValue{&cfg.FileDenyPatterns}},
+
+		{"search.output_max_chars", "SEARCH_OUTPUT_MAX_CHARS", intValue{&cfg.SearchOutputMaxChars}},
+
+		{"refresh.check_interval", "REFRESH_CHECK_INTERVAL", durationValue{&cfg.RefreshCheckInterval}},
+
+		{"backup.dir", "BACKUP_DIR", stringValue{&cfg.BackupDir}},
+		{"backup.interval", "BACKUP_INTERVAL", durationValue{&cfg.BackupInterval}},
+		{"backup.retain", "BACKUP_RETAIN", intValue{&cfg.BackupRetain}},
+	}
+}
+
+// set parses raw into the setting for key and records where it came from
+func (cfg *Config) set(key string, raw any, origin string) error {
+	for _, s := range cfg.settings() {
+		if s.key != key {
+			continue
+		}
+		if err := s.value.set(raw); err != nil {
+			return fmt.Errorf("invalid configuration: %s: %w (set by %s)", key, err, origin)
+		}
+		cfg.origins[key] = origin
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: unknown key %q (set by %s)", key, origin)
+}
+
+// applyEnv applies every non-empty environment variable
+func (cfg *Config) applyEnv() error {
+	for _, s := range cfg.settings() {
+		if raw := os.Getenv(s.env); raw != "" {
+			if err := cfg.set(s.key, raw, s.env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) set(raw any) error {
+	s, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("expected a string, got %v", raw)
+	}
+	*v.p = s
+	return nil
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) set(raw any) error {
+	switch n := raw.(type) {
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", n)
+		}
+		*v.p = parsed
+	case int:
+		*v.p = n
+	case int64:
+		if n < math.MinInt || n > math.MaxInt {
+			return fmt.Errorf("integer %d out of range", n)
+		}
+		*v.p = int(n)
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt || n > math.MaxInt {
+			return fmt.Errorf("expected an integer, got %v", n)
+		}
+		*v.p = int(n)
+	default:
+		return fmt.Errorf("expected an integer, got %v", raw)
+	}
+	return nil
+}
+
+type floatValue struct{ p *float64 }
+
+func (v floatValue) set(raw any) error {
+	switch n := raw.(type) {
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", n)
+		}
+		*v.p = parsed
+	case int:
+		*v.p = float64(n)
+	case int64:
+		*v.p = float64(n)
+	case float64:
+		*v.p = n
+	default:
+		return fmt.Errorf("expected a number, got %v", raw)
+	}
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) set(raw any) error {
+	switch b := raw.(type) {
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", b)
+		}
+		*v.p = parsed
+	case bool:
+		*v.p = b
+	default:
+		return fmt.Errorf("expected true or false, got %v", raw)
+	}
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) set(raw any) error {
+	s, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("expected a duration such as \"30s\" or \"5m\", got %v", raw)
+	}
+	parsed, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("expected a duration such as \"30s\" or \"5m\", got %q", s)
+	}
+	*v.p = parsed
+	return nil
+}
+
+// listValue accepts a comma-separated string or a list of strings,
+// dropping empty entries
+type listValue struct{ p *[]string }
+
+func (v listValue) set(raw any) error {
+	var items []string
+	switch l := raw.(type) {
+	case string:
+		items = strings.Split(l, ",")
+	case []any:
+		for i, item := range l {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("item %d: expected a string, got %v", i, item)
+			}
+			items = append(items, s)
+		}
+	default:
+		return fmt.Errorf("expected a list of strings, got %v", raw)
+	}
+
+	var values []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*v.p = values
+	return nil
+}
@@ -0,0 +1,165 @@
+package embeddings
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+)
+
+// PersistentStore is a durable second-level cache for embeddings, keyed by
+// model and text hash
+type PersistentStore interface {
+	GetCachedEmbeddings(model string, hashes []string) (map[string][]float32, error)
+	PutCachedEmbeddings(model string, entries map[string][]float32) error
+}
+
+// CacheStats reports embedding cache activity
+type CacheStats struct {
+	Hits           int64 `json:"hits"`
+	PersistentHits int64 `json:"persistent_hits"`
+	Misses         int64 `json:"misses"`
+	Deduplicated   int64 `json:"deduplicated"`
+	Entries        int   `json:"entries"`
+	Capacity       int   `json:"capacity"`
+}
+
+// Cache is an in-memory LRU of embeddings with an optional persistent layer
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	store    PersistentStore
+	stats    CacheStats
+}
+
+// cacheEntry is the value stored in the LRU list
+type cacheEntry struct {
+	key       string
+	embedding []float32
+}
+
+// NewCache creates a cache holding up to capacity embeddings in memory.
+// store may be nil to disable the persistent layer.
+func NewCache(capacity int, store PersistentStore) *Cache {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &Cache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		store:    store,
+	}
+}
+
+// normalizeText collapses whitespace so trivially different texts share a key
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// hashText returns the cache key for a text
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(normalizeText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// memoryKey combines model and text hash into an LRU key
+func memoryKey(model, hash string) string {
+	return model + ":" + hash
+}
+
+// lookup returns cached embeddings by text hash, checking memory first and
+// then the persistent store. Hashes not found are counted as misses.
+func (c *Cache) lookup(model string, hashes []string) map[string][]float32 {
+	found := make(map[string][]float32, len(hashes))
+	var remaining []string
+
+	c.mu.Lock()
+	for _, hash := range hashes {
+		if elem, ok := c.items[memoryKey(model, hash)]; ok {
+			c.order.MoveToFront(elem)
+			found[hash] = elem.Value.(*cacheEntry).embedding
+			c.stats.Hits++
+		} else {
+			remaining = append(remaining, hash)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(remaining) > 0 && c.store != nil {
+		stored, err := c.store.GetCachedEmbeddings(model, remaining)
+		if err != nil {
+			log.Printf("Failed to read persistent embedding cache: %v", err)
+		} else {
+			c.mu.Lock()
+			for hash, embedding := range stored {
+				found[hash] = embedding
+				c.addLocked(memoryKey(model, hash), embedding)
+				c.stats.PersistentHits++
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses += int64(len(hashes) - len(found))
+	c.mu.Unlock()
+
+	return found
+}
+
+// save stores freshly computed embeddings in memory and, if configured, in
+// the persistent store
+func (c *Cache) save(model string, entries map[string][]float32) error {
+	c.mu.Lock()
+	for hash, embedding := range entries {
+		c.addLocked(memoryKey(model, hash), embedding)
+	}
+	c.mu.Unlock()
+
+	if c.store != nil && len(entries) > 0 {
+		return c.store.PutCachedEmbeddings(model, entries)
+	}
+	return nil
+}
+
+// addLocked inserts or refreshes an LRU entry, evicting the oldest when full.
+// The caller must hold c.mu.
+func (c *Cache) addLocked(key string, embedding []float32) {
+	if c.capacity == 0 {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).embedding = embedding
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, embedding: embedding})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// recordDeduplicated counts texts that were skipped as duplicates of another
+// text in the same request
+func (c *Cache) recordDeduplicated(n int) {
+	c.mu.Lock()
+	c.stats.Deduplicated += int64(n)
+	c.mu.Unlock()
+}
+
+// Stats returns a snapshot of cache statistics
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Capacity = c.capacity
+	return stats
+}
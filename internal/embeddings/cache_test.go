@@ -0,0 +1,93 @@
+package embeddings
+
+import (
+	"testing"
+)
+
+// memoryStore is an in-memory PersistentStore for tests
+type memoryStore struct {
+	data map[string][]float32
+}
+
+func (m *memoryStore) GetCachedEmbeddings(model string, hashes []string) (map[string][]float32, error) {
+	found := make(map[string][]float32)
+	for _, hash := range hashes {
+		if emb, ok := m.data[model+":"+hash]; ok {
+			found[hash] = emb
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryStore) PutCachedEmbeddings(model string, entries map[string][]float32) error {
+	for hash, emb := range entries {
+		m.data[model+":"+hash] = emb
+	}
+	return nil
+}
+
+func TestHashTextNormalizesWhitespace(t *testing.T) {
+	if hashText("hello  world\n") != hashText(" hello world") {
+		t.Error("Expected whitespace variants to share a hash")
+	}
+	if hashText("hello world") == hashText("Hello world") {
+		t.Error("Expected case to be significant")
+	}
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	cache := NewCache(2, nil)
+
+	cache.save("m", map[string][]float32{"a": {1}})
+	cache.save("m", map[string][]float32{"b": {2}})
+
+	// Touch a so b becomes least recently used
+	cache.lookup("m", []string{"a"})
+	cache.save("m", map[string][]float32{"c": {3}})
+
+	found := cache.lookup("m", []string{"a", "b", "c"})
+	if _, ok := found["b"]; ok {
+		t.Error("Expected b to be evicted")
+	}
+	if len(found) != 2 {
+		t.Errorf("Expected a and c to remain, got %d entries", len(found))
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Expected 3 hits and 1 miss, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", stats.Entries)
+	}
+}
+
+func TestCacheKeyedByModel(t *testing.T) {
+	cache := NewCache(10, nil)
+	cache.save("model-a", map[string][]float32{"h": {1}})
+
+	if found := cache.lookup("model-b", []string{"h"}); len(found) != 0 {
+		t.Error("Expected no hit for a different model")
+	}
+}
+
+func TestCachePersistentLayer(t *testing.T) {
+	store := &memoryStore{data: make(map[string][]float32)}
+
+	first := NewCache(10, store)
+	if err := first.save("m", map[string][]float32{"h": {1, 2}}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// A fresh cache sharing the store should find the entry on disk
+	second := NewCache(10, store)
+	found := second.lookup("m", []string{"h"})
+	if len(found["h"]) != 2 {
+		t.Fatalf("Expected persistent hit, got %v", found)
+	}
+
+	stats := second.Stats()
+	if stats.PersistentHits != 1 || stats.Entries != 1 {
+		t.Errorf("Expected 1 persistent hit promoted to memory, got %+v", stats)
+	}
+}
@@ -6,33 +6,143 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/wait"
 )
 
 const (
 	openAIEmbeddingsURL = "https://api.openai.com/v1/embeddings"
-	embeddingModel      = "text-embedding-3-small"
+	defaultModel        = "text-embedding-3-small"
 	maxBatchSize        = 100
 	embeddingDimension  = 1536
+	maxRetryBackoff     = 60 * time.Second
 )
 
+// Options configures request concurrency, rate limits and retries
+type Options struct {
+	// Endpoint overrides the embeddings API URL
+	Endpoint string
+	// Model is the embedding model requested from the endpoint; it must
+	// return 1536-dimension vectors
+	Model string
+	// Concurrency is the number of batches embedded in parallel
+	Concurrency int
+	// RequestsPerMinute and TokensPerMinute throttle outgoing requests (0 = unlimited)
+	RequestsPerMinute int
+	TokensPerMinute   int
+	// MaxRetries is the number of retries after a failed attempt
+	MaxRetries int
+	// PricePerMillionTokens is used to estimate embedding cost in USD
+	PricePerMillionTokens float64
+}
+
+// DefaultOptions returns options matching OpenAI's default tier-1 limits
+func DefaultOptions() Options {
+	return Options{
+		Endpoint:          openAIEmbeddingsURL,
+		Model:             defaultModel,
+		Concurrency:       4,
+		RequestsPerMinute: 3000,
+		TokensPerMinute:   1000000,
+		MaxRetries:        5,
+
+		PricePerMillionTokens: 0.02,
+	}
+}
+
 // Client handles OpenAI embeddings API calls
 type Client struct {
-	apiKey     string
-	httpClient *http.Client
+	apiKey      string
+	endpoint    string
+	model       string
+	concurrency int
+	maxRetries  int
+	price       float64
+	limiter     *rateLimiter
+	httpClient  *http.Client
+	cache       *Cache
 }
 
-// NewClient creates a new embeddings client
+// NewClient creates a new embeddings client with default options
 func NewClient(apiKey string) *Client {
+	return NewClientWithOptions(apiKey, DefaultOptions())
+}
+
+// NewClientWithOptions creates a new embeddings client with the given options
+func NewClientWithOptions(apiKey string, opts Options) *Client {
+	if opts.Endpoint == "" {
+		opts.Endpoint = openAIEmbeddingsURL
+	}
+	if opts.Model == "" {
+		opts.Model = defaultModel
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
 	return &Client{
-		apiKey: apiKey,
+		apiKey:      apiKey,
+		endpoint:    opts.Endpoint,
+		model:       opts.Model,
+		concurrency: opts.Concurrency,
+		maxRetries:  opts.MaxRetries,
+		price:       opts.PricePerMillionTokens,
+		limiter:     newRateLimiter(opts.RequestsPerMinute, opts.TokensPerMinute),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// SetCache enables embedding caching; nil disables it
+func (c *Client) SetCache(cache *Cache) {
+	c.cache = cache
+}
+
+// CacheStats returns embedding cache statistics, or nil when caching is disabled
+func (c *Client) CacheStats() *CacheStats {
+	if c.cache == nil {
+		return nil
+	}
+	stats := c.cache.Stats()
+	return &stats
+}
+
+// Model returns the embedding model identifier
+func (c *Client) Model() string {
+	return c.model
+}
+
+// Dimension returns the length of the embedding vectors the model produces
+func (c *Client) Dimension() int {
+	return embeddingDimension
+}
+
+// Usage records tokens consumed by embedding requests
+type Usage struct {
+	Tokens   int     `json:"tokens"`
+	Requests int     `json:"requests"`
+	Cost     float64 `json:"estimated_cost_usd"`
+}
+
+// add accumulates another usage record
+func (u *Usage) add(other Usage) {
+	u.Tokens += other.Tokens
+	u.Requests += other.Requests
+	u.Cost += other.Cost
+}
+
+// EstimateCost returns the estimated USD cost of the given number of tokens
+func (c *Client) EstimateCost(tokens int) float64 {
+	return float64(tokens) * c.price / 1e6
+}
+
 // embeddingRequest represents the OpenAI API request
 type embeddingRequest struct {
 	Input []string `json:"input"`
@@ -45,6 +155,10 @@ type embeddingResponse struct {
 		Embedding []float32 `json:"embedding"`
 		Index     int       `json:"index"`
 	} `json:"data"`
+	Usage *struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage,omitempty"`
 	Error *apiError `json:"error,omitempty"`
 }
 
@@ -55,121 +169,329 @@ type apiError struct {
 	Code    string `json:"code"`
 }
 
-// Embed generates embeddings for multiple texts
+// Embed generates embeddings for multiple texts. Identical texts are only
+// embedded once, and cached embeddings are reused when a cache is set.
 func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, _, err := c.EmbedWithUsage(ctx, texts)
+	return embeddings, err
+}
+
+// EmbedWithUsage is like Embed but also reports the tokens consumed. Texts
+// served from the cache cost nothing.
+func (c *Client) EmbedWithUsage(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	return c.EmbedWithProgress(ctx, texts, nil)
+}
+
+// ProgressFunc receives the number of texts embedded so far and the total
+type ProgressFunc func(done, total int)
+
+// EmbedWithProgress is like EmbedWithUsage and also calls progress, when
+// non-nil, once cached and duplicate texts are resolved and again after
+// each batch completes. Calls are never concurrent.
+func (c *Client) EmbedWithProgress(ctx context.Context, texts []string, progress ProgressFunc) ([][]float32, Usage, error) {
+	var usage Usage
 	if len(texts) == 0 {
-		return [][]float32{}, nil
+		return [][]float32{}, usage, nil
+	}
+
+	// Deduplicate texts by normalized hash
+	hashes := make([]string, len(texts))
+	var uniqueHashes []string
+	var uniqueTexts []string
+	seen := make(map[string]bool, len(texts))
+	for i, text := range texts {
+		hashes[i] = hashText(text)
+		if !seen[hashes[i]] {
+			seen[hashes[i]] = true
+			uniqueHashes = append(uniqueHashes, hashes[i])
+			uniqueTexts = append(uniqueTexts, text)
+		}
 	}
 
-	var allEmbeddings [][]float32
+	// Look up cached embeddings
+	byHash := make(map[string][]float32, len(uniqueHashes))
+	if c.cache != nil {
+		c.cache.recordDeduplicated(len(texts) - len(uniqueTexts))
+		byHash = c.cache.lookup(c.model, uniqueHashes)
+	}
 
-	// Process in batches
-	for i := 0; i < len(texts); i += maxBatchSize {
-		end := i + maxBatchSize
-		if end > len(texts) {
-			end = len(texts)
+	var missHashes []string
+	var missTexts []string
+	for i, hash := range uniqueHashes {
+		if _, ok := byHash[hash]; !ok {
+			missHashes = append(missHashes, hash)
+			missTexts = append(missTexts, uniqueTexts[i])
 		}
+	}
 
-		batch := texts[i:end]
-		embeddings, err := c.embedBatch(ctx, batch)
+	// Count every occurrence of a text as done once its embedding is known
+	occurrences := make(map[string]int, len(hashes))
+	for _, hash := range hashes {
+		occurrences[hash]++
+	}
+	done := len(texts)
+	for _, hash := range missHashes {
+		done -= occurrences[hash]
+	}
+	if progress != nil {
+		progress(done, len(texts))
+	}
+	onBatch := func(start, end int) {
+		if progress == nil {
+			return
+		}
+		for _, hash := range missHashes[start:end] {
+			done += occurrences[hash]
+		}
+		progress(done, len(texts))
+	}
+
+	// Embed texts not found in the cache
+	if len(missTexts) > 0 {
+		embedded, batchUsage, err := c.embedAll(ctx, missTexts, onBatch)
 		if err != nil {
-			return nil, fmt.Errorf("failed to embed batch [%d:%d]: %w", i, end, err)
+			return nil, usage, err
 		}
+		usage = batchUsage
 
-		allEmbeddings = append(allEmbeddings, embeddings...)
+		fresh := make(map[string][]float32, len(missHashes))
+		for i, hash := range missHashes {
+			byHash[hash] = embedded[i]
+			fresh[hash] = embedded[i]
+		}
 
-		// Add delay between batches to avoid rate limiting
-		if end < len(texts) {
-			select {
-			case <-time.After(100 * time.Millisecond):
-			case <-ctx.Done():
-				return nil, ctx.Err()
+		if c.cache != nil {
+			if err := c.cache.save(c.model, fresh); err != nil {
+				log.Printf("Failed to persist cached embeddings: %v", err)
 			}
 		}
 	}
 
-	return allEmbeddings, nil
+	allEmbeddings := make([][]float32, len(texts))
+	for i, hash := range hashes {
+		allEmbeddings[i] = byHash[hash]
+	}
+
+	return allEmbeddings, usage, nil
 }
 
-// embedBatch generates embeddings for a single batch
-func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+// embedAll embeds texts in batches of maxBatchSize, running up to
+// c.concurrency batches in parallel. onBatch is called with the bounds of
+// each batch that succeeds, one call at a time.
+func (c *Client) embedAll(ctx context.Context, texts []string, onBatch func(start, end int)) ([][]float32, Usage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	allEmbeddings := make([][]float32, len(texts))
+	batchStarts := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		usageMu  sync.Mutex
+		usage    Usage
+		batchMu  sync.Mutex
+	)
+
+	workers := c.concurrency
+	if batches := (len(texts) + maxBatchSize - 1) / maxBatchSize; workers > batches {
+		workers = batches
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batchStarts {
+				end := start + maxBatchSize
+				if end > len(texts) {
+					end = len(texts)
+				}
+
+				embeddings, batchUsage, err := c.embedBatch(ctx, texts[start:end])
+				usageMu.Lock()
+				usage.add(batchUsage)
+				usageMu.Unlock()
+				if err == nil {
+					copy(allEmbeddings[start:end], embeddings)
+
+					// Progress may block on a slow client; only other
+					// callbacks wait for it, not usage accounting
+					batchMu.Lock()
+					onBatch(start, end)
+					batchMu.Unlock()
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("failed to embed batch [%d:%d]: %w", start, end, err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for start := 0; start < len(texts); start += maxBatchSize {
+		select {
+		case batchStarts <- start:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(batchStarts)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, usage, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, usage, err
+	}
+
+	return allEmbeddings, usage, nil
+}
+
+// retryableError marks a failure that may succeed on a later attempt
+type retryableError struct {
+	err error
+	// delay is the server-requested wait, valid when hasDelay is set
+	delay    time.Duration
+	hasDelay bool
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// embedBatch generates embeddings for a single batch, retrying on rate
+// limits, 5xx responses and network errors
+func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	var usage Usage
+
 	// Create request payload
 	reqBody := embeddingRequest{
 		Input: texts,
-		Model: embeddingModel,
+		Model: c.model,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, usage, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	tokens := estimateTokens(texts)
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, tokens); err != nil {
+			return nil, usage, err
+		}
+
+		embeddings, reqUsage, err := c.doEmbedRequest(ctx, jsonData, texts)
+		usage.add(reqUsage)
+		if err == nil {
+			return embeddings, usage, nil
+		}
+
+		retryErr, ok := err.(*retryableError)
+		if !ok || attempt >= c.maxRetries || ctx.Err() != nil {
+			if ok {
+				return nil, usage, fmt.Errorf("giving up after %d attempts: %w", attempt+1, retryErr.err)
+			}
+			return nil, usage, err
+		}
+
+		// Honour server-provided delays, otherwise back off exponentially
+		backoff := retryErr.delay
+		if !retryErr.hasDelay {
+			backoff = time.Duration(1<<uint(attempt)) * time.Second
+		}
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		if err := wait.Sleep(ctx, backoff); err != nil {
+			return nil, usage, err
+		}
+	}
+}
+
+// doEmbedRequest performs a single embeddings API call. Usage is only
+// reported for successful responses, which are the only ones billed.
+func (c *Client) doEmbedRequest(ctx context.Context, jsonData []byte, texts []string) ([][]float32, Usage, error) {
+	var usage Usage
+
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", openAIEmbeddingsURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, usage, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
-	// Execute request with retry on rate limit
-	var resp *http.Response
-	maxRetries := 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, err = c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to execute request: %w", err)
-		}
-
-		// Check for rate limit error
-		if resp.StatusCode == http.StatusTooManyRequests {
-			resp.Body.Close()
-			if attempt < maxRetries-1 {
-				// Exponential backoff
-				backoff := time.Duration(1<<uint(attempt)) * time.Second
-				select {
-				case <-time.After(backoff):
-					continue
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				}
-			}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, usage, ctx.Err()
 		}
-		break
+		return nil, usage, &retryableError{err: fmt.Errorf("failed to execute request: %w", err)}
 	}
 	defer resp.Body.Close()
 
+	c.limiter.observe(resp.Header)
+
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, usage, &retryableError{err: fmt.Errorf("failed to read response: %w", err)}
+	}
+
+	// Retry on rate limits and server errors
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		delay, hasDelay := retryAfterDelay(resp.Header)
+		if resp.StatusCode == http.StatusTooManyRequests && delay > 0 {
+			c.limiter.pauseUntil(time.Now().Add(delay))
+		}
+		return nil, usage, &retryableError{
+			err:      fmt.Errorf("HTTP error: %d %s, body: %s", resp.StatusCode, resp.Status, string(body)),
+			delay:    delay,
+			hasDelay: hasDelay,
+		}
 	}
 
 	// Parse response
 	var embResp embeddingResponse
 	if err := json.Unmarshal(body, &embResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, usage, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Check for API error
 	if embResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI API error: %s (type: %s)", embResp.Error.Message, embResp.Error.Type)
+		return nil, usage, fmt.Errorf("OpenAI API error: %s (type: %s)", embResp.Error.Message, embResp.Error.Type)
 	}
 
 	// Check HTTP status
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %d %s, body: %s", resp.StatusCode, resp.Status, string(body))
+		return nil, usage, fmt.Errorf("HTTP error: %d %s, body: %s", resp.StatusCode, resp.Status, string(body))
+	}
+
+	// Record token usage, estimating when the endpoint does not report it
+	usage.Requests = 1
+	if embResp.Usage != nil && embResp.Usage.TotalTokens > 0 {
+		usage.Tokens = embResp.Usage.TotalTokens
+	} else {
+		usage.Tokens = estimateTokens(texts)
 	}
+	usage.Cost = c.EstimateCost(usage.Tokens)
 
 	// Extract embeddings in correct order
 	embeddings := make([][]float32, len(texts))
 	for _, item := range embResp.Data {
 		if item.Index >= len(embeddings) {
-			return nil, fmt.Errorf("invalid embedding index: %d", item.Index)
+			return nil, usage, fmt.Errorf("invalid embedding index: %d", item.Index)
 		}
 		if len(item.Embedding) != embeddingDimension {
-			return nil, fmt.Errorf("unexpected embedding dimension: got %d, expected %d", len(item.Embedding), embeddingDimension)
+			return nil, usage, fmt.Errorf("unexpected embedding dimension: got %d, expected %d", len(item.Embedding), embeddingDimension)
 		}
 		embeddings[item.Index] = item.Embedding
 	}
@@ -177,9 +499,9 @@ func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, e
 	// Verify all embeddings were received
 	for i, emb := range embeddings {
 		if emb == nil {
-			return nil, fmt.Errorf("missing embedding for index %d", i)
+			return nil, usage, fmt.Errorf("missing embedding for index %d", i)
 		}
 	}
 
-	return embeddings, nil
+	return embeddings, usage, nil
 }
@@ -2,7 +2,13 @@ package embeddings
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewClient(t *testing.T) {
@@ -30,3 +36,293 @@ func TestEmbedEmptyTexts(t *testing.T) {
 
 // Note: Actual API tests would require a valid API key and would make real API calls
 // For integration testing, you would use a valid OPENAI_API_KEY and test against the real API
+
+// fakeEmbeddingServer returns an httptest server that embeds each input as a
+// vector whose first element is the input's length
+func fakeEmbeddingServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, inputs []string) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			return
+		}
+		if handler != nil && !handler(w, r, req.Input) {
+			return
+		}
+
+		var resp struct {
+			Data []map[string]any `json:"data"`
+		}
+		for i, input := range req.Input {
+			emb := make([]float32, embeddingDimension)
+			emb[0] = float32(len(input))
+			resp.Data = append(resp.Data, map[string]any{"index": i, "embedding": emb})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func testOptions(endpoint string) Options {
+	return Options{Endpoint: endpoint, Concurrency: 4, MaxRetries: 3}
+}
+
+func TestEmbedPreservesOrderAcrossConcurrentBatches(t *testing.T) {
+	server := fakeEmbeddingServer(t, nil)
+	defer server.Close()
+
+	texts := make([]string, 250)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+
+	client := NewClientWithOptions("test-api-key", testOptions(server.URL))
+	embeddings, err := client.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i, emb := range embeddings {
+		if emb[0] != float32(i+1) {
+			t.Fatalf("Embedding %d out of order: got marker %f", i, emb[0])
+		}
+	}
+}
+
+func TestEmbedDeduplicatesTexts(t *testing.T) {
+	var sent int32
+	server := fakeEmbeddingServer(t, func(w http.ResponseWriter, r *http.Request, inputs []string) bool {
+		atomic.AddInt32(&sent, int32(len(inputs)))
+		return true
+	})
+	defer server.Close()
+
+	client := NewClientWithOptions("test-api-key", testOptions(server.URL))
+	client.SetCache(NewCache(10, nil))
+
+	embeddings, err := client.Embed(context.Background(), []string{"a", "bb", "a ", "bb"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embeddings) != 4 {
+		t.Fatalf("Expected 4 embeddings, got %d", len(embeddings))
+	}
+	if sent != 2 {
+		t.Errorf("Expected 2 texts sent to the API, got %d", sent)
+	}
+
+	// A repeated call should be served entirely from the cache
+	if _, err := client.Embed(context.Background(), []string{"bb"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("Expected cached embedding to be reused, API saw %d texts", sent)
+	}
+
+	stats := client.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Deduplicated != 2 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestEmbedRetriesServerErrorsAndHonoursRetryAfter(t *testing.T) {
+	var attempts int32
+	server := fakeEmbeddingServer(t, func(w http.ResponseWriter, r *http.Request, inputs []string) bool {
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return false
+		}
+		return true
+	})
+	defer server.Close()
+
+	opts := testOptions(server.URL)
+	client := NewClientWithOptions("test-api-key", opts)
+
+	start := time.Now()
+	if _, err := client.Embed(context.Background(), []string{"hello"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	// 1s backoff after the 503, then Retry-After: 0 for the 429
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Retry-After was not honoured, took %v", elapsed)
+	}
+}
+
+func TestEmbedDoesNotRetryClientErrors(t *testing.T) {
+	var attempts int32
+	server := fakeEmbeddingServer(t, func(w http.ResponseWriter, r *http.Request, inputs []string) bool {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad input","type":"invalid_request_error"}}`))
+		return false
+	})
+	defer server.Close()
+
+	client := NewClientWithOptions("test-api-key", testOptions(server.URL))
+	if _, err := client.Embed(context.Background(), []string{"hello"}); err == nil {
+		t.Fatal("Expected error for 400 response")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{"seconds", map[string]string{"Retry-After": "2"}, 2 * time.Second, true},
+		{"milliseconds", map[string]string{"retry-after-ms": "150"}, 150 * time.Millisecond, true},
+		{"ratelimit reset", map[string]string{"x-ratelimit-remaining-tokens": "0", "x-ratelimit-reset-tokens": "6m0s"}, 6 * time.Minute, true},
+		{"remaining capacity", map[string]string{"x-ratelimit-remaining-requests": "10", "x-ratelimit-reset-requests": "1s"}, 0, false},
+		{"none", map[string]string{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			delay, ok := retryAfterDelay(header)
+			if delay != tt.expected || ok != tt.ok {
+				t.Errorf("retryAfterDelay() = (%v, %v), expected (%v, %v)", delay, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(60) // one per second
+	if d := bucket.reserve(60); d != 0 {
+		t.Errorf("Expected full bucket to allow burst, got wait %v", d)
+	}
+	if d := bucket.reserve(1); d < 900*time.Millisecond || d > time.Second {
+		t.Errorf("Expected ~1s wait on empty bucket, got %v", d)
+	}
+	if newTokenBucket(0) != nil {
+		t.Error("Expected nil bucket for unlimited rate")
+	}
+}
+
+func TestRateLimiterRefundsCancelledWait(t *testing.T) {
+	limiter := newRateLimiter(1, 600) // one request a minute, ten tokens a second
+	if err := limiter.wait(context.Background(), 600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A wait cancelled before its turn gives its reservation back
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx, 600); err == nil {
+		t.Fatal("Expected the wait to be cancelled")
+	}
+	if d := limiter.tokens.reserve(10); d > 2*time.Second {
+		t.Errorf("Expected the cancelled reservation to be refunded, got a %v wait", d)
+	}
+	if d := limiter.requests.reserve(1); d > 90*time.Second {
+		t.Errorf("Expected the cancelled request to be refunded, got a %v wait", d)
+	}
+}
+
+func TestEmbedWithUsageReportsTokensAndCost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		emb := make([]float32, embeddingDimension)
+		json.NewEncoder(w).Encode(map[string]any{
+			"data":  []map[string]any{{"index": 0, "embedding": emb}},
+			"usage": map[string]int{"prompt_tokens": 500000, "total_tokens": 500000},
+		})
+	}))
+	defer server.Close()
+
+	opts := testOptions(server.URL)
+	opts.PricePerMillionTokens = 0.02
+	client := NewClientWithOptions("test-api-key", opts)
+
+	_, usage, err := client.EmbedWithUsage(context.Background(), []string{"hello"})
+	if err != nil {
+		t.Fatalf("EmbedWithUsage failed: %v", err)
+	}
+	if usage.Tokens != 500000 || usage.Requests != 1 {
+		t.Errorf("Expected 500000 tokens in 1 request, got %+v", usage)
+	}
+	if usage.Cost < 0.0099 || usage.Cost > 0.0101 {
+		t.Errorf("Expected cost of $0.01, got %f", usage.Cost)
+	}
+}
+
+func TestEmbedRequestsConfiguredModel(t *testing.T) {
+	var model atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		model.Store(req.Model)
+		emb := make([]float32, embeddingDimension)
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"index": 0, "embedding": emb}}})
+	}))
+	defer server.Close()
+
+	if got := NewClientWithOptions("test-api-key", testOptions(server.URL)).Model(); got != defaultModel {
+		t.Errorf("Expected the default model %q, got %q", defaultModel, got)
+	}
+
+	opts := testOptions(server.URL)
+	opts.Model = "custom-embedder"
+	client := NewClientWithOptions("test-api-key", opts)
+	if _, err := client.Embed(context.Background(), []string{"hello"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if model.Load() != "custom-embedder" || client.Model() != "custom-embedder" {
+		t.Errorf("Expected requests for custom-embedder, got %v", model.Load())
+	}
+}
+
+func TestEmbedWithProgressReportsBatches(t *testing.T) {
+	server := fakeEmbeddingServer(t, nil)
+	defer server.Close()
+
+	client := NewClientWithOptions("test-api-key", testOptions(server.URL))
+	client.SetCache(NewCache(1000, nil))
+	if _, err := client.Embed(context.Background(), []string{"cached"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+
+	// 250 unique texts need 3 batches; the duplicate and cached text are done up front
+	texts := []string{"cached", "dup", "dup"}
+	for i := 0; i < 249; i++ {
+		texts = append(texts, strings.Repeat("x", i+1))
+	}
+
+	var calls [][2]int
+	_, _, err := client.EmbedWithProgress(context.Background(), texts, func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	if err != nil {
+		t.Fatalf("EmbedWithProgress failed: %v", err)
+	}
+
+	if len(calls) != 4 {
+		t.Fatalf("Expected an initial call and one per batch, got %v", calls)
+	}
+	if calls[0] != [2]int{1, len(texts)} {
+		t.Errorf("Expected cached text to be done up front, got %v", calls[0])
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i][0] <= calls[i-1][0] {
+			t.Errorf("Progress did not increase: %v", calls)
+		}
+	}
+	if last := calls[len(calls)-1]; last[0] != len(texts) || last[1] != len(texts) {
+		t.Errorf("Expected final progress %d/%d, got %v", len(texts), len(texts), last)
+	}
+}
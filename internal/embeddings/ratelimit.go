@@ -0,0 +1,189 @@
+package embeddings
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/wait"
+)
+
+// tokenBucket is a simple token bucket refilled continuously over a minute
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+}
+
+// newTokenBucket creates a bucket allowing perMinute units per minute.
+// A non-positive perMinute returns nil, meaning unlimited.
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// reserve takes n units from the bucket and returns how long the caller must
+// wait before they are available. Requests larger than the bucket are capped
+// to its capacity so they can eventually proceed.
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > b.capacity {
+		n = b.capacity
+	}
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.perSec * float64(time.Second))
+}
+
+// refund returns n units taken by reserve for a request that was never sent
+func (b *tokenBucket) refund(n float64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.tokens += n
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// rateLimiter throttles requests by requests/minute and tokens/minute, and
+// pauses all callers when the API reports an exhausted limit
+type rateLimiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+// newRateLimiter creates a limiter; zero limits disable the respective bucket
+func newRateLimiter(requestsPerMinute, tokensPerMinute int) *rateLimiter {
+	return &rateLimiter{
+		requests: newTokenBucket(requestsPerMinute),
+		tokens:   newTokenBucket(tokensPerMinute),
+	}
+}
+
+// wait blocks until one request carrying the given number of tokens may be sent
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	l.mu.Lock()
+	delay := time.Until(l.blockedUntil)
+	l.mu.Unlock()
+
+	if d := l.requests.reserve(1); d > delay {
+		delay = d
+	}
+	if d := l.tokens.reserve(float64(tokens)); d > delay {
+		delay = d
+	}
+
+	if err := wait.Sleep(ctx, delay); err != nil {
+		// The request will not be sent, so later callers need not wait for it
+		l.requests.refund(1)
+		l.tokens.refund(float64(tokens))
+		return err
+	}
+	return nil
+}
+
+// pauseUntil blocks subsequent requests until the given time
+func (l *rateLimiter) pauseUntil(t time.Time) {
+	l.mu.Lock()
+	if t.After(l.blockedUntil) {
+		l.blockedUntil = t
+	}
+	l.mu.Unlock()
+}
+
+// observe inspects x-ratelimit-* headers and pauses the limiter when the
+// server reports no remaining requests or tokens
+func (l *rateLimiter) observe(header http.Header) {
+	if d, ok := exhaustedResetDelay(header); ok {
+		l.pauseUntil(time.Now().Add(d))
+	}
+}
+
+// exhaustedResetDelay returns the reset delay for any x-ratelimit bucket that
+// has no remaining capacity
+func exhaustedResetDelay(header http.Header) (time.Duration, bool) {
+	var delay time.Duration
+	exhausted := false
+	for _, kind := range []string{"requests", "tokens"} {
+		remaining, err := strconv.Atoi(header.Get("x-ratelimit-remaining-" + kind))
+		if err != nil || remaining > 0 {
+			continue
+		}
+		if d, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + kind)); err == nil {
+			exhausted = true
+			if d > delay {
+				delay = d
+			}
+		}
+	}
+	return delay, exhausted
+}
+
+// retryAfterDelay returns the delay requested by Retry-After (seconds or
+// HTTP date) or retry-after-ms, falling back to x-ratelimit reset headers
+func retryAfterDelay(header http.Header) (time.Duration, bool) {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+	if ra := header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.ParseFloat(ra, 64); err == nil && secs >= 0 {
+			return time.Duration(secs * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(ra); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+	return exhaustedResetDelay(header)
+}
+
+// estimateTokens roughly approximates the token count of texts (~4 chars/token)
+func estimateTokens(texts []string) int {
+	total := 0
+	for _, text := range texts {
+		total += len(text)/4 + 1
+	}
+	return total
+}
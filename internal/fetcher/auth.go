@@ -0,0 +1,320 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"sort"
+	"strings"
+)
+
+// redactedPlaceholder replaces secret values in error messages
+const redactedPlaceholder = "[REDACTED]"
+
+// AuthConfig holds per-host credentials for authenticated fetching, keyed by
+// host pattern. A pattern is an exact host name ("wiki.example.com"), a host
+// and port ("localhost:8080"), or a wildcard for subdomains ("*.example.com").
+type AuthConfig struct {
+	Hosts map[string]HostAuth `json:"hosts"`
+}
+
+// HostAuth describes the credentials sent to a host. Secrets may be given
+// inline or read from an environment variable or file.
+type HostAuth struct {
+	// Headers are static headers added to every request
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Bearer token sent as "Authorization: Bearer <token>"
+	BearerToken     string `json:"bearer_token,omitempty"`
+	BearerTokenEnv  string `json:"bearer_token_env,omitempty"`
+	BearerTokenFile string `json:"bearer_token_file,omitempty"`
+
+	// HTTP basic auth
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	PasswordEnv  string `json:"password_env,omitempty"`
+	PasswordFile string `json:"password_file,omitempty"`
+
+	// Cookies sent with every request, e.g. a session cookie copied from a browser
+	Cookies map[string]string `json:"cookies,omitempty"`
+}
+
+// LoadAuthConfig reads an AuthConfig from a JSON file
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var cfg AuthConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// hostCredentials are resolved credentials for one host pattern
+type hostCredentials struct {
+	pattern string
+	headers http.Header
+	cookies []*http.Cookie
+}
+
+// authenticator applies per-host credentials to outgoing requests
+type authenticator struct {
+	hosts []hostCredentials
+	// managed lists every header and cookie name set for any host, so they
+	// can be removed when a redirect leaves that host
+	managed        []string
+	managedCookies map[string]bool
+	secrets        []string
+}
+
+// newAuthenticator resolves secrets from env and files and validates the config
+func newAuthenticator(cfg *AuthConfig) (*authenticator, error) {
+	a := &authenticator{managedCookies: map[string]bool{}}
+	managed := map[string]bool{}
+
+	patterns := make([]string, 0, len(cfg.Hosts))
+	for pattern := range cfg.Hosts {
+		patterns = append(patterns, pattern)
+	}
+	// Exact patterns win over wildcards; longer wildcards over shorter ones
+	sort.Slice(patterns, func(i, j int) bool {
+		wi, wj := strings.HasPrefix(patterns[i], "*."), strings.HasPrefix(patterns[j], "*.")
+		if wi != wj {
+			return !wi
+		}
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		auth := cfg.Hosts[pattern]
+		creds := hostCredentials{pattern: strings.ToLower(strings.TrimSpace(pattern)), headers: http.Header{}}
+		if creds.pattern == "" || strings.Contains(creds.pattern, "/") {
+			return nil, fmt.Errorf("invalid auth host pattern %q (use host, host:port or *.domain)", pattern)
+		}
+
+		for name, value := range auth.Headers {
+			creds.headers.Set(name, value)
+			a.addSecret(value)
+		}
+
+		token, err := resolveSecret(auth.BearerToken, auth.BearerTokenEnv, auth.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth for %s: bearer token: %w", pattern, err)
+		}
+
+		password, err := resolveSecret(auth.Password, auth.PasswordEnv, auth.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth for %s: password: %w", pattern, err)
+		}
+
+		switch {
+		case token != "" && auth.Username != "":
+			return nil, fmt.Errorf("auth for %s: use either a bearer token or basic auth, not both", pattern)
+		case token != "":
+			creds.headers.Set("Authorization", "Bearer "+token)
+			a.addSecret(token)
+		case auth.Username != "":
+			basic := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + password))
+			creds.headers.Set("Authorization", "Basic "+basic)
+			a.addSecret(password)
+			a.addSecret(basic)
+		case password != "":
+			return nil, fmt.Errorf("auth for %s: password requires username", pattern)
+		}
+
+		cookieNames := make([]string, 0, len(auth.Cookies))
+		for name := range auth.Cookies {
+			cookieNames = append(cookieNames, name)
+		}
+		sort.Strings(cookieNames)
+		for _, name := range cookieNames {
+			cookie := &http.Cookie{Name: name, Value: auth.Cookies[name]}
+			if err := cookie.Valid(); err != nil {
+				return nil, fmt.Errorf("auth for %s: invalid cookie %q", pattern, name)
+			}
+			creds.cookies = append(creds.cookies, cookie)
+			a.managedCookies[name] = true
+			a.addSecret(cookie.Value)
+		}
+
+		for name := range creds.headers {
+			if !managed[name] {
+				managed[name] = true
+				a.managed = append(a.managed, name)
+			}
+		}
+		a.hosts = append(a.hosts, creds)
+	}
+
+	return a, nil
+}
+
+// resolveSecret returns the first configured source of a secret: inline
+// value, environment variable or file. Errors name the source, never the value.
+func resolveSecret(value, env, file string) (string, error) {
+	set := 0
+	for _, s := range []string{value, env, file} {
+		if s != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", errors.New("set only one of the inline value, _env or _file")
+	}
+
+	switch {
+	case env != "":
+		v := os.Getenv(env)
+		if v == "" {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+		return v, nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, errors.Unwrap(err))
+		}
+		v := strings.TrimSpace(string(data))
+		if v == "" {
+			return "", fmt.Errorf("file %s is empty", file)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// addSecret registers a value to be redacted from error messages
+func (a *authenticator) addSecret(secret string) {
+	// Very short values would redact unrelated text and leak little anyway
+	if len(secret) >= 4 {
+		a.secrets = append(a.secrets, secret)
+	}
+}
+
+// match returns the credentials for a request host, if any
+func (a *authenticator) match(hostport, hostname string) *hostCredentials {
+	hostport = strings.ToLower(hostport)
+	hostname = strings.ToLower(hostname)
+	for i := range a.hosts {
+		if matchHostPattern(a.hosts[i].pattern, hostport, hostname) {
+			return &a.hosts[i]
+		}
+	}
+	return nil
+}
+
+// matchHostPattern reports whether a host matches a pattern. Patterns with a
+// port match host:port exactly; "*.domain" matches any subdomain of domain.
+func matchHostPattern(pattern, hostport, hostname string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(hostname, pattern[1:])
+	}
+	if strings.Contains(pattern, ":") {
+		return pattern == hostport
+	}
+	return pattern == hostname
+}
+
+// apply removes credentials meant for other hosts and adds those configured
+// for the request's host. It is called for the initial request and again on
+// every redirect so credentials never follow a redirect to another host.
+func (a *authenticator) apply(req *http.Request) {
+	if a == nil {
+		return
+	}
+	for _, name := range a.managed {
+		req.Header.Del(name)
+	}
+	if len(a.managedCookies) > 0 && req.Header.Get("Cookie") != "" {
+		cookies := req.Cookies()
+		req.Header.Del("Cookie")
+		for _, cookie := range cookies {
+			if !a.managedCookies[cookie.Name] {
+				req.AddCookie(cookie)
+			}
+		}
+	}
+
+	creds := a.match(req.URL.Host, req.URL.Hostname())
+	if creds == nil {
+		return
+	}
+	for name, values := range creds.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	for _, cookie := range creds.cookies {
+		req.AddCookie(cookie)
+	}
+}
+
+// redact replaces any configured secret in text with a placeholder
+func (a *authenticator) redact(text string) string {
+	if a == nil {
+		return text
+	}
+	for _, secret := range a.secrets {
+		text = strings.ReplaceAll(text, secret, redactedPlaceholder)
+	}
+	return text
+}
+
+// redactedError hides secrets in an error message while keeping the
+// original error available to errors.Is and errors.As
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError returns err with any configured secret removed from its message
+func (a *authenticator) redactError(err error) error {
+	if a == nil || err == nil {
+		return err
+	}
+	msg := err.Error()
+	if redacted := a.redact(msg); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+	return err
+}
+
+// SetAuth configures per-host credentials and enables a cookie jar so
+// session cookies set by authenticated hosts are kept between requests
+func (f *Fetcher) SetAuth(cfg *AuthConfig) error {
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+
+	f.auth = auth
+	f.httpClient.Jar = jar
+	return nil
+}
+
+// AuthHostCount returns the number of hosts with configured credentials
+func (f *Fetcher) AuthHostCount() int {
+	if f.auth == nil {
+		return 0
+	}
+	return len(f.auth.hosts)
+}
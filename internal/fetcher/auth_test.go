@@ -0,0 +1,236 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordingServer records the headers of every request it receives
+func recordingServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *[]http.Header) {
+	t.Helper()
+	var seen []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Clone())
+		if handler != nil {
+			handler(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &seen
+}
+
+func hostOf(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestFetcherAuthHeaders(t *testing.T) {
+	authed, authedSeen := recordingServer(t, nil)
+	other, otherSeen := recordingServer(t, nil)
+
+	t.Setenv("TEST_WIKI_TOKEN", "s3cr3t-token")
+	f := newLoopbackFetcher(t)
+	err := f.SetAuth(&AuthConfig{Hosts: map[string]HostAuth{
+		hostOf(t, authed.URL): {
+			Headers:        map[string]string{"X-Team": "docs"},
+			BearerTokenEnv: "TEST_WIKI_TOKEN",
+			Cookies:        map[string]string{"session": "abc123"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("SetAuth failed: %v", err)
+	}
+
+	if _, err := f.FetchURL(context.Background(), authed.URL); err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+	if _, err := f.FetchURL(context.Background(), other.URL); err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+
+	got := (*authedSeen)[0]
+	if got.Get("Authorization") != "Bearer s3cr3t-token" {
+		t.Errorf("Expected bearer token, got %q", got.Get("Authorization"))
+	}
+	if got.Get("X-Team") != "docs" {
+		t.Errorf("Expected X-Team header, got %q", got.Get("X-Team"))
+	}
+	if !strings.Contains(got.Get("Cookie"), "session=abc123") {
+		t.Errorf("Expected session cookie, got %q", got.Get("Cookie"))
+	}
+
+	leaked := (*otherSeen)[0]
+	for _, name := range []string{"Authorization", "X-Team", "Cookie"} {
+		if leaked.Get(name) != "" {
+			t.Errorf("Expected no %s header for other host, got %q", name, leaked.Get(name))
+		}
+	}
+}
+
+func TestFetcherAuthBasicFromFile(t *testing.T) {
+	server, seen := recordingServer(t, nil)
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("hunter2-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newLoopbackFetcher(t)
+	err := f.SetAuth(&AuthConfig{Hosts: map[string]HostAuth{
+		hostOf(t, server.URL): {Username: "alice", PasswordFile: passwordFile},
+	}})
+	if err != nil {
+		t.Fatalf("SetAuth failed: %v", err)
+	}
+
+	if _, err := f.FetchURL(context.Background(), server.URL); err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+
+	req := &http.Request{Header: (*seen)[0]}
+	user, pass, ok := req.BasicAuth()
+	if !ok || user != "alice" || pass != "hunter2-pass" {
+		t.Errorf("Expected basic auth alice/hunter2-pass, got %q/%q (ok=%v)", user, pass, ok)
+	}
+}
+
+func TestFetcherAuthNotForwardedOnRedirect(t *testing.T) {
+	target, targetSeen := recordingServer(t, nil)
+	origin, _ := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/landing", http.StatusFound)
+	})
+
+	f := newLoopbackFetcher(t)
+	err := f.SetAuth(&AuthConfig{Hosts: map[string]HostAuth{
+		hostOf(t, origin.URL): {
+			Headers:     map[string]string{"X-Api-Key": "key-12345"},
+			BearerToken: "token-12345",
+			Cookies:     map[string]string{"session": "abc123"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("SetAuth failed: %v", err)
+	}
+
+	if _, err := f.FetchURL(context.Background(), origin.URL); err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+
+	got := (*targetSeen)[0]
+	for _, name := range []string{"Authorization", "X-Api-Key", "Cookie"} {
+		if got.Get(name) != "" {
+			t.Errorf("Expected %s to be dropped on redirect, got %q", name, got.Get(name))
+		}
+	}
+}
+
+func TestFetcherCookieJar(t *testing.T) {
+	server, seen := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "from-server", Path: "/"})
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "ok")
+	})
+
+	f := newLoopbackFetcher(t)
+	if err := f.SetAuth(&AuthConfig{}); err != nil {
+		t.Fatalf("SetAuth failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.FetchURL(context.Background(), server.URL); err != nil {
+			t.Fatalf("FetchURL failed: %v", err)
+		}
+	}
+
+	if !strings.Contains((*seen)[1].Get("Cookie"), "sid=from-server") {
+		t.Errorf("Expected session cookie on second request, got %q", (*seen)[1].Get("Cookie"))
+	}
+}
+
+func TestAuthConfigErrorsHideSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		auth HostAuth
+	}{
+		{"missing env", HostAuth{BearerTokenEnv: "TEST_UNSET_TOKEN_VAR"}},
+		{"missing file", HostAuth{BearerTokenFile: "/nonexistent/token"}},
+		{"token and basic", HostAuth{BearerToken: "visible-secret", Username: "bob"}},
+		{"two sources", HostAuth{BearerToken: "visible-secret", BearerTokenEnv: "X"}},
+		{"password without username", HostAuth{Password: "visible-secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher()
+			err := f.SetAuth(&AuthConfig{Hosts: map[string]HostAuth{"example.com": tt.auth}})
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if strings.Contains(err.Error(), "visible-secret") {
+				t.Errorf("Error leaks secret: %v", err)
+			}
+		})
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	f := NewFetcher()
+	if err := f.SetAuth(&AuthConfig{Hosts: map[string]HostAuth{"example.com": {BearerToken: "tok-abcdef"}}}); err != nil {
+		t.Fatalf("SetAuth failed: %v", err)
+	}
+
+	base := fmt.Errorf("wrapped: %w", ErrUnsupportedContentType)
+	err := f.auth.redactError(fmt.Errorf("server said tok-abcdef: %w", base))
+	if strings.Contains(err.Error(), "tok-abcdef") {
+		t.Errorf("Expected token to be redacted, got %v", err)
+	}
+	if !errors.Is(err, ErrUnsupportedContentType) {
+		t.Error("Expected redacted error to unwrap to the original")
+	}
+	if got := f.auth.redact("Bearer tok-abcdef"); got != "Bearer "+redactedPlaceholder {
+		t.Errorf("Redact = %q", got)
+	}
+}
+
+func TestMatchHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		hostport string
+		expected bool
+	}{
+		{"wiki.example.com", "wiki.example.com", true},
+		{"wiki.example.com", "wiki.example.com:8443", true},
+		{"wiki.example.com", "example.com", false},
+		{"*.example.com", "docs.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"localhost:8080", "localhost:8080", true},
+		{"localhost:8080", "localhost:9090", false},
+	}
+
+	for _, tt := range tests {
+		hostname := tt.hostport
+		if i := strings.LastIndex(hostname, ":"); i >= 0 {
+			hostname = hostname[:i]
+		}
+		if got := matchHostPattern(tt.pattern, tt.hostport, hostname); got != tt.expected {
+			t.Errorf("matchHostPattern(%q, %q) = %v, expected %v", tt.pattern, tt.hostport, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/wait"
+)
+
+// CrawlOptions configures a site crawl
+type CrawlOptions struct {
+	// MaxDepth is the number of link hops followed from the start URL
+	MaxDepth int
+	// MaxPages caps the number of pages fetched
+	MaxPages int
+	// Include and Exclude are regular expressions matched against discovered
+	// URLs. A URL must match an Include pattern (if any) and no Exclude pattern.
+	Include []string
+	Exclude []string
+	// Delay is the minimum pause between requests; robots.txt Crawl-delay
+	// takes precedence when it is longer
+	Delay time.Duration
+}
+
+// CrawlPage is a page visited during a crawl. Err is set when the page could
+// not be fetched.
+type CrawlPage struct {
+	URL    string
+	Depth  int
+	Result *FetchResult
+	Err    error
+}
+
+// CrawlSummary reports what a crawl skipped
+type CrawlSummary struct {
+	Fetched    int
+	Disallowed int
+	Filtered   int
+}
+
+// crawlItem is a queued URL
+type crawlItem struct {
+	url   string
+	depth int
+}
+
+// Crawl fetches startURL and follows same-origin links breadth-first,
+// honouring robots.txt and seeding from the site's sitemap when present.
+// visit is called for every fetched page; returning an error stops the crawl.
+func (f *Fetcher) Crawl(ctx context.Context, startURL string, opts CrawlOptions, visit func(CrawlPage) error) (*CrawlSummary, error) {
+	start, err := url.Parse(startURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if start.Scheme != "http" && start.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme: %s (must be http or https)", start.Scheme)
+	}
+	if opts.MaxPages <= 0 {
+		return nil, fmt.Errorf("max pages must be positive")
+	}
+
+	include, err := compilePatterns(opts.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	exclude, err := compilePatterns(opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	// Every request to a host, including robots.txt and sitemaps, is spaced
+	// by the crawl delay
+	pacer := &hostPacer{delay: opts.Delay, last: make(map[string]time.Time)}
+
+	origin := &url.URL{Scheme: start.Scheme, Host: start.Host}
+	robots, err := f.fetchRobots(ctx, origin, pacer)
+	if err != nil {
+		return nil, err
+	}
+	if robots != nil && robots.crawlDelay > pacer.delay {
+		pacer.delay = robots.crawlDelay
+	}
+
+	summary := &CrawlSummary{}
+	visited := make(map[string]bool)
+	var queue []crawlItem
+
+	enqueue := func(raw string, depth int, filtered bool) {
+		u, err := url.Parse(raw)
+		if err != nil || !sameOrigin(raw, origin) {
+			return
+		}
+		key := normalizeCrawlURL(u)
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+
+		if filtered && !matchesFilters(key, include, exclude) {
+			summary.Filtered++
+			return
+		}
+		if !robots.allowed(u.RequestURI()) {
+			summary.Disallowed++
+			return
+		}
+		queue = append(queue, crawlItem{url: key, depth: depth})
+	}
+
+	if !robots.allowed(start.RequestURI()) {
+		return nil, fmt.Errorf("start URL is disallowed by robots.txt: %s", startURL)
+	}
+	enqueue(startURL, 0, false)
+
+	// Seed with sitemap URLs as if linked from the start page
+	if opts.MaxDepth > 0 {
+		for _, entry := range f.discoverSitemap(ctx, origin, robots, pacer) {
+			enqueue(entry.Loc, 1, true)
+		}
+	}
+
+	for len(queue) > 0 && summary.Fetched < opts.MaxPages {
+		item := queue[0]
+		queue = queue[1:]
+
+		if err := pacer.wait(ctx, item.url); err != nil {
+			return summary, err
+		}
+
+		result, fetchErr := f.FetchURL(ctx, item.url)
+		if errors.Is(fetchErr, ErrUnsupportedContentType) {
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return summary, ctxErr
+		}
+		// A same-origin URL that redirects off the site is not indexed
+		// under the in-origin name
+		if fetchErr == nil && !sameOrigin(result.URL, origin) {
+			summary.Filtered++
+			continue
+		}
+		summary.Fetched++
+
+		if err := visit(CrawlPage{URL: item.url, Depth: item.depth, Result: result, Err: fetchErr}); err != nil {
+			return summary, err
+		}
+
+		if fetchErr == nil && item.depth < opts.MaxDepth {
+			for _, link := range result.Links {
+				enqueue(link, item.depth+1, true)
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// hostPacer spaces requests to the same host by a delay
+type hostPacer struct {
+	delay time.Duration
+	last  map[string]time.Time
+}
+
+// wait sleeps until delay has passed since the last request to the host of
+// rawURL, then records this request
+func (p *hostPacer) wait(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ctx.Err()
+	}
+	if last, ok := p.last[u.Host]; ok {
+		if err := wait.Sleep(ctx, time.Until(last.Add(p.delay))); err != nil {
+			return err
+		}
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.last[u.Host] = time.Now()
+	return nil
+}
+
+// fetchRobots loads robots.txt for an origin; a missing or unreadable file
+// allows everything
+func (f *Fetcher) fetchRobots(ctx context.Context, origin *url.URL, pacer *hostPacer) (*robotsRules, error) {
+	robotsURL := origin.String() + "/robots.txt"
+	if err := pacer.wait(ctx, robotsURL); err != nil {
+		return nil, err
+	}
+	status, body, err := f.fetchRaw(ctx, robotsURL, f.maxBodySize)
+	if err != nil || status != http.StatusOK {
+		return nil, ctx.Err()
+	}
+	return parseRobots(string(body), userAgent), nil
+}
+
+// discoverSitemap returns entries from sitemaps listed in robots.txt, or from
+// /sitemap.xml when robots.txt lists none. Failures are ignored.
+func (f *Fetcher) discoverSitemap(ctx context.Context, origin *url.URL, robots *robotsRules, pacer *hostPacer) []SitemapEntry {
+	sitemaps := []string{origin.String() + "/sitemap.xml"}
+	if robots != nil && len(robots.sitemaps) > 0 {
+		sitemaps = robots.sitemaps
+	}
+
+	var entries []SitemapEntry
+	for _, sm := range sitemaps {
+		found, _, err := f.fetchSitemaps(ctx, sm, pacer.wait)
+		if err == nil {
+			entries = append(entries, found...)
+		}
+	}
+	return entries
+}
+
+// sameOrigin reports whether raw has the scheme and host of origin
+func sameOrigin(raw string, origin *url.URL) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == origin.Scheme && u.Host == origin.Host
+}
+
+// normalizeCrawlURL strips fragments and gives empty paths a trailing slash so
+// equivalent URLs are only visited once
+func normalizeCrawlURL(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
+
+// compilePatterns compiles a list of regular expressions
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchesFilters reports whether a URL passes the include and exclude patterns
+func matchesFilters(u string, include, exclude []*regexp.Regexp) bool {
+	for _, re := range exclude {
+		if re.MatchString(u) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, re := range include {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSite serves a small site with robots.txt, a sitemap and several
+// linked pages
+func newTestSite(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	pages := map[string]string{
+		"/":             `<a href="/a">A</a> <a href="/private/x">P</a> <a href="https://external.example/">E</a> <a href="/skip-me">S</a> <a href="/doc.pdf">PDF</a>`,
+		"/a":            `<a href="/b#section">B</a> <a href="/">Home</a>`,
+		"/b":            `<a href="/c">C</a>`,
+		"/c":            `deep page`,
+		"/private/x":    `secret`,
+		"/skip-me":      `skipped`,
+		"/from-sitemap": `only in sitemap`,
+	}
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow: /private/\n\nSitemap: %s/sitemap.xml\n", server.URL)
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/from-sitemap</loc></url></urlset>`, server.URL)
+	})
+	mux.HandleFunc("/doc.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", r.URL.Path, body)
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func crawlPaths(t *testing.T, server *httptest.Server, opts CrawlOptions) ([]string, *CrawlSummary) {
+	f := newLoopbackFetcher(t)
+	var paths []string
+	summary, err := f.Crawl(context.Background(), server.URL+"/", opts, func(page CrawlPage) error {
+		if page.Err != nil {
+			t.Errorf("Unexpected fetch error for %s: %v", page.URL, page.Err)
+			return nil
+		}
+		paths = append(paths, strings.TrimPrefix(page.URL, server.URL))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	sort.Strings(paths)
+	return paths, summary
+}
+
+func TestCrawlFollowsSameOriginLinks(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	paths, summary := crawlPaths(t, server, CrawlOptions{
+		MaxDepth: 2,
+		MaxPages: 20,
+		Exclude:  []string{`/skip-me$`},
+	})
+
+	expected := []string{"/", "/a", "/b", "/from-sitemap"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected pages %v, got %v", expected, paths)
+	}
+	if summary.Disallowed != 1 {
+		t.Errorf("Expected 1 URL disallowed by robots.txt, got %d", summary.Disallowed)
+	}
+	if summary.Filtered != 1 {
+		t.Errorf("Expected 1 URL filtered by exclude pattern, got %d", summary.Filtered)
+	}
+}
+
+func TestCrawlRespectsPageLimit(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	paths, _ := crawlPaths(t, server, CrawlOptions{MaxDepth: 5, MaxPages: 2})
+	if len(paths) != 2 {
+		t.Errorf("Expected 2 pages, got %v", paths)
+	}
+}
+
+func TestCrawlIncludePatterns(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	paths, _ := crawlPaths(t, server, CrawlOptions{
+		MaxDepth: 3,
+		MaxPages: 20,
+		Include:  []string{`/(a|b)$`},
+	})
+
+	expected := []string{"/", "/a", "/b"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected pages %v, got %v", expected, paths)
+	}
+}
+
+func TestCrawlPolitenessDelay(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	start := time.Now()
+	crawlPaths(t, server, CrawlOptions{MaxDepth: 1, MaxPages: 3, Delay: 50 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected at least 2 delays between 3 pages, took %v", elapsed)
+	}
+}
+
+func TestCrawlDelaysRobotsAndSitemapRequests(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nCrawl-delay: 0.05\n\nSitemap: %s/sitemap-index.xml\n", server.URL)
+	})
+	mux.HandleFunc("/sitemap-index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>%s/sitemap.xml</loc></sitemap></sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/a</loc></url></urlset>`, server.URL)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+	})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	paths, _ := crawlPaths(t, server, CrawlOptions{MaxDepth: 1, MaxPages: 10})
+	if strings.Join(paths, ",") != "/,/a" {
+		t.Errorf("Expected the start page and the sitemap page, got %v", paths)
+	}
+
+	// robots.txt, two sitemap files and two pages, each spaced by the delay
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 5 {
+		t.Fatalf("Expected 5 requests, got %d", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Expected request %d to wait for the crawl delay, came after %v", i, gap)
+		}
+	}
+}
+
+func TestCrawlStartDisallowed(t *testing.T) {
+	server := newTestSite(t)
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	_, err := f.Crawl(context.Background(), server.URL+"/private/x", CrawlOptions{MaxPages: 5}, func(CrawlPage) error { return nil })
+	if err == nil {
+		t.Error("Expected error when start URL is disallowed by robots.txt")
+	}
+}
+
+func TestCrawlSkipsOffOriginRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>another site</body></html>")
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/out", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a href="/out">Out</a></body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	paths, summary := crawlPaths(t, server, CrawlOptions{MaxDepth: 1, MaxPages: 10})
+	if strings.Join(paths, ",") != "/" {
+		t.Errorf("Expected only the start page to be visited, got %v", paths)
+	}
+	if summary.Filtered != 1 || summary.Fetched != 1 {
+		t.Errorf("Expected the off-origin redirect to be filtered, got %+v", summary)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	robots := parseRobots(`
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /admin
+Allow: /admin/public
+Disallow: /*.json$
+Crawl-delay: 2
+`, userAgent)
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/admin/users", false},
+		{"/admin/public/page", true},
+		{"/data/file.json", false},
+		{"/data/file.json?x=1", true},
+	}
+	for _, tt := range tests {
+		if got := robots.allowed(tt.path); got != tt.allowed {
+			t.Errorf("allowed(%q) = %v, expected %v", tt.path, got, tt.allowed)
+		}
+	}
+	if robots.crawlDelay != 2*time.Second {
+		t.Errorf("Expected crawl delay 2s, got %v", robots.crawlDelay)
+	}
+}
+
+func TestParseRobotsMatchesProductToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		allowed bool
+	}{
+		{"exact token", "User-agent: *\nAllow: /\n\nUser-agent: MCP-DocSearch\nDisallow: /\n", false},
+		{"case-insensitive", "User-agent: *\nAllow: /\n\nUser-agent: mcp-docsearch\nDisallow: /\n", false},
+		{"prefix of the token", "User-agent: *\nAllow: /\n\nUser-agent: MCP\nDisallow: /\n", true},
+		{"other agent", "User-agent: *\nDisallow: /\n\nUser-agent: DocSearchBot\nAllow: /\n", false},
+	}
+	for _, tt := range tests {
+		if got := parseRobots(tt.content, userAgent).allowed("/page"); got != tt.allowed {
+			t.Errorf("%s: allowed = %v, expected %v", tt.name, got, tt.allowed)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,31 +11,137 @@ import (
 	"time"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
+// userAgent identifies the fetcher to servers and robots.txt
+const userAgent = "MCP-DocSearch/1.0"
+
+// DefaultMaxBodySize is the default limit on response bodies (10 MiB)
+const DefaultMaxBodySize = 10 << 20
+
+// ErrUnsupportedContentType is returned when a URL does not serve text
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// ErrResponseTooLarge is returned when a response body exceeds the size limit
+var ErrResponseTooLarge = errors.New("response too large")
+
 // FetchResult contains the fetched content and metadata
 type FetchResult struct {
 	Content string
 	Title   string
 	Size    int
+	// URL is the final URL after redirects
+	URL string
+	// Links holds absolute URLs of anchors found in HTML pages
+	Links []string
+	// Validators returned by the server for conditional re-fetching
+	ETag         string
+	LastModified string
+	// NotModified is set when a conditional request returned 304; no
+	// content is included in that case
+	NotModified bool
+}
+
+// Validators are cache validators from a previous fetch, sent as
+// If-None-Match and If-Modified-Since
+type Validators struct {
+	ETag         string
+	LastModified string
 }
 
 // Fetcher handles fetching and parsing URL content
 type Fetcher struct {
-	httpClient *http.Client
+	httpClient  *http.Client
+	extractMode string
+	maxBodySize int64
+	auth        *authenticator
+	guard       *guard
 }
 
-// NewFetcher creates a new fetcher with a 30-second timeout
+// NewFetcher creates a new fetcher with a 30-second timeout that extracts
+// the main content of HTML pages and refuses to connect to private,
+// loopback and link-local addresses
 func NewFetcher() *Fetcher {
-	return &Fetcher{
+	f := &Fetcher{
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		extractMode: ExtractModeReadability,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	f.httpClient.CheckRedirect = f.checkRedirect
+
+	// The zero policy has no CIDRs to parse and cannot fail
+	g, _ := newGuard(NetworkPolicy{})
+	f.setGuard(g)
+	return f
+}
+
+// checkRedirect validates each redirect target against the network policy
+// and re-applies per-host credentials for it
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if err := f.guard.checkURL(req.URL); err != nil {
+		return err
+	}
+	f.auth.apply(req)
+	return nil
+}
+
+// newRequest creates a GET request with the user agent and any credentials
+// configured for the URL's host, after checking the URL against the network
+// policy
+func (f *Fetcher) newRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := f.guard.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	f.auth.apply(req)
+	return req, nil
+}
+
+// SetExtractMode selects how text is extracted from HTML pages:
+// ExtractModeReadability (default) or ExtractModeRaw
+func (f *Fetcher) SetExtractMode(mode string) error {
+	switch mode {
+	case ExtractModeReadability, ExtractModeRaw:
+		f.extractMode = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown extract mode: %s (must be %s or %s)", mode, ExtractModeReadability, ExtractModeRaw)
+	}
+}
+
+// SetMaxBodySize sets the largest response body, in bytes, the fetcher reads
+func (f *Fetcher) SetMaxBodySize(limit int64) error {
+	if limit <= 0 {
+		return fmt.Errorf("max body size must be positive, got %d", limit)
 	}
+	f.maxBodySize = limit
+	return nil
 }
 
-// FetchURL fetches content from a URL and extracts text
+// FetchURL fetches content from a URL; HTML pages are converted to Markdown
 func (f *Fetcher) FetchURL(ctx context.Context, urlStr string) (*FetchResult, error) {
+	return f.FetchURLConditional(ctx, urlStr, Validators{})
+}
+
+// FetchURLConditional fetches a URL like FetchURL, sending the given
+// validators so an unchanged resource yields a NotModified result
+func (f *Fetcher) FetchURLConditional(ctx context.Context, urlStr string, validators Validators) (*FetchResult, error) {
+	result, err := f.fetchURL(ctx, urlStr, validators)
+	return result, f.auth.redactError(err)
+}
+
+// fetchURL implements FetchURLConditional
+func (f *Fetcher) fetchURL(ctx context.Context, urlStr string, validators Validators) (*FetchResult, error) {
 	// Validate URL format
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -45,14 +152,19 @@ func (f *Fetcher) FetchURL(ctx context.Context, urlStr string) (*FetchResult, er
 		return nil, fmt.Errorf("unsupported URL scheme: %s (must be http or https)", parsedURL.Scheme)
 	}
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	// Create request with user agent and credentials
+	req, err := f.newRequest(ctx, urlStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	// Add user agent
-	req.Header.Set("User-Agent", "MCP-DocSearch/1.0")
+	// Add conditional request headers
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 
 	// Execute request
 	resp, err := f.httpClient.Do(req)
@@ -61,6 +173,15 @@ func (f *Fetcher) FetchURL(ctx context.Context, urlStr string) (*FetchResult, er
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return &FetchResult{
+			URL:          resp.Request.URL.String(),
+			ETag:         firstNonEmpty(resp.Header.Get("ETag"), validators.ETag),
+			LastModified: firstNonEmpty(resp.Header.Get("Last-Modified"), validators.LastModified),
+			NotModified:  true,
+		}, nil
+	}
+
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
@@ -69,22 +190,28 @@ func (f *Fetcher) FetchURL(ctx context.Context, urlStr string) (*FetchResult, er
 	// Check content type
 	contentType := resp.Header.Get("Content-Type")
 	if !isTextContent(contentType) {
-		return nil, fmt.Errorf("unsupported content type: %s (must be text/plain, text/html, or text/markdown)", contentType)
+		return nil, fmt.Errorf("%w: %s (must be text/plain, text/html, or text/markdown)", ErrUnsupportedContentType, contentType)
 	}
 
-	// Read body
-	body, err := io.ReadAll(resp.Body)
+	// Read body up to the size limit and convert it to UTF-8
+	body, err := readBody(resp, f.maxBodySize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
-	content := string(body)
+	content, err := decodeBody(body, contentType)
+	if err != nil {
+		return nil, err
+	}
 	title := ""
+	var links []string
 
 	// Extract text from HTML
 	if strings.Contains(contentType, "text/html") {
+		links = extractLinks(content, resp.Request.URL)
+
 		var extractErr error
-		content, title, extractErr = extractHTMLText(content)
+		content, title, extractErr = extractMarkdown(content, resp.Request.URL, f.extractMode != ExtractModeRaw)
 		if extractErr != nil {
 			return nil, fmt.Errorf("failed to extract HTML text: %w", extractErr)
 		}
@@ -94,9 +221,80 @@ func (f *Fetcher) FetchURL(ctx context.Context, urlStr string) (*FetchResult, er
 		Content: content,
 		Title:   title,
 		Size:    len(content),
+		URL:     resp.Request.URL.String(),
+		Links:   links,
+
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}, nil
 }
 
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fetchRaw fetches a URL and returns the response status and body, read up
+// to limit bytes, without any content type checks or extraction
+func (f *Fetcher) fetchRaw(ctx context.Context, urlStr string, limit int64) (int, []byte, error) {
+	req, err := f.newRequest(ctx, urlStr)
+	if err != nil {
+		return 0, nil, f.auth.redactError(err)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, f.auth.redactError(fmt.Errorf("failed to fetch URL: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(resp, limit)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// readBody reads a response body, failing with ErrResponseTooLarge as soon
+// as the declared or actual size exceeds limit
+func readBody(resp *http.Response, limit int64) ([]byte, error) {
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %s declares %d bytes, limit is %d bytes", ErrResponseTooLarge, resp.Request.URL, resp.ContentLength, limit)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: %s exceeds the %d byte limit", ErrResponseTooLarge, resp.Request.URL, limit)
+	}
+
+	return body, nil
+}
+
+// decodeBody converts a text body to UTF-8, detecting the encoding from a
+// byte order mark, the Content-Type charset or an HTML <meta charset>
+func decodeBody(body []byte, contentType string) (string, error) {
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		// Drop a UTF-8 byte order mark so it does not end up in the text
+		return strings.TrimPrefix(string(body), "\uFEFF"), nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s content: %w", name, err)
+	}
+	return string(decoded), nil
+}
+
 // isTextContent checks if the content type is text-based
 func isTextContent(contentType string) bool {
 	contentType = strings.ToLower(contentType)
@@ -106,7 +304,8 @@ func isTextContent(contentType string) bool {
 		strings.Contains(contentType, "text/")
 }
 
-// extractHTMLText extracts text content and title from HTML
+// extractHTMLText extracts flat text content and title from HTML. It is the
+// fallback when Markdown conversion produces nothing.
 func extractHTMLText(htmlContent string) (text string, title string, err error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
@@ -157,6 +356,55 @@ func extractHTMLText(htmlContent string) (text string, title string, err error)
 	return extractedText, titleText, nil
 }
 
+// extractLinks returns the absolute http(s) URLs of all anchors in an HTML
+// document, resolved against base (or a <base href> if present), without fragments
+func extractLinks(htmlContent string, base *url.URL) []string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	var hrefs []string
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "a" || n.Data == "base") {
+			for _, attr := range n.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				if n.Data == "base" {
+					if baseURL, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+						base = baseURL
+					}
+				} else {
+					hrefs = append(hrefs, strings.TrimSpace(attr.Val))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	seen := make(map[string]bool)
+	var links []string
+	for _, href := range hrefs {
+		link, err := base.Parse(href)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			continue
+		}
+		link.Fragment = ""
+		link.RawFragment = ""
+		if s := link.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, s)
+		}
+	}
+
+	return links
+}
+
 // normalizeWhitespace replaces multiple spaces with single space
 func normalizeWhitespace(text string) string {
 	// Split by whitespace and rejoin with single spaces
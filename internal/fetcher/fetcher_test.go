@@ -2,6 +2,10 @@ package fetcher
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -111,3 +115,116 @@ func TestFetcherInvalidURL(t *testing.T) {
 		t.Error("Expected error for unsupported scheme")
 	}
 }
+
+func TestFetchURLConditional(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 15 Jan 2024 10:00:00 GMT")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	ctx := context.Background()
+
+	first, err := f.FetchURL(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+	if first.NotModified || first.Content != "hello" {
+		t.Fatalf("Expected full response, got %+v", first)
+	}
+	if first.ETag != `"v1"` || first.LastModified == "" {
+		t.Errorf("Expected validators to be captured, got etag=%q last_modified=%q", first.ETag, first.LastModified)
+	}
+
+	second, err := f.FetchURLConditional(ctx, server.URL, Validators{ETag: first.ETag, LastModified: first.LastModified})
+	if err != nil {
+		t.Fatalf("FetchURLConditional failed: %v", err)
+	}
+	if !second.NotModified {
+		t.Error("Expected 304 to be reported as NotModified")
+	}
+	if second.ETag != `"v1"` {
+		t.Errorf("Expected ETag to be preserved on 304, got %q", second.ETag)
+	}
+}
+
+func TestFetchURLBodyLimit(t *testing.T) {
+	body := strings.Repeat("a", 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/chunked" {
+			// Flushing before writing forces chunked encoding without Content-Length
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	if err := f.SetMaxBodySize(1024); err != nil {
+		t.Fatalf("SetMaxBodySize failed: %v", err)
+	}
+
+	for _, path := range []string{"/", "/chunked"} {
+		_, err := f.FetchURL(context.Background(), server.URL+path)
+		if !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("Expected ErrResponseTooLarge for %s, got %v", path, err)
+		}
+	}
+
+	if err := f.SetMaxBodySize(4096); err != nil {
+		t.Fatalf("SetMaxBodySize failed: %v", err)
+	}
+	if _, err := f.FetchURL(context.Background(), server.URL+"/chunked"); err != nil {
+		t.Errorf("Expected body under the limit to succeed, got %v", err)
+	}
+
+	if err := f.SetMaxBodySize(0); err == nil {
+		t.Error("Expected error for non-positive limit")
+	}
+}
+
+func TestFetchURLTranscodesCharset(t *testing.T) {
+	// "café – naïve" in windows-1252
+	latin := []byte("caf\xe9 \x96 na\xefve")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Set("Content-Type", "text/plain; charset=windows-1252")
+			w.Write(latin)
+		case "/meta":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta charset="iso-8859-1"><title>T</title></head><body><p>caf` + "\xe9" + `</p></body></html>`))
+		case "/bom":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("\xef\xbb\xbfhello"))
+		}
+	}))
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/header", "café – naïve"},
+		{"/meta", "café"},
+		{"/bom", "hello"},
+	}
+	for _, tt := range tests {
+		result, err := f.FetchURL(context.Background(), server.URL+tt.path)
+		if err != nil {
+			t.Fatalf("FetchURL %s failed: %v", tt.path, err)
+		}
+		if !strings.Contains(result.Content, tt.expected) || strings.HasPrefix(result.Content, "\uFEFF") {
+			t.Errorf("Expected %s content to contain %q, got %q", tt.path, tt.expected, result.Content)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrBlockedURL is returned when a URL or the address it resolves to is
+// not allowed by the network policy
+var ErrBlockedURL = errors.New("blocked by network policy")
+
+// NetworkPolicy controls which hosts and addresses the fetcher may contact.
+// The zero value blocks private, loopback and link-local addresses and
+// allows every public host.
+type NetworkPolicy struct {
+	// AllowPrivateNetworks disables address checks entirely
+	AllowPrivateNetworks bool
+	// AllowedNetworks are CIDR ranges exempt from address checks, e.g. the
+	// subnet of an internal wiki
+	AllowedNetworks []string
+	// AllowedHosts, when non-empty, are the only hosts that may be fetched.
+	// Patterns use the same syntax as auth hosts: host, host:port or *.domain.
+	AllowedHosts []string
+	// DeniedHosts are never fetched
+	DeniedHosts []string
+}
+
+// blockedPrefixes are non-public ranges not covered by netip's helpers
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, incl. broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may map to private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),       // 6to4, may embed private IPv4
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+}
+
+// isBlockedIP reports whether an address is private, loopback, link-local
+// or otherwise not publicly routable
+func isBlockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guard enforces a NetworkPolicy on URLs and dialed addresses
+type guard struct {
+	policy      NetworkPolicy
+	allowedNets []netip.Prefix
+	resolver    *net.Resolver
+	dialer      *net.Dialer
+}
+
+// newGuard validates a policy and creates its guard
+func newGuard(policy NetworkPolicy) (*guard, error) {
+	g := &guard{
+		policy:   policy,
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	for _, cidr := range policy.AllowedNetworks {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q: %w", cidr, err)
+		}
+		g.allowedNets = append(g.allowedNets, prefix.Masked())
+	}
+	g.policy.AllowedHosts = normalizeHostPatterns(policy.AllowedHosts)
+	g.policy.DeniedHosts = normalizeHostPatterns(policy.DeniedHosts)
+	return g, nil
+}
+
+// normalizeHostPatterns lower-cases patterns and drops empty ones
+func normalizeHostPatterns(patterns []string) []string {
+	var normalized []string
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			normalized = append(normalized, pattern)
+		}
+	}
+	return normalized
+}
+
+// checkURL validates the scheme and host of a URL against the host lists
+func (g *guard) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported URL scheme %s", ErrBlockedURL, u.Scheme)
+	}
+
+	hostport := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+	if hostname == "" {
+		return fmt.Errorf("%w: URL has no host", ErrBlockedURL)
+	}
+
+	for _, pattern := range g.policy.DeniedHosts {
+		if matchHostPattern(pattern, hostport, hostname) {
+			return fmt.Errorf("%w: host %s is denied", ErrBlockedURL, hostname)
+		}
+	}
+	if len(g.policy.AllowedHosts) == 0 {
+		return nil
+	}
+	for _, pattern := range g.policy.AllowedHosts {
+		if matchHostPattern(pattern, hostport, hostname) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s is not in the allowed hosts", ErrBlockedURL, hostname)
+}
+
+// checkIP validates a resolved address
+func (g *guard) checkIP(host string, ip netip.Addr) error {
+	if g.policy.AllowPrivateNetworks {
+		return nil
+	}
+	ip = ip.Unmap()
+	for _, prefix := range g.allowedNets {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	if isBlockedIP(ip) {
+		return fmt.Errorf("%w: %s resolves to non-public address %s", ErrBlockedURL, host, ip)
+	}
+	return nil
+}
+
+// dialContext resolves the host itself and only dials addresses that pass
+// checkIP, so DNS answers cannot change between the check and the connection
+func (g *guard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		if err := g.checkIP(host, ip); err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// transport returns an HTTP transport that dials through the guard.
+// Environment proxies are only honoured when address checks are disabled,
+// since a proxy would connect to the target on the fetcher's behalf.
+func (g *guard) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = g.dialContext
+	if !g.policy.AllowPrivateNetworks {
+		t.Proxy = nil
+	}
+	return t
+}
+
+// SetNetworkPolicy replaces the fetcher's network policy
+func (f *Fetcher) SetNetworkPolicy(policy NetworkPolicy) error {
+	g, err := newGuard(policy)
+	if err != nil {
+		return err
+	}
+	f.setGuard(g)
+	return nil
+}
+
+// setGuard installs a guard and its transport
+func (f *Fetcher) setGuard(g *guard) {
+	f.guard = g
+	f.httpClient.Transport = g.transport()
+}
@@ -0,0 +1,154 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// newLoopbackFetcher returns a fetcher allowed to reach httptest servers
+func newLoopbackFetcher(t *testing.T) *Fetcher {
+	t.Helper()
+	f := NewFetcher()
+	if err := f.SetNetworkPolicy(NetworkPolicy{AllowedNetworks: []string{"127.0.0.0/8", "::1/128"}}); err != nil {
+		t.Fatalf("SetNetworkPolicy failed: %v", err)
+	}
+	return f
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700:4700::1111", false},
+	}
+
+	for _, tt := range tests {
+		if got := isBlockedIP(netip.MustParseAddr(tt.ip)); got != tt.blocked {
+			t.Errorf("isBlockedIP(%s) = %v, expected %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestFetcherBlocksPrivateAddressesByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "internal")
+	}))
+	defer server.Close()
+
+	f := NewFetcher()
+	for _, target := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := f.FetchURL(context.Background(), target)
+		if !errors.Is(err, ErrBlockedURL) {
+			t.Errorf("Expected ErrBlockedURL for %s, got %v", target, err)
+		}
+	}
+
+	if err := f.SetNetworkPolicy(NetworkPolicy{AllowPrivateNetworks: true}); err != nil {
+		t.Fatalf("SetNetworkPolicy failed: %v", err)
+	}
+	if _, err := f.FetchURL(context.Background(), server.URL); err != nil {
+		t.Errorf("Expected fetch to succeed with private networks allowed, got %v", err)
+	}
+}
+
+func TestFetcherHostLists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	host := hostOf(t, server.URL)
+
+	tests := []struct {
+		name    string
+		policy  NetworkPolicy
+		blocked bool
+	}{
+		{"no lists", NetworkPolicy{}, false},
+		{"denied host", NetworkPolicy{DeniedHosts: []string{"127.0.0.1"}}, true},
+		{"allowed host", NetworkPolicy{AllowedHosts: []string{host}}, false},
+		{"not in allowed hosts", NetworkPolicy{AllowedHosts: []string{"docs.example.com"}}, true},
+		{"deny wins over allow", NetworkPolicy{AllowedHosts: []string{host}, DeniedHosts: []string{host}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.AllowedNetworks = []string{"127.0.0.0/8"}
+			f := NewFetcher()
+			if err := f.SetNetworkPolicy(tt.policy); err != nil {
+				t.Fatalf("SetNetworkPolicy failed: %v", err)
+			}
+			_, err := f.FetchURL(context.Background(), server.URL)
+			if blocked := errors.Is(err, ErrBlockedURL); blocked != tt.blocked {
+				t.Errorf("Expected blocked=%v, got err=%v", tt.blocked, err)
+			}
+		})
+	}
+}
+
+func TestFetcherChecksRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "secret")
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer origin.Close()
+
+	// The origin is reachable, but the redirect target's host is denied
+	f := NewFetcher()
+	if err := f.SetNetworkPolicy(NetworkPolicy{AllowedNetworks: []string{"127.0.0.0/8", "::1/128"}, DeniedHosts: []string{"localhost"}}); err != nil {
+		t.Fatalf("SetNetworkPolicy failed: %v", err)
+	}
+	if _, err := f.FetchURL(context.Background(), origin.URL); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Expected redirect to denied host to be blocked, got %v", err)
+	}
+
+	// The redirect target's address is re-checked after resolution
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://127.0.0.2:1/", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	f = NewFetcher()
+	if err := f.SetNetworkPolicy(NetworkPolicy{AllowedNetworks: []string{"127.0.0.1/32"}}); err != nil {
+		t.Fatalf("SetNetworkPolicy failed: %v", err)
+	}
+	if _, err := f.FetchURL(context.Background(), redirector.URL); !errors.Is(err, ErrBlockedURL) {
+		t.Errorf("Expected redirect to blocked address to be blocked, got %v", err)
+	}
+}
+
+func TestSetNetworkPolicyInvalidNetwork(t *testing.T) {
+	f := NewFetcher()
+	if err := f.SetNetworkPolicy(NetworkPolicy{AllowedNetworks: []string{"not-a-cidr"}}); err == nil {
+		t.Error("Expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,443 @@
+package fetcher
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// extractMarkdown converts an HTML document to Markdown and returns it with
+// the page title. When readability is set only the main content is kept.
+// Relative links and images are resolved against base (or <base href>).
+// Documents that render to nothing fall back to flat text extraction.
+func extractMarkdown(htmlContent string, base *url.URL, readability bool) (text string, title string, err error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", "", err
+	}
+
+	title = findTitle(doc)
+	base = documentBase(doc, base)
+
+	body := findElement(doc, "body")
+	if body == nil {
+		body = doc
+	}
+
+	nodes := []*html.Node{body}
+	if readability {
+		nodes = mainContent(body)
+	} else {
+		removeElements(body, "script", "style", "noscript", "template")
+	}
+
+	r := &markdownRenderer{base: base}
+	var parts []string
+	for _, n := range nodes {
+		if md := r.render(n); md != "" {
+			parts = append(parts, md)
+		}
+	}
+
+	if len(parts) == 0 {
+		return extractHTMLText(htmlContent)
+	}
+
+	return strings.Join(parts, "\n\n"), title, nil
+}
+
+// documentBase returns the URL from <base href> resolved against base
+func documentBase(doc *html.Node, base *url.URL) *url.URL {
+	n := findElement(doc, "base")
+	if n == nil || base == nil {
+		return base
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == "href" {
+			if resolved, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+				return resolved
+			}
+		}
+	}
+	return base
+}
+
+// removeElements detaches all descendants with the given tags
+func removeElements(n *html.Node, tags ...string) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.ElementNode {
+			for _, tag := range tags {
+				if c.Data == tag {
+					n.RemoveChild(c)
+					break
+				}
+			}
+			if c.Parent == n {
+				removeElements(c, tags...)
+			}
+		}
+	}
+}
+
+// markdownRenderer converts HTML nodes to Markdown
+type markdownRenderer struct {
+	base *url.URL
+}
+
+var (
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+	spaceRuns   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	languageTag = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#.-]+)`)
+)
+
+// render converts a node and its descendants to Markdown
+func (r *markdownRenderer) render(n *html.Node) string {
+	md := r.blocks(n, "\n\n")
+	md = blankLines.ReplaceAllString(md, "\n\n")
+	return strings.TrimSpace(md)
+}
+
+// blocks renders the children of n as block-level Markdown joined by sep.
+// Runs of inline content between block elements become paragraphs.
+func (r *markdownRenderer) blocks(n *html.Node, sep string) string {
+	var out []string
+	var inline strings.Builder
+
+	flush := func() {
+		if p := cleanInline(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && isBlockElement(c.Data) {
+			flush()
+			if b := r.block(c); b != "" {
+				out = append(out, b)
+			}
+			continue
+		}
+		inline.WriteString(r.inline(c))
+	}
+	flush()
+
+	return strings.Join(out, sep)
+}
+
+// block renders a single block-level element
+func (r *markdownRenderer) block(n *html.Node) string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.ReplaceAll(cleanInline(r.inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case "pre":
+		return r.codeBlock(n)
+	case "ul", "ol":
+		return r.list(n)
+	case "table":
+		return r.table(n)
+	case "blockquote":
+		inner := r.blocks(n, "\n\n")
+		if inner == "" {
+			return ""
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case "hr":
+		return "---"
+	default:
+		return r.blocks(n, "\n\n")
+	}
+}
+
+// codeBlock renders <pre> as a fenced code block, keeping whitespace and
+// picking the language from a language-* class on pre or its code child
+func (r *markdownRenderer) codeBlock(n *html.Node) string {
+	code := strings.Trim(rawText(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+
+	lang := codeLanguage(n)
+	if lang == "" {
+		if c := findElement(n, "code"); c != nil {
+			lang = codeLanguage(c)
+		}
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// codeLanguage returns the language named by a language-* or lang-* class
+func codeLanguage(n *html.Node) string {
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			if m := languageTag.FindStringSubmatch(attr.Val); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// list renders <ul>/<ol>, indenting nested content under each marker
+func (r *markdownRenderer) list(n *html.Node) string {
+	ordered := n.Data == "ol"
+	number := 1
+	if ordered {
+		if start, err := strconv.Atoi(getAttr(n, "start")); err == nil {
+			number = start
+		}
+	}
+
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		content := r.blocks(c, "\n")
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(content, "\n")
+		for i := range lines {
+			if i == 0 {
+				lines[i] = marker + lines[i]
+			} else if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, strings.TrimRight(strings.Join(lines, "\n"), " "))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as a GitHub-flavoured Markdown table, using the
+// first row as the header
+func (r *markdownRenderer) table(n *html.Node) string {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			case "tr":
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						text := spaceRuns.ReplaceAllString(cleanInline(r.inlineChildren(cell)), " ")
+						cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	collect(n)
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// inline renders a node as inline Markdown
+func (r *markdownRenderer) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaceRuns.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "br":
+		return "\n"
+	case "strong", "b":
+		return wrapInline(r.inlineChildren(n), "**")
+	case "em", "i":
+		return wrapInline(r.inlineChildren(n), "*")
+	case "code", "kbd", "samp":
+		return inlineCode(rawText(n))
+	case "a":
+		text := strings.TrimSpace(r.inlineChildren(n))
+		href := r.resolve(getAttr(n, "href"))
+		if text == "" || href == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		src := r.resolve(getAttr(n, "src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + getAttr(n, "alt") + "](" + src + ")"
+	default:
+		if isBlockElement(n.Data) {
+			return " " + r.inlineChildren(n) + " "
+		}
+		return r.inlineChildren(n)
+	}
+}
+
+// inlineChildren renders the children of n as inline Markdown
+func (r *markdownRenderer) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(r.inline(c))
+	}
+	return b.String()
+}
+
+// resolve makes an http(s) link absolute; other schemes and fragments-only
+// links are dropped
+func (r *markdownRenderer) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if r.base != nil {
+		u = r.base.ResolveReference(u)
+	}
+	if u.IsAbs() && u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto" {
+		return ""
+	}
+	return u.String()
+}
+
+// wrapInline surrounds text with a marker, keeping surrounding spaces outside
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// inlineCode wraps code in enough backticks to contain any it holds
+func inlineCode(code string) string {
+	code = strings.TrimSpace(spaceRuns.ReplaceAllString(code, " "))
+	if code == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return fence + " " + code + " " + fence
+	}
+	return fence + code + fence
+}
+
+// cleanInline trims each line of inline content and drops empty lines
+func cleanInline(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawText returns the text of a node and its descendants unmodified
+func rawText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(rawText(c))
+	}
+	return b.String()
+}
+
+// getAttr returns the value of an attribute, or "" when absent
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// isBlockElement reports whether a tag starts a new Markdown block
+func isBlockElement(tag string) bool {
+	switch tag {
+	case "address", "article", "aside", "blockquote", "body", "dd", "details",
+		"div", "dl", "dt", "fieldset", "figcaption", "figure", "footer", "form",
+		"h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "nav",
+		"ol", "p", "pre", "section", "summary", "table", "ul", "html":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package fetcher
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/guide/")
+
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "headings and paragraphs",
+			html:     `<h1>Title</h1><p>First   paragraph.</p><h2>Section</h2><p>Second <strong>bold</strong> and <em>italic</em>.</p>`,
+			expected: "# Title\n\nFirst paragraph.\n\n## Section\n\nSecond **bold** and *italic*.",
+		},
+		{
+			name:     "code block keeps whitespace and language",
+			html:     "<p>Run:</p><pre><code class=\"language-go\">func main() {\n\tfmt.Println(\"hi\")\n}\n</code></pre>",
+			expected: "Run:\n\n```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```",
+		},
+		{
+			name:     "inline code",
+			html:     `<p>Call <code>Fetch()</code> first.</p>`,
+			expected: "Call `Fetch()` first.",
+		},
+		{
+			name:     "nested lists",
+			html:     `<ul><li>One<ul><li>Nested</li></ul></li><li>Two</li></ul><ol start="3"><li>Three</li><li>Four</li></ol>`,
+			expected: "- One\n  - Nested\n- Two\n\n3. Three\n4. Four",
+		},
+		{
+			name:     "table",
+			html:     `<table><thead><tr><th>Name</th><th>Value</th></tr></thead><tbody><tr><td>a|b</td><td>1</td></tr><tr><td>c</td></tr></tbody></table>`,
+			expected: "| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n| c |  |",
+		},
+		{
+			name:     "relative links and images are resolved",
+			html:     `<p>See <a href="../api">the API</a>, <a href="#top">top</a> and <img src="/img/x.png" alt="diagram"></p>`,
+			expected: "See [the API](https://example.com/docs/api), top and ![diagram](https://example.com/img/x.png)",
+		},
+		{
+			name:     "blockquote",
+			html:     `<blockquote><p>Quoted</p><p>Text</p></blockquote>`,
+			expected: "> Quoted\n>\n> Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _, err := extractMarkdown("<html><body>"+tt.html+"</body></html>", base, false)
+			if err != nil {
+				t.Fatalf("extractMarkdown failed: %v", err)
+			}
+			if text != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestExtractMarkdownBaseHref(t *testing.T) {
+	base, _ := url.Parse("https://example.com/page")
+	html := `<html><head><base href="https://cdn.example.com/root/"></head><body><p><a href="doc">Doc</a></p></body></html>`
+
+	text, _, err := extractMarkdown(html, base, false)
+	if err != nil {
+		t.Fatalf("extractMarkdown failed: %v", err)
+	}
+	if !strings.Contains(text, "(https://cdn.example.com/root/doc)") {
+		t.Errorf("Expected link resolved against <base href>, got %q", text)
+	}
+}
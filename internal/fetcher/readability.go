@@ -0,0 +1,312 @@
+package fetcher
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTML extraction modes
+const (
+	// ExtractModeReadability keeps only the main article content
+	ExtractModeReadability = "readability"
+	// ExtractModeRaw keeps the whole page except scripts and styles
+	ExtractModeRaw = "raw"
+)
+
+// boilerplateTags are removed before scoring
+var boilerplateTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"nav": true, "header": true, "footer": true, "aside": true,
+	"form": true, "button": true, "iframe": true, "svg": true, "select": true,
+}
+
+var (
+	// unlikelyPattern matches class/id values of navigation, ads and banners
+	unlikelyPattern = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|consent|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|pager|pagination|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|toolbar|ad-break|agegate|advert`)
+	// maybeCandidatePattern rescues elements that also look like content
+	maybeCandidatePattern = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positivePattern       = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story|docs?|markdown`)
+	negativePattern       = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// minParagraphLength is the shortest text block that contributes to scoring
+const minParagraphLength = 25
+
+// mainContent strips navigation, headers, footers, sidebars and similar
+// boilerplate from body and returns the nodes holding the main article.
+// Pages without a clear main content block yield the cleaned body.
+func mainContent(body *html.Node) []*html.Node {
+	removeBoilerplate(body)
+	if main := selectMainContent(body); main != nil {
+		return main
+	}
+	return []*html.Node{body}
+}
+
+// findTitle returns the trimmed text of the first <title> element
+func findTitle(doc *html.Node) string {
+	if n := findElement(doc, "title"); n != nil {
+		return normalizeWhitespace(textContent(n))
+	}
+	return ""
+}
+
+// findElement returns the first element with the given tag, depth first
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// removeBoilerplate detaches boilerplate elements and elements whose class
+// or id marks them as unlikely to be content
+func removeBoilerplate(n *html.Node) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+			continue
+		}
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if boilerplateTags[c.Data] || isHidden(c) || isUnlikelyCandidate(c) {
+			n.RemoveChild(c)
+			continue
+		}
+		removeBoilerplate(c)
+	}
+}
+
+// isHidden reports whether an element is hidden from readers
+func isHidden(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if attr.Val == "true" {
+				return true
+			}
+		case "style":
+			style := strings.ReplaceAll(strings.ToLower(attr.Val), " ", "")
+			if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+				return true
+			}
+		case "role":
+			if attr.Val == "navigation" || attr.Val == "banner" || attr.Val == "contentinfo" || attr.Val == "complementary" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isUnlikelyCandidate reports whether an element's class/id suggests
+// boilerplate. Structural content elements are never treated as unlikely.
+func isUnlikelyCandidate(n *html.Node) bool {
+	switch n.Data {
+	case "body", "article", "main", "a", "table", "tbody", "tr", "td", "th", "pre", "code":
+		return false
+	}
+	match := classAndID(n)
+	return match != "" && unlikelyPattern.MatchString(match) && !maybeCandidatePattern.MatchString(match)
+}
+
+// classAndID returns an element's class and id joined by a space
+func classAndID(n *html.Node) string {
+	var class, id string
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "class":
+			class = attr.Val
+		case "id":
+			id = attr.Val
+		}
+	}
+	return strings.TrimSpace(class + " " + id)
+}
+
+// selectMainContent scores block containers by the paragraphs they hold and
+// returns the best candidate plus any similarly scored siblings. It returns
+// nil when no element holds enough text to score.
+func selectMainContent(body *html.Node) []*html.Node {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+
+	initialize := func(n *html.Node) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		scores[n] = tagWeight(n) + classWeight(n)
+		order = append(order, n)
+	}
+
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && isScoredBlock(n) {
+			text := normalizeWhitespace(textContent(n))
+			if len(text) >= minParagraphLength && n.Parent != nil {
+				score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+
+				parent := n.Parent
+				initialize(parent)
+				scores[parent] += score
+
+				if grand := parent.Parent; grand != nil && grand.Type == html.ElementNode {
+					initialize(grand)
+					scores[grand] += score / 2
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(body)
+
+	if len(order) == 0 {
+		return nil
+	}
+
+	// Scale by link density so link lists lose to prose
+	var top *html.Node
+	for _, n := range order {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+
+	// Include siblings that look like part of the same article
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+	threshold := math.Max(10, scores[top]*0.2)
+	var selected []*html.Node
+	for c := top.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c == top {
+			selected = append(selected, c)
+			continue
+		}
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if score, ok := scores[c]; ok && score >= threshold {
+			selected = append(selected, c)
+			continue
+		}
+		if c.Data == "p" {
+			text := normalizeWhitespace(textContent(c))
+			if len(text) > 80 && linkDensity(c) < 0.25 {
+				selected = append(selected, c)
+			}
+		}
+	}
+
+	return selected
+}
+
+// isScoredBlock reports whether an element is a text block used for scoring
+func isScoredBlock(n *html.Node) bool {
+	switch n.Data {
+	case "p", "pre", "td", "blockquote", "li", "dd", "h2", "h3", "section":
+		return !hasBlockChild(n)
+	}
+	return false
+}
+
+// hasBlockChild reports whether an element directly contains paragraphs or
+// other scored blocks, in which case its children are scored instead
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			switch c.Data {
+			case "p", "pre", "div", "blockquote", "ul", "ol", "table", "section":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagWeight is the initial score for a candidate based on its tag
+func tagWeight(n *html.Node) float64 {
+	switch n.Data {
+	case "article", "main":
+		return 10
+	case "div", "section":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "ol", "ul", "dl", "dd", "dt", "li":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// classWeight adjusts a candidate's score based on its class and id
+func classWeight(n *html.Node) float64 {
+	match := classAndID(n)
+	if match == "" {
+		return 0
+	}
+	weight := 0.0
+	if negativePattern.MatchString(match) {
+		weight -= 25
+	}
+	if positivePattern.MatchString(match) {
+		weight += 25
+	}
+	return weight
+}
+
+// textContent returns the concatenated text of a node and its descendants
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// linkDensity is the fraction of an element's text that sits inside links
+func linkDensity(n *html.Node) float64 {
+	total := len(normalizeWhitespace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	linkLen := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linkLen += len(normalizeWhitespace(textContent(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linkLen) / float64(total)
+}
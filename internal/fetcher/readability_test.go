@@ -0,0 +1,115 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const articlePage = `<html><head><title>Guide</title></head><body>
+<header><a href="/">Home</a> <a href="/docs">Docs</a></header>
+<nav class="menu"><ul><li><a href="/a">Getting started with the project</a></li><li><a href="/b">Advanced configuration reference</a></li></ul></nav>
+<div id="cookie-banner">We use cookies to improve your experience, accept them all.</div>
+<div class="layout">
+  <div class="sidebar"><p>Related posts, popular posts, and other things you might like to read.</p></div>
+  <article class="content">
+    <h1>Installing the tool</h1>
+    <p>Install the binary with go install, then make sure your GOPATH bin directory is on your PATH.</p>
+    <p>Configuration lives in environment variables, and every setting has a sensible default value.</p>
+  </article>
+</div>
+<aside>Follow us on social media for updates.</aside>
+<footer>Copyright 2024, Example Corp. All rights reserved.</footer>
+</body></html>`
+
+func TestExtractMainContent(t *testing.T) {
+	text, title, err := extractMarkdown(articlePage, nil, true)
+	if err != nil {
+		t.Fatalf("extractMarkdown failed: %v", err)
+	}
+
+	if title != "Guide" {
+		t.Errorf("Expected title %q, got %q", "Guide", title)
+	}
+
+	for _, want := range []string{"# Installing the tool", "go install", "sensible default value"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected text to contain %q, got %q", want, text)
+		}
+	}
+
+	for _, unwanted := range []string{"Home", "Getting started", "cookies", "Related posts", "social media", "Copyright"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("Expected boilerplate %q to be removed, got %q", unwanted, text)
+		}
+	}
+}
+
+func TestExtractMainContentFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "short body without scored blocks",
+			html:     `<html><head><title>T</title></head><body><nav>Menu</nav><p>Hello World</p></body></html>`,
+			expected: "Hello World",
+		},
+		{
+			name:     "everything is boilerplate",
+			html:     `<html><body><nav>Only navigation</nav></body></html>`,
+			expected: "Only navigation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _, err := extractMarkdown(tt.html, nil, true)
+			if err != nil {
+				t.Fatalf("extractMarkdown failed: %v", err)
+			}
+			if text != tt.expected {
+				t.Errorf("Expected text %q, got %q", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestFetcherExtractMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, articlePage)
+	}))
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	result, err := f.FetchURL(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+	if strings.Contains(result.Content, "Copyright") {
+		t.Errorf("Expected footer to be removed by default, got %q", result.Content)
+	}
+	if len(result.Links) == 0 {
+		t.Error("Expected links to be extracted from the full page")
+	}
+
+	if err := f.SetExtractMode(ExtractModeRaw); err != nil {
+		t.Fatalf("SetExtractMode failed: %v", err)
+	}
+	result, err = f.FetchURL(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("FetchURL failed: %v", err)
+	}
+	if !strings.Contains(result.Content, "Copyright") {
+		t.Errorf("Expected raw mode to keep the footer, got %q", result.Content)
+	}
+
+	if err := f.SetExtractMode("bogus"); err == nil {
+		t.Error("Expected error for unknown extract mode")
+	}
+}
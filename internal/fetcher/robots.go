@@ -0,0 +1,154 @@
+package fetcher
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules holds the robots.txt rules that apply to this fetcher
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+// robotsRule is a single Allow or Disallow path pattern
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// parseRobots parses robots.txt content, keeping the group for our product
+// token if present and otherwise the "*" group
+func parseRobots(content, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+	if i := strings.Index(agent, "/"); i >= 0 {
+		agent = agent[:i]
+	}
+
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inAgentLines := false
+	result := &robotsRules{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgentLines || current == nil {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgentLines = true
+		case "allow", "disallow":
+			inAgentLines = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+		case "crawl-delay":
+			inAgentLines = false
+			if current == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			result.sitemaps = append(result.sitemaps, value)
+		}
+	}
+
+	// Prefer a group naming our product token, compared case-insensitively,
+	// falling back to the wildcard group
+	var wildcard, specific *group
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" && wildcard == nil {
+				wildcard = g
+			} else if a == agent && specific == nil {
+				specific = g
+			}
+		}
+	}
+	chosen := specific
+	if chosen == nil {
+		chosen = wildcard
+	}
+	if chosen != nil {
+		result.rules = chosen.rules
+		result.crawlDelay = chosen.crawlDelay
+	}
+
+	return result
+}
+
+// allowed reports whether a URL path (with query) may be fetched. The
+// longest matching rule wins, with Allow winning ties.
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+
+	bestLen := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > bestLen || (len(rule.pattern) == bestLen && rule.allow) {
+			bestLen = len(rule.pattern)
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern supporting "*" wildcards and
+// a trailing "$" anchor
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+
+	if !anchored {
+		return true
+	}
+	if len(parts) == 1 {
+		return pos == len(path)
+	}
+	// With a wildcard, the final literal segment must end the path
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
@@ -0,0 +1,165 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SitemapEntry is a single <url> entry from a sitemap
+type SitemapEntry struct {
+	Loc     string
+	LastMod string
+}
+
+// lastModLayouts are the W3C datetime forms allowed in <lastmod>
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// LastModified parses the entry's <lastmod>, reporting false when it is
+// missing or malformed
+func (e SitemapEntry) LastModified() (time.Time, bool) {
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, e.LastMod); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc is a <url> or <sitemap> element
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// maxSitemapFiles bounds how many nested sitemap files are followed
+const maxSitemapFiles = 50
+
+// SitemapFailure records a nested sitemap file that could not be read
+type SitemapFailure struct {
+	URL string
+	Err error
+}
+
+// FetchSitemap fetches a sitemap and returns its URL entries, following
+// sitemap index files. Gzip-compressed sitemaps are decompressed. Only a
+// failure to read sitemapURL itself is an error; nested sitemap files that
+// cannot be fetched or parsed are returned as failures and skipped.
+func (f *Fetcher) FetchSitemap(ctx context.Context, sitemapURL string) ([]SitemapEntry, []SitemapFailure, error) {
+	return f.fetchSitemaps(ctx, sitemapURL, nil)
+}
+
+// fetchSitemaps implements FetchSitemap, calling pace (if set) before each
+// sitemap file is requested
+func (f *Fetcher) fetchSitemaps(ctx context.Context, sitemapURL string, pace func(context.Context, string) error) ([]SitemapEntry, []SitemapFailure, error) {
+	var entries []SitemapEntry
+	var failures []SitemapFailure
+	queue := []string{sitemapURL}
+	visited := make(map[string]bool)
+
+	for len(queue) > 0 && len(visited) < maxSitemapFiles {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		var doc *sitemapDocument
+		err := ctx.Err()
+		if pace != nil {
+			err = pace(ctx, current)
+		}
+		if err == nil {
+			doc, err = f.fetchSitemapFile(ctx, current)
+		}
+		if err != nil {
+			if current == sitemapURL {
+				return nil, nil, err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, nil, ctxErr
+			}
+			failures = append(failures, SitemapFailure{URL: current, Err: err})
+			continue
+		}
+
+		for _, u := range doc.URLs {
+			if loc := strings.TrimSpace(u.Loc); loc != "" {
+				entries = append(entries, SitemapEntry{Loc: loc, LastMod: strings.TrimSpace(u.LastMod)})
+			}
+		}
+		for _, sm := range doc.Sitemaps {
+			if loc := strings.TrimSpace(sm.Loc); loc != "" {
+				queue = append(queue, loc)
+			}
+		}
+	}
+
+	return entries, failures, nil
+}
+
+// fetchSitemapFile fetches and parses a single sitemap file
+func (f *Fetcher) fetchSitemapFile(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	status, body, err := f.fetchRaw(ctx, sitemapURL, maxSitemapSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: %w", sitemapURL, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: HTTP %d", sitemapURL, status)
+	}
+
+	doc, err := parseSitemap(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+	}
+	return doc, nil
+}
+
+// maxSitemapSize bounds the downloaded and decompressed size of a single
+// sitemap file
+const maxSitemapSize = 50 << 20
+
+// parseSitemap decodes a sitemap or sitemap index document, which may be gzipped
+func parseSitemap(data []byte) (*sitemapDocument, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip sitemap: %w", err)
+		}
+		defer zr.Close()
+		data, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("unexpected root element <%s>", doc.XMLName.Local)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,125 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchSitemapIndexAndGzip(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/more.xml.gz</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%s/a</loc><lastmod>2024-01-15</lastmod></url>
+</urlset>`, server.URL)
+	})
+	mux.HandleFunc("/more.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		fmt.Fprintf(zw, `<urlset><url><loc>%s/b</loc><lastmod>2024-02-01T10:30:00+00:00</lastmod></url></urlset>`, server.URL)
+		zw.Close()
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(buf.Bytes())
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	entries, failures, err := f.FetchSitemap(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("FetchSitemap failed: %v", err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("Unexpected failures: %+v", failures)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Loc != server.URL+"/a" || entries[1].Loc != server.URL+"/b" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	lastMod, ok := entries[1].LastModified()
+	if !ok || !lastMod.Equal(time.Date(2024, 2, 1, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected lastmod 2024-02-01T10:30:00Z, got %v (ok=%v)", lastMod, ok)
+	}
+}
+
+func TestFetchSitemapSkipsFailedChildren(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/broken.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset><url><loc>`)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%s/a</loc></url></urlset>`, server.URL)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	f := newLoopbackFetcher(t)
+	entries, failures, err := f.FetchSitemap(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Expected failed child sitemaps not to fail the ingestion, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Loc != server.URL+"/a" {
+		t.Errorf("Expected the readable child's entries, got %+v", entries)
+	}
+	if len(failures) != 2 || failures[0].URL != server.URL+"/missing.xml" || failures[1].URL != server.URL+"/broken.xml" {
+		t.Errorf("Expected both failed children to be recorded, got %+v", failures)
+	}
+
+	// The root sitemap failing is still an error
+	if _, _, err := f.FetchSitemap(context.Background(), server.URL+"/missing.xml"); err == nil {
+		t.Error("Expected error when the root sitemap cannot be fetched")
+	}
+}
+
+func TestSitemapEntryLastModified(t *testing.T) {
+	tests := []struct {
+		lastMod string
+		ok      bool
+	}{
+		{"2024-01-15", true},
+		{"2024-01-15T08:00Z", true},
+		{"2024-01-15T08:00:00.123+02:00", true},
+		{"", false},
+		{"yesterday", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := (SitemapEntry{LastMod: tt.lastMod}).LastModified(); ok != tt.ok {
+			t.Errorf("LastModified(%q) ok = %v, expected %v", tt.lastMod, ok, tt.ok)
+		}
+	}
+}
+
+func TestParseSitemapRejectsOtherXML(t *testing.T) {
+	if _, err := parseSitemap([]byte(`<rss></rss>`)); err == nil {
+		t.Error("Expected error for non-sitemap XML")
+	}
+}
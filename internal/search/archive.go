@@ -0,0 +1,154 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cmrigney/mcp-document-search/internal/archive"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// Import modes
+const (
+	// ImportModeMerge keeps existing documents, replacing one only when the
+	// archive holds a newer copy of the same source
+	ImportModeMerge = "merge"
+	// ImportModeReplace removes every existing document before importing
+	ImportModeReplace = "replace"
+)
+
+// ExportResponse summarizes a written archive
+type ExportResponse struct {
+	Documents      int    `json:"documents"`
+	Chunks         int    `json:"chunks"`
+	EmbeddingModel string `json:"embedding_model"`
+	FormatVersion  int    `json:"format_version"`
+}
+
+// ImportRequest represents an import request
+type ImportRequest struct {
+	Mode string
+}
+
+// ImportResponse summarizes an import
+type ImportResponse struct {
+	Mode           string `json:"mode"`
+	EmbeddingModel string `json:"embedding_model"`
+	FormatVersion  int    `json:"format_version"`
+	Documents      int    `json:"documents"`
+	Imported       int    `json:"imported"`
+	Replaced       int    `json:"replaced"`
+	Skipped        int    `json:"skipped"`
+	Removed        int    `json:"removed"`
+	Message        string `json:"message"`
+}
+
+// Export writes every indexed document, with its chunks and embeddings, to
+// w as a compressed archive
+func (s *Service) Export(ctx context.Context, w io.Writer) (*ExportResponse, error) {
+	aw, err := archive.NewWriter(w, s.embeddingClient.Model(), s.embeddingClient.Dimension())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.ExportDocuments(func(doc storage.Document, chunks []storage.Chunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return aw.Add(doc, chunks)
+	})
+	if err != nil {
+		aw.Close()
+		return nil, fmt.Errorf("failed to export documents: %w", err)
+	}
+
+	manifest, err := aw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportResponse{
+		Documents:      manifest.Documents,
+		Chunks:         manifest.Chunks,
+		EmbeddingModel: manifest.EmbeddingModel,
+		FormatVersion:  manifest.Version,
+	}, nil
+}
+
+// Import loads an archive written by Export. The archive must have been
+// built with the same embedding model, since vectors from different models
+// are not comparable. The import is all or nothing.
+func (s *Service) Import(ctx context.Context, r io.Reader, req ImportRequest) (*ImportResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = ImportModeMerge
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return nil, fmt.Errorf("invalid import mode %q (use %s or %s)", mode, ImportModeMerge, ImportModeReplace)
+	}
+
+	ar, err := archive.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+
+	manifest := ar.Manifest()
+	if manifest.EmbeddingModel != s.embeddingClient.Model() || manifest.EmbeddingDimension != s.embeddingClient.Dimension() {
+		return nil, fmt.Errorf("archive embeddings use %s (%d dimensions) but this index uses %s (%d dimensions); reindex the sources instead",
+			manifest.EmbeddingModel, manifest.EmbeddingDimension, s.embeddingClient.Model(), s.embeddingClient.Dimension())
+	}
+
+	importer, removed, err := s.db.BeginImport(mode == ImportModeReplace)
+	if err != nil {
+		return nil, err
+	}
+	defer importer.Rollback()
+
+	resp := &ImportResponse{
+		Mode:           mode,
+		EmbeddingModel: manifest.EmbeddingModel,
+		FormatVersion:  manifest.Version,
+		Removed:        removed,
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		doc, chunks, err := ar.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		resp.Documents++
+
+		written, replaced, err := importer.Put(doc, chunks, mode == ImportModeMerge)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !written:
+			resp.Skipped++
+		case replaced:
+			resp.Replaced++
+		default:
+			resp.Imported++
+		}
+	}
+
+	if err := importer.Commit(); err != nil {
+		return nil, err
+	}
+
+	resp.Message = fmt.Sprintf("Imported %d new documents, replaced %d older copies and skipped %d not newer than the indexed copy",
+		resp.Imported, resp.Replaced, resp.Skipped)
+	if mode == ImportModeReplace {
+		resp.Message = fmt.Sprintf("Removed %d existing documents and imported %d", resp.Removed, resp.Imported+resp.Replaced)
+	}
+	return resp, nil
+}
@@ -0,0 +1,180 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/archive"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// exportArchive exports every document of s
+func exportArchive(t *testing.T, s *Service) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := s.Export(context.Background(), &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	return &buf
+}
+
+// writeArchive builds an archive of docs, each with one chunk of dimension
+// dim, for embeddings from model
+func writeArchive(t *testing.T, model string, dim int, docs ...storage.Document) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := archive.NewWriter(&buf, model, dim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range docs {
+		chunk := storage.Chunk{Content: "archived " + doc.Source, EndOffset: 9 + len(doc.Source), Embedding: make([]float32, dim)}
+		chunk.Embedding[0] = 1
+		if err := w.Add(doc, []storage.Chunk{chunk}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// sources returns the indexed sources of s
+func sources(t *testing.T, s *Service) map[string]storage.Document {
+	t.Helper()
+	docs, err := s.db.ListDocuments("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]storage.Document)
+	for _, doc := range docs {
+		found[doc.Source] = doc
+	}
+	return found
+}
+
+// indexText indexes content under source
+func indexText(t *testing.T, s *Service, source, content string) {
+	t.Helper()
+	if _, err := s.Index(context.Background(), IndexRequest{Content: content, Source: source}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportMerge(t *testing.T) {
+	ctx := context.Background()
+	from, _, _ := newTestService(t, nil)
+	to, _, toPath := newTestService(t, nil)
+
+	indexText(t, from, "shared", "The exported copy of a shared document.")
+	indexText(t, from, "exported-only", "Only in the exported index.")
+	indexText(t, to, "shared", "The local copy of a shared document.")
+	indexText(t, to, "local-only", "Only in the local index.")
+
+	// The archived copy of shared was indexed first, so the local one stays
+	resp, err := to.Import(ctx, exportArchive(t, from), ImportRequest{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if resp.Mode != ImportModeMerge || resp.Documents != 2 || resp.Imported != 1 || resp.Skipped != 1 || resp.Replaced != 0 {
+		t.Errorf("Unexpected merge summary: %+v", resp)
+	}
+	docs := sources(t, to)
+	if len(docs) != 3 {
+		t.Fatalf("Expected 3 documents after merge, got %v", docs)
+	}
+
+	// A sub-second later copy is still in the same second
+	local := docs["shared"]
+	later := local
+	later.IndexedAt = local.IndexedAt.Add(500 * time.Millisecond)
+	resp, err = to.Import(ctx, writeArchive(t, to.embeddingClient.Model(), to.embeddingClient.Dimension(), later), ImportRequest{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if resp.Skipped != 1 {
+		t.Errorf("Expected a copy newer by under a second to be skipped, got %+v", resp)
+	}
+
+	// Once the local copy is older, the archived copy replaces it
+	backdateDocuments(t, toPath, time.Hour)
+	resp, err = to.Import(ctx, exportArchive(t, from), ImportRequest{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if resp.Replaced != 2 || resp.Imported != 0 {
+		t.Errorf("Expected both archived copies to replace older local ones, got %+v", resp)
+	}
+	results, err := to.Search(ctx, SearchRequest{Query: "shared", TopK: 10, SourceFilter: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Content != "The exported copy of a shared document." {
+		t.Errorf("Expected the exported chunk to replace the local one, got %+v", results.Results)
+	}
+	if _, ok := sources(t, to)["local-only"]; !ok {
+		t.Error("Expected merge to keep documents missing from the archive")
+	}
+}
+
+func TestImportReplace(t *testing.T) {
+	from, _, _ := newTestService(t, nil)
+	to, _, _ := newTestService(t, nil)
+
+	indexText(t, from, "exported", "Exported document.")
+	indexText(t, to, "local-a", "Local document A.")
+	indexText(t, to, "local-b", "Local document B.")
+
+	resp, err := to.Import(context.Background(), exportArchive(t, from), ImportRequest{Mode: ImportModeReplace})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if resp.Removed != 2 || resp.Imported != 1 {
+		t.Errorf("Unexpected replace summary: %+v", resp)
+	}
+	docs := sources(t, to)
+	if _, ok := docs["exported"]; !ok || len(docs) != 1 {
+		t.Errorf("Expected only the archived document after replace, got %v", docs)
+	}
+}
+
+func TestImportRejectsOtherEmbeddings(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	indexText(t, s, "local", "Local document.")
+	doc := storage.Document{Source: "archived", SourceType: "content", IndexedAt: time.Now()}
+
+	for name, buf := range map[string]*bytes.Buffer{
+		"model":     writeArchive(t, "another-model", s.embeddingClient.Dimension(), doc),
+		"dimension": writeArchive(t, s.embeddingClient.Model(), 4, doc),
+	} {
+		if _, err := s.Import(context.Background(), buf, ImportRequest{Mode: ImportModeReplace}); err == nil {
+			t.Errorf("Expected an archive with a different %s to be refused", name)
+		}
+	}
+	if docs := sources(t, s); len(docs) != 1 {
+		t.Errorf("Expected a refused import to leave the index unchanged, got %v", docs)
+	}
+}
+
+func TestImportRollsBackCorruptArchive(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	indexText(t, s, "local", "Local document.")
+
+	// The second document has no source, so reading it fails after the
+	// first has been written
+	buf := writeArchive(t, s.embeddingClient.Model(), s.embeddingClient.Dimension(),
+		storage.Document{Source: "archived", SourceType: "content", IndexedAt: time.Now()},
+		storage.Document{SourceType: "content", IndexedAt: time.Now()},
+	)
+	if _, err := s.Import(context.Background(), buf, ImportRequest{Mode: ImportModeReplace}); err == nil {
+		t.Fatal("Expected a corrupt archive to fail")
+	}
+
+	docs := sources(t, s)
+	if _, ok := docs["local"]; !ok || len(docs) != 1 {
+		t.Errorf("Expected the failed import to be rolled back, got %v", docs)
+	}
+}
@@ -0,0 +1,205 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshots are named doc_search-<UTC timestamp>.db so they sort by age
+const (
+	snapshotPrefix = "doc_search-"
+	snapshotLayout = "20060102-150405"
+	snapshotExt    = ".db"
+)
+
+// ErrBackupExists is returned when a backup target exists and overwrite is not set
+var ErrBackupExists = errors.New("backup file already exists")
+
+// BackupPolicy configures where backups are written and how many
+// timestamped snapshots are kept
+type BackupPolicy struct {
+	// Dir holds snapshots and is the only place the backup tool may write
+	Dir string
+	// Retain is the number of timestamped snapshots kept (0 keeps all)
+	Retain int
+}
+
+// SetBackupPolicy sets the backup directory and snapshot retention
+func (s *Service) SetBackupPolicy(policy BackupPolicy) error {
+	if policy.Dir == "" {
+		return fmt.Errorf("backup directory is required")
+	}
+	if policy.Retain < 0 {
+		return fmt.Errorf("backup retention must be non-negative, got %d", policy.Retain)
+	}
+	dir, err := filepath.Abs(policy.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve backup directory: %w", err)
+	}
+	policy.Dir = dir
+	s.backups = &policy
+	return nil
+}
+
+// BackupRequest represents a backup request
+type BackupRequest struct {
+	// Name is a file name inside the backup directory; empty creates a
+	// timestamped snapshot and prunes old ones
+	Name      string
+	Overwrite bool
+}
+
+// BackupResponse describes a written backup
+type BackupResponse struct {
+	Path       string   `json:"path"`
+	SizeBytes  int64    `json:"size_bytes"`
+	CreatedAt  string   `json:"created_at"`
+	DurationMs int64    `json:"duration_ms"`
+	Pruned     []string `json:"pruned,omitempty"`
+	Message    string   `json:"message"`
+}
+
+// Backup writes a consistent snapshot of the database into the backup
+// directory while the server keeps running
+func (s *Service) Backup(ctx context.Context, req BackupRequest) (*BackupResponse, error) {
+	if s.backups == nil {
+		return nil, fmt.Errorf("backups are not configured")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = snapshotName(time.Now())
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid backup name %q: must be a plain file name inside the backup directory", req.Name)
+	}
+
+	resp, err := s.BackupTo(ctx, filepath.Join(s.backups.Dir, name), req.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" {
+		pruned, err := pruneSnapshots(s.backups.Dir, s.backups.Retain)
+		if err != nil {
+			log.Printf("Failed to prune old snapshots: %v", err)
+		}
+		resp.Pruned = pruned
+		if len(pruned) > 0 {
+			resp.Message += fmt.Sprintf(", removed %d old snapshots", len(pruned))
+		}
+	}
+	return resp, nil
+}
+
+// BackupTo writes a consistent snapshot of the database to any path
+func (s *Service) BackupTo(ctx context.Context, path string, overwrite bool) (*BackupResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrBackupExists, path)
+		}
+	}
+
+	start := time.Now()
+	if err := s.db.Backup(path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	return &BackupResponse{
+		Path:       path,
+		SizeBytes:  info.Size(),
+		CreatedAt:  start.UTC().Format(time.RFC3339),
+		DurationMs: time.Since(start).Milliseconds(),
+		Message:    fmt.Sprintf("Backed up database to %s (%d bytes)", path, info.Size()),
+	}, nil
+}
+
+// RunBackupScheduler writes a timestamped snapshot every interval until ctx
+// is cancelled, keeping the configured number of snapshots. A non-positive
+// interval disables the scheduler.
+func (s *Service) RunBackupScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || s.backups == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resp, err := s.Backup(ctx, BackupRequest{})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Scheduled backup failed: %v", err)
+			}
+			continue
+		}
+		log.Println(resp.Message)
+	}
+}
+
+// snapshotName returns the file name of a snapshot taken at t
+func snapshotName(t time.Time) string {
+	return snapshotPrefix + t.UTC().Format(snapshotLayout) + snapshotExt
+}
+
+// pruneSnapshots removes all but the newest retain snapshots in dir and
+// returns the removed paths. Files not named like snapshots are left alone.
+func pruneSnapshots(dir string, retain int) ([]string, error) {
+	if retain <= 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup directory: %w", err)
+	}
+
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotExt)
+		if _, err := time.Parse(snapshotLayout, stamp); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, name)
+	}
+	if len(snapshots) <= retain {
+		return nil, nil
+	}
+
+	// Timestamps sort lexically, so the oldest come first
+	sort.Strings(snapshots)
+	var pruned []string
+	for _, name := range snapshots[:len(snapshots)-retain] {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			return pruned, fmt.Errorf("failed to remove snapshot %s: %w", name, err)
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var names []string
+	for i := 0; i < 5; i++ {
+		names = append(names, snapshotName(start.Add(time.Duration(i)*time.Hour)))
+	}
+	// Files not named like snapshots must survive
+	others := []string{"manual.db", "doc_search-latest.db", "doc_search-20250101-000000.db.partial"}
+	for _, name := range append(names, others...) {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := pruneSnapshots(dir, 2)
+	if err != nil {
+		t.Fatalf("pruneSnapshots failed: %v", err)
+	}
+	if len(pruned) != 3 {
+		t.Fatalf("Expected 3 snapshots pruned, got %v", pruned)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	for _, keep := range append(names[3:], others...) {
+		found := false
+		for _, name := range remaining {
+			found = found || name == keep
+		}
+		if !found {
+			t.Errorf("Expected %s to be kept, remaining: %v", keep, remaining)
+		}
+	}
+	if len(remaining) != 2+len(others) {
+		t.Errorf("Unexpected remaining files: %v", remaining)
+	}
+
+	// Retain 0 keeps everything
+	if pruned, err := pruneSnapshots(dir, 0); err != nil || len(pruned) != 0 {
+		t.Errorf("Expected nothing pruned with retain 0, got %v, %v", pruned, err)
+	}
+}
+
+func TestBackupRejectsNamesOutsideDir(t *testing.T) {
+	s := &Service{}
+	if _, err := s.Backup(context.Background(), BackupRequest{}); err == nil {
+		t.Error("Expected error when backups are not configured")
+	}
+
+	if err := s.SetBackupPolicy(BackupPolicy{Dir: t.TempDir(), Retain: 3}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../escape.db", "sub/dir.db", "/tmp/abs.db", ".hidden.db", ".."} {
+		_, err := s.Backup(context.Background(), BackupRequest{Name: name})
+		if err == nil || !strings.Contains(err.Error(), "invalid backup name") {
+			t.Errorf("Backup(%q): expected invalid name error, got %v", name, err)
+		}
+	}
+}
+
+func TestBackupSchedulerWritesCompleteSnapshots(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	if _, err := s.Index(context.Background(), IndexRequest{Content: "Snapshot me.", Source: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := s.SetBackupPolicy(BackupPolicy{Dir: dir, Retain: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.RunBackupScheduler(ctx, 10*time.Millisecond)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		matches, _ := filepath.Glob(filepath.Join(dir, snapshotPrefix+"*"+snapshotExt))
+		if len(matches) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a snapshot")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// Snapshots are written under a temporary name and only renamed into
+	// place once complete, so nothing else is left in the directory
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotExt) {
+			t.Errorf("Unexpected file in the backup directory: %s", name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cmrigney/mcp-document-search/internal/embeddings"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// maxBatchQueries caps the number of queries accepted by SearchBatch
+const maxBatchQueries = 50
+
+// SearchBatchRequest represents a request to run several searches at once
+type SearchBatchRequest struct {
+	Queries      []string
+	TopK         int
+	MinScore     float64
+	SourceFilter string
+	Deduplicate  bool
+}
+
+// SearchBatchResponse represents the grouped results of a batch search
+type SearchBatchResponse struct {
+	Results []BatchQueryResult `json:"results"`
+	Count   int                `json:"count"`
+	Usage   embeddings.Usage   `json:"usage"`
+}
+
+// BatchQueryResult holds the results for a single query in a batch
+type BatchQueryResult struct {
+	Query   string             `json:"query"`
+	Results []SearchResultItem `json:"results"`
+	Count   int                `json:"count"`
+}
+
+// SearchBatch embeds all queries in a single request and searches them
+// concurrently. With Deduplicate set, a chunk matched by several queries is
+// only kept under the query it scored highest for.
+func (s *Service) SearchBatch(ctx context.Context, req SearchBatchRequest) (*SearchBatchResponse, error) {
+	if len(req.Queries) == 0 {
+		return nil, fmt.Errorf("at least one query is required")
+	}
+	if len(req.Queries) > maxBatchQueries {
+		return nil, fmt.Errorf("too many queries: got %d, maximum is %d", len(req.Queries), maxBatchQueries)
+	}
+	for i, query := range req.Queries {
+		if query == "" {
+			return nil, fmt.Errorf("query %d is empty", i)
+		}
+	}
+
+	// Set defaults
+	if req.TopK <= 0 {
+		req.TopK = 5
+	}
+
+	// Embed all queries in one request
+	queryEmbeddings, usage, err := s.embeddingClient.EmbedWithUsage(ctx, req.Queries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed queries: %w", err)
+	}
+	s.recordUsage("search", usage)
+
+	if len(queryEmbeddings) != len(req.Queries) {
+		return nil, fmt.Errorf("embedding count mismatch: got %d embeddings for %d queries", len(queryEmbeddings), len(req.Queries))
+	}
+
+	// Search database concurrently
+	resultSets := make([][]storage.SearchResult, len(req.Queries))
+	errs := make([]error, len(req.Queries))
+	var wg sync.WaitGroup
+	for i, queryEmbedding := range queryEmbeddings {
+		wg.Add(1)
+		go func(i int, queryEmbedding []float32) {
+			defer wg.Done()
+			resultSets[i], errs[i] = s.db.Search(queryEmbedding, req.TopK, req.MinScore, req.SourceFilter)
+		}(i, queryEmbedding)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to search database for query %d: %w", i, err)
+		}
+	}
+
+	if req.Deduplicate {
+		resultSets = dedupeResultSets(resultSets)
+	}
+
+	groups := make([]BatchQueryResult, len(req.Queries))
+	total := 0
+	for i, query := range req.Queries {
+		items := toResultItems(resultSets[i])
+		groups[i] = BatchQueryResult{
+			Query:   query,
+			Results: items,
+			Count:   len(items),
+		}
+		total += len(items)
+	}
+
+	return &SearchBatchResponse{
+		Results: groups,
+		Count:   total,
+		Usage:   usage,
+	}, nil
+}
+
+// dedupeResultSets removes chunks that appear in more than one result set,
+// keeping each in the set where it has the highest score (earliest set wins ties)
+func dedupeResultSets(resultSets [][]storage.SearchResult) [][]storage.SearchResult {
+	type owner struct {
+		set   int
+		score float64
+	}
+
+	owners := make(map[string]owner)
+	for i, results := range resultSets {
+		for _, result := range results {
+			key := resultKey(result)
+			if current, ok := owners[key]; !ok || result.Score > current.score {
+				owners[key] = owner{set: i, score: result.Score}
+			}
+		}
+	}
+
+	deduped := make([][]storage.SearchResult, len(resultSets))
+	for i, results := range resultSets {
+		kept := make([]storage.SearchResult, 0, len(results))
+		for _, result := range results {
+			key := resultKey(result)
+			if owners[key].set == i {
+				kept = append(kept, result)
+			}
+		}
+		deduped[i] = kept
+	}
+
+	return deduped
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+func TestDedupeResultSets(t *testing.T) {
+	sets := [][]storage.SearchResult{
+		{
+			{Source: "a", ChunkIndex: 0, Score: 0.9},
+			{Source: "b", ChunkIndex: 0, Score: 0.5},
+		},
+		{
+			{Source: "b", ChunkIndex: 0, Score: 0.8},
+			{Source: "a", ChunkIndex: 0, Score: 0.9},
+			{Source: "c", ChunkIndex: 2, Score: 0.4},
+		},
+	}
+
+	deduped := dedupeResultSets(sets)
+
+	if len(deduped[0]) != 1 || deduped[0][0].Source != "a" {
+		t.Errorf("Expected first set to keep only a#0, got %+v", deduped[0])
+	}
+	if len(deduped[1]) != 2 || deduped[1][0].Source != "b" || deduped[1][1].Source != "c" {
+		t.Errorf("Expected second set to keep b#0 and c#2, got %+v", deduped[1])
+	}
+}
+
+func TestSearchBatchValidation(t *testing.T) {
+	s := &Service{}
+	ctx := context.Background()
+
+	if _, err := s.SearchBatch(ctx, SearchBatchRequest{}); err == nil {
+		t.Error("Expected error for empty query list")
+	}
+	if _, err := s.SearchBatch(ctx, SearchBatchRequest{Queries: []string{"ok", ""}}); err == nil {
+		t.Error("Expected error for empty query")
+	}
+	if _, err := s.SearchBatch(ctx, SearchBatchRequest{Queries: make([]string, maxBatchQueries+1)}); err == nil {
+		t.Error("Expected error for too many queries")
+	}
+}
@@ -0,0 +1,162 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/fetcher"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+const (
+	defaultCrawlDepth = 2
+	defaultCrawlPages = 50
+	maxCrawlPages     = 1000
+	defaultCrawlDelay = 500 * time.Millisecond
+)
+
+// CrawlRequest represents a request to crawl and index a site
+type CrawlRequest struct {
+	URL      string
+	MaxDepth *int
+	MaxPages int
+	Include  []string
+	Exclude  []string
+	Delay    *time.Duration
+	Reindex  bool
+
+	// ResumeSince marks a resumed job: pages indexed at or after this time
+	// were written by an earlier attempt and are not indexed again
+	ResumeSince time.Time `json:"-"`
+}
+
+// CrawlResponse summarizes a crawl
+type CrawlResponse struct {
+	StartURL     string       `json:"start_url"`
+	PagesIndexed int          `json:"pages_indexed"`
+	PagesSkipped int          `json:"pages_skipped"`
+	PagesFailed  int          `json:"pages_failed"`
+	Disallowed   int          `json:"disallowed_by_robots"`
+	Filtered     int          `json:"filtered_by_pattern"`
+	ChunkCount   int          `json:"chunk_count"`
+	Pages        []PageResult `json:"pages"`
+	Message      string       `json:"message"`
+
+	EmbeddingTokens int     `json:"embedding_tokens"`
+	EstimatedCost   float64 `json:"estimated_cost_usd"`
+}
+
+// PageResult reports the outcome for one page of a bulk index
+type PageResult struct {
+	URL        string `json:"url"`
+	Status     string `json:"status"`
+	ChunkCount int    `json:"chunk_count,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Crawl follows same-origin links from a start URL and indexes each page as
+// its own "url" document
+func (s *Service) Crawl(ctx context.Context, req CrawlRequest) (*CrawlResponse, error) {
+	if req.URL == "" {
+		return nil, fmt.Errorf("url is required for crawling")
+	}
+
+	opts := fetcher.CrawlOptions{
+		MaxDepth: defaultCrawlDepth,
+		MaxPages: req.MaxPages,
+		Include:  req.Include,
+		Exclude:  req.Exclude,
+		Delay:    defaultCrawlDelay,
+	}
+	if req.MaxDepth != nil {
+		opts.MaxDepth = *req.MaxDepth
+	}
+	if opts.MaxDepth < 0 {
+		return nil, fmt.Errorf("max_depth must be non-negative, got %d", opts.MaxDepth)
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = defaultCrawlPages
+	}
+	if opts.MaxPages > maxCrawlPages {
+		return nil, fmt.Errorf("max_pages must be at most %d, got %d", maxCrawlPages, opts.MaxPages)
+	}
+	if req.Delay != nil {
+		opts.Delay = *req.Delay
+	}
+
+	resp := &CrawlResponse{StartURL: req.URL, Pages: []PageResult{}}
+
+	summary, err := s.fetcher.Crawl(ctx, req.URL, opts, func(page fetcher.CrawlPage) error {
+		result := PageResult{URL: page.URL}
+
+		if page.Err != nil {
+			result.Status = PageStatusFailed
+			result.Error = page.Err.Error()
+			resp.PagesFailed++
+			resp.Pages = append(resp.Pages, result)
+			reportPageDone(ctx, page.URL, len(resp.Pages), 0)
+			return nil
+		}
+
+		done, err := s.indexedSince(page.URL, req.ResumeSince)
+		if err != nil {
+			return err
+		}
+		if done != nil {
+			result.Status = PageStatusIndexed
+			result.ChunkCount = done.ChunkCount
+			resp.PagesIndexed++
+			resp.ChunkCount += done.ChunkCount
+			resp.Pages = append(resp.Pages, result)
+			reportPageDone(ctx, page.URL, len(resp.Pages), 0)
+			return nil
+		}
+
+		pageCtx := withPage(ctx, len(resp.Pages)+1, len(resp.Pages), 0)
+		reportProgress(pageCtx, Progress{Phase: PhaseFetch, Source: page.URL, Message: fmt.Sprintf("Fetched %s", page.URL)})
+		indexResp, err := s.indexContent(pageCtx, storage.DocumentMeta{
+			Source:     page.URL,
+			SourceType: "url",
+			Title:      page.Result.Title,
+
+			ETag:         page.Result.ETag,
+			LastModified: page.Result.LastModified,
+		}, page.Result.Content, req.Reindex)
+
+		switch {
+		case err == nil:
+			result.Status = PageStatusIndexed
+			result.ChunkCount = indexResp.ChunkCount
+			resp.PagesIndexed++
+			resp.ChunkCount += indexResp.ChunkCount
+			resp.EmbeddingTokens += indexResp.EmbeddingTokens
+			resp.EstimatedCost += indexResp.EstimatedCost
+		case errors.Is(err, ErrAlreadyIndexed):
+			result.Status = PageStatusSkipped
+			result.Error = "already indexed"
+			resp.PagesSkipped++
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			result.Status = PageStatusFailed
+			result.Error = err.Error()
+			resp.PagesFailed++
+		}
+
+		resp.Pages = append(resp.Pages, result)
+		reportPageDone(ctx, page.URL, len(resp.Pages), 0)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("crawl failed: %w", err)
+	}
+
+	resp.Disallowed = summary.Disallowed
+	resp.Filtered = summary.Filtered
+	resp.Message = fmt.Sprintf("Crawled %s: %d indexed, %d skipped, %d failed (%d chunks)",
+		req.URL, resp.PagesIndexed, resp.PagesSkipped, resp.PagesFailed, resp.ChunkCount)
+
+	return resp, nil
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// Query transformation modes
+const (
+	QueryModeNone  = ""
+	QueryModeHyDE  = "hyde"
+	QueryModeMulti = "multi"
+)
+
+const (
+	defaultNumQueries = 3
+	maxNumQueries     = 10
+	// rrfK is the rank constant used by reciprocal rank fusion
+	rrfK = 60
+)
+
+const hydeSystemPrompt = `You write short passages of technical documentation.
+Given a question, write a single paragraph that would plausibly appear in documentation answering it.
+Do not mention that the passage is hypothetical. Respond with the passage only.`
+
+const multiQuerySystemPrompt = `You rewrite search queries for a semantic document search engine.
+Given a query, produce %d alternative phrasings that keep its meaning but vary wording and specificity.
+Respond with one query per line and nothing else.`
+
+// listPrefixPattern matches numbering or bullets a model may put before a line
+var listPrefixPattern = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+
+// expandQuery returns the texts to embed for a query in the given mode
+func (s *Service) expandQuery(ctx context.Context, query, mode string, numQueries int) ([]string, error) {
+	switch mode {
+	case QueryModeNone:
+		return []string{query}, nil
+	case QueryModeHyDE, QueryModeMulti:
+	default:
+		return nil, fmt.Errorf("unsupported query mode: %s (must be %q or %q)", mode, QueryModeHyDE, QueryModeMulti)
+	}
+
+	if s.chatClient == nil {
+		return nil, fmt.Errorf("query mode %q requires a chat client to be configured", mode)
+	}
+
+	if mode == QueryModeHyDE {
+		draft, err := s.chatClient.Complete(ctx, hydeSystemPrompt, query, 0.2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate hypothetical document: %w", err)
+		}
+		if draft == "" {
+			return []string{query}, nil
+		}
+		return []string{draft}, nil
+	}
+
+	if numQueries <= 0 {
+		numQueries = defaultNumQueries
+	}
+	if numQueries > maxNumQueries {
+		numQueries = maxNumQueries
+	}
+
+	reply, err := s.chatClient.Complete(ctx, fmt.Sprintf(multiQuerySystemPrompt, numQueries), query, 0.7)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query variations: %w", err)
+	}
+
+	return append([]string{query}, parseQueryVariations(reply, query, numQueries)...), nil
+}
+
+// parseQueryVariations splits a model reply into distinct queries, dropping
+// list markers, blank lines and repeats of the original query
+func parseQueryVariations(reply, original string, limit int) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(original)): true}
+	var variations []string
+
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(listPrefixPattern.ReplaceAllString(line, ""))
+		line = strings.Trim(line, `"`)
+		key := strings.ToLower(line)
+		if line == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		variations = append(variations, line)
+		if len(variations) >= limit {
+			break
+		}
+	}
+
+	return variations
+}
+
+// fuseResults merges ranked result lists with reciprocal rank fusion. Each
+// chunk keeps its best similarity score; ordering follows the fused rank.
+func fuseResults(resultSets [][]storage.SearchResult, topK int) []storage.SearchResult {
+	type fused struct {
+		result storage.SearchResult
+		rank   float64
+	}
+
+	byKey := make(map[string]*fused)
+	for _, results := range resultSets {
+		for rank, result := range results {
+			key := resultKey(result)
+			entry, ok := byKey[key]
+			if !ok {
+				entry = &fused{result: result}
+				byKey[key] = entry
+			}
+			entry.rank += 1.0 / float64(rrfK+rank+1)
+			if result.Score > entry.result.Score {
+				entry.result.Score = result.Score
+			}
+		}
+	}
+
+	entries := make([]*fused, 0, len(byKey))
+	for _, entry := range byKey {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].rank != entries[j].rank {
+			return entries[i].rank > entries[j].rank
+		}
+		return entries[i].result.Score > entries[j].result.Score
+	})
+
+	if len(entries) > topK {
+		entries = entries[:topK]
+	}
+
+	results := make([]storage.SearchResult, len(entries))
+	for i, entry := range entries {
+		results[i] = entry.result
+	}
+	return results
+}
+
+// resultKey identifies a chunk across result lists
+func resultKey(result storage.SearchResult) string {
+	return fmt.Sprintf("%s#%d", result.Source, result.ChunkIndex)
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+func TestParseQueryVariations(t *testing.T) {
+	reply := "1. How to fix auth timeouts\n\n- \"authentication session expiry\"\n2) auth timeout\n* login timeout settings\nextra query"
+
+	got := parseQueryVariations(reply, "auth timeout", 3)
+	want := []string{
+		"How to fix auth timeouts",
+		"authentication session expiry",
+		"login timeout settings",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseQueryVariations() = %q, expected %q", got, want)
+	}
+}
+
+func TestFuseResults(t *testing.T) {
+	sets := [][]storage.SearchResult{
+		{
+			{Source: "a", ChunkIndex: 0, Score: 0.9},
+			{Source: "b", ChunkIndex: 1, Score: 0.7},
+		},
+		{
+			{Source: "b", ChunkIndex: 1, Score: 0.8},
+			{Source: "c", ChunkIndex: 0, Score: 0.6},
+		},
+	}
+
+	results := fuseResults(sets, 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// b#1 appears in both lists so it should rank first and keep its best score
+	if results[0].Source != "b" || results[0].Score != 0.8 {
+		t.Errorf("Expected b#1 with score 0.8 first, got %s#%d (%f)", results[0].Source, results[0].ChunkIndex, results[0].Score)
+	}
+	if results[1].Source != "a" {
+		t.Errorf("Expected a#0 second, got %s#%d", results[1].Source, results[1].ChunkIndex)
+	}
+}
+
+func TestExpandQueryWithoutChatClient(t *testing.T) {
+	s := &Service{}
+
+	queries, err := s.expandQuery(context.Background(), "auth timeout", QueryModeNone, 0)
+	if err != nil {
+		t.Fatalf("Expected no error for default mode, got %v", err)
+	}
+	if len(queries) != 1 || queries[0] != "auth timeout" {
+		t.Errorf("Expected original query only, got %q", queries)
+	}
+
+	if _, err := s.expandQuery(context.Background(), "auth timeout", QueryModeHyDE, 0); err == nil {
+		t.Error("Expected error for hyde mode without chat client")
+	}
+	if _, err := s.expandQuery(context.Background(), "auth timeout", "bogus", 0); err == nil {
+		t.Error("Expected error for unknown query mode")
+	}
+}
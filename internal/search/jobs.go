@@ -0,0 +1,442 @@
+package search
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// Background job kinds
+const (
+	JobKindIndex   = "index"
+	JobKindCrawl   = "crawl"
+	JobKindSitemap = "sitemap"
+)
+
+// Job statuses
+const (
+	JobQueued    = storage.JobQueued
+	JobRunning   = storage.JobRunning
+	JobCompleted = storage.JobCompleted
+	JobFailed    = storage.JobFailed
+	JobCancelled = storage.JobCancelled
+)
+
+const (
+	// maxConcurrentJobs is how many jobs one process runs at a time
+	maxConcurrentJobs = 2
+
+	// A running job saves its progress every jobHeartbeatInterval; a job
+	// whose heartbeat is older than jobStaleAfter was abandoned by a process
+	// that stopped, and is resumed
+	jobHeartbeatInterval = 2 * time.Second
+	jobStaleAfter        = 30 * time.Second
+
+	// jobPollInterval is how often the runner looks for queued or abandoned
+	// jobs it was not woken for
+	jobPollInterval = 5 * time.Second
+
+	defaultJobListLimit = 20
+)
+
+// JobInfo reports the state of a background job
+type JobInfo struct {
+	ID       string `json:"job_id"`
+	Kind     string `json:"kind"`
+	Target   string `json:"target"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+
+	// Latest progress while running, and the operation's response once
+	// completed
+	Progress *Progress       `json:"progress,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+
+	CancelRequested bool   `json:"cancel_requested,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	StartedAt       string `json:"started_at,omitempty"`
+	FinishedAt      string `json:"finished_at,omitempty"`
+	Message         string `json:"message"`
+}
+
+// ListJobsRequest represents a list_jobs request
+type ListJobsRequest struct {
+	Status string
+	Limit  int
+}
+
+// ListJobsResponse lists background jobs, newest first
+type ListJobsResponse struct {
+	Jobs  []JobInfo `json:"jobs"`
+	Count int       `json:"count"`
+}
+
+// jobRunner tracks the jobs running in this process
+type jobRunner struct {
+	wake chan struct{}
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newJobRunner() *jobRunner {
+	return &jobRunner{
+		wake:    make(chan struct{}, 1),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// SubmitIndex queues an index of a single document as a background job
+func (s *Service) SubmitIndex(req IndexRequest) (*JobInfo, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return s.submitJob(JobKindIndex, req.target(), req)
+}
+
+// SubmitCrawl queues a crawl as a background job
+func (s *Service) SubmitCrawl(req CrawlRequest) (*JobInfo, error) {
+	if req.URL == "" {
+		return nil, fmt.Errorf("url is required for crawling")
+	}
+	return s.submitJob(JobKindCrawl, req.URL, req)
+}
+
+// SubmitSitemap queues a sitemap ingestion as a background job
+func (s *Service) SubmitSitemap(req SitemapRequest) (*JobInfo, error) {
+	if req.SitemapURL == "" {
+		return nil, fmt.Errorf("sitemap URL is required")
+	}
+	return s.submitJob(JobKindSitemap, req.SitemapURL, req)
+}
+
+// submitJob persists a queued job and wakes the runner
+func (s *Service) submitJob(kind, target string, req interface{}) (*JobInfo, error) {
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job request: %w", err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.CreateJob(id, kind, target, string(request)); err != nil {
+		return nil, err
+	}
+	s.wakeJobs()
+
+	return s.JobStatus(id)
+}
+
+// newJobID returns a random job id
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return "job_" + hex.EncodeToString(b), nil
+}
+
+// JobStatus returns the state of a job
+func (s *Service) JobStatus(id string) (*JobInfo, error) {
+	job, err := s.db.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+	info := toJobInfo(*job)
+	return &info, nil
+}
+
+// ListJobs returns background jobs, newest first
+func (s *Service) ListJobs(req ListJobsRequest) (*ListJobsResponse, error) {
+	switch req.Status {
+	case "", JobQueued, JobRunning, JobCompleted, JobFailed, JobCancelled:
+	default:
+		return nil, fmt.Errorf("invalid job status %q (use %s, %s, %s, %s or %s)", req.Status, JobQueued, JobRunning, JobCompleted, JobFailed, JobCancelled)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultJobListLimit
+	}
+
+	jobs, err := s.db.ListJobs(req.Status, limit)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]JobInfo, len(jobs))
+	for i, job := range jobs {
+		infos[i] = toJobInfo(job)
+	}
+	return &ListJobsResponse{Jobs: infos, Count: len(infos)}, nil
+}
+
+// CancelJob cancels a queued job, or stops a running one. Documents a job
+// indexed before it was cancelled stay indexed.
+func (s *Service) CancelJob(id string) (*JobInfo, error) {
+	job, err := s.db.CancelJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	// A job running in this process stops right away; one running in
+	// another process stops at its next heartbeat
+	s.jobs.mu.Lock()
+	if cancel, ok := s.jobs.cancels[id]; ok {
+		cancel()
+	}
+	s.jobs.mu.Unlock()
+
+	info := toJobInfo(*job)
+	return &info, nil
+}
+
+// toJobInfo converts a stored job for reporting
+func toJobInfo(job storage.Job) JobInfo {
+	info := JobInfo{
+		ID:              job.ID,
+		Kind:            job.Kind,
+		Target:          job.Target,
+		Status:          job.Status,
+		Attempts:        job.Attempts,
+		Error:           job.Error,
+		CancelRequested: job.CancelRequested && job.Status == JobRunning,
+		CreatedAt:       job.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if job.Progress != "" {
+		var p Progress
+		if err := json.Unmarshal([]byte(job.Progress), &p); err == nil {
+			info.Progress = &p
+		}
+	}
+	if job.Result != "" {
+		json.Unmarshal([]byte(job.Result), &info.Result)
+	}
+	if !job.StartedAt.IsZero() {
+		info.StartedAt = job.StartedAt.Format("2006-01-02 15:04:05")
+	}
+	if !job.FinishedAt.IsZero() {
+		info.FinishedAt = job.FinishedAt.Format("2006-01-02 15:04:05")
+	}
+
+	switch {
+	case info.CancelRequested:
+		info.Message = fmt.Sprintf("Cancelling %s of %s", job.Kind, job.Target)
+	case job.Status == JobQueued && job.Attempts > 0:
+		info.Message = fmt.Sprintf("Interrupted %s of %s; it will resume", job.Kind, job.Target)
+	case job.Status == JobQueued:
+		info.Message = fmt.Sprintf("Queued %s of %s", job.Kind, job.Target)
+	case job.Status == JobRunning && info.Progress != nil:
+		info.Message = info.Progress.Message
+	case job.Status == JobRunning:
+		info.Message = fmt.Sprintf("Running %s of %s", job.Kind, job.Target)
+	case job.Status == JobCompleted:
+		info.Message = fmt.Sprintf("Completed %s of %s", job.Kind, job.Target)
+	case job.Status == JobFailed:
+		info.Message = fmt.Sprintf("Failed %s of %s: %s", job.Kind, job.Target, job.Error)
+	case job.Status == JobCancelled:
+		info.Message = fmt.Sprintf("Cancelled %s of %s", job.Kind, job.Target)
+	}
+	return info
+}
+
+// RunJobs runs queued background jobs until ctx is cancelled, including
+// jobs left unfinished by an earlier run of this or another process. Jobs
+// still running when ctx is cancelled are put back in the queue before it
+// returns.
+func (s *Service) RunJobs(ctx context.Context) {
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	slots := make(chan struct{}, maxConcurrentJobs)
+	for {
+	claim:
+		for ctx.Err() == nil {
+			select {
+			case slots <- struct{}{}:
+			default:
+				break claim
+			}
+
+			job, err := s.db.ClaimJob(jobStaleAfter)
+			if err != nil || job == nil {
+				<-slots
+				if err != nil {
+					log.Printf("Failed to claim job: %v", err)
+				}
+				break
+			}
+
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				defer func() {
+					<-slots
+					s.wakeJobs()
+				}()
+				s.runJob(ctx, job)
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.jobs.wake:
+		}
+	}
+}
+
+// wakeJobs tells the runner to look for jobs without waiting for its poll
+func (s *Service) wakeJobs() {
+	select {
+	case s.jobs.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runJob runs one claimed job to completion, cancellation or failure, saving
+// its progress as it goes
+func (s *Service) runJob(base context.Context, job *storage.Job) {
+	ctx, cancel := context.WithCancel(base)
+	defer cancel()
+
+	s.jobs.mu.Lock()
+	s.jobs.cancels[job.ID] = cancel
+	s.jobs.mu.Unlock()
+	defer func() {
+		s.jobs.mu.Lock()
+		delete(s.jobs.cancels, job.ID)
+		s.jobs.mu.Unlock()
+	}()
+
+	if job.Attempts > 1 {
+		log.Printf("Resuming %s job %s for %s (attempt %d)", job.Kind, job.ID, job.Target, job.Attempts)
+	} else {
+		log.Printf("Starting %s job %s for %s", job.Kind, job.ID, job.Target)
+	}
+
+	var mu sync.Mutex
+	progress := job.Progress
+	ctx = WithProgress(ctx, func(p Progress) {
+		encoded, err := json.Marshal(p)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		progress = string(encoded)
+		mu.Unlock()
+	})
+	latest := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return progress
+	}
+
+	heartbeatDone := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatDone:
+				return
+			case <-ticker.C:
+				stop, err := s.db.HeartbeatJob(job.ID, latest())
+				if err != nil {
+					log.Printf("Failed to update job %s: %v", job.ID, err)
+					continue
+				}
+				if stop {
+					cancel()
+				}
+			}
+		}
+	}()
+
+	// A resumed job skips documents indexed by its earlier attempts
+	var since time.Time
+	if job.Attempts > 1 {
+		since = job.StartedAt
+	}
+	result, err := s.executeJob(ctx, job, since)
+	close(heartbeatDone)
+	<-stopped
+
+	switch {
+	case err == nil:
+		err = s.db.FinishJob(job.ID, JobCompleted, latest(), string(result), "")
+		log.Printf("Completed %s job %s for %s", job.Kind, job.ID, job.Target)
+	case base.Err() != nil:
+		// Shutting down: leave the job to be resumed by the next run
+		err = s.db.RequeueJob(job.ID, latest())
+		log.Printf("Interrupted %s job %s for %s; it will resume on restart", job.Kind, job.ID, job.Target)
+	case ctx.Err() != nil:
+		err = s.db.FinishJob(job.ID, JobCancelled, latest(), "", "cancelled")
+		log.Printf("Cancelled %s job %s for %s", job.Kind, job.ID, job.Target)
+	default:
+		log.Printf("Failed %s job %s for %s: %v", job.Kind, job.ID, job.Target, err)
+		err = s.db.FinishJob(job.ID, JobFailed, latest(), "", err.Error())
+	}
+	if err != nil {
+		log.Printf("Failed to record job %s: %v", job.ID, err)
+	}
+}
+
+// executeJob decodes and runs a job's request, returning its encoded response
+func (s *Service) executeJob(ctx context.Context, job *storage.Job, since time.Time) ([]byte, error) {
+	var resp interface{}
+	var err error
+	switch job.Kind {
+	case JobKindIndex:
+		var req IndexRequest
+		if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+			return nil, fmt.Errorf("failed to decode job request: %w", err)
+		}
+		req.ResumeSince = since
+		resp, err = s.Index(ctx, req)
+	case JobKindCrawl:
+		var req CrawlRequest
+		if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+			return nil, fmt.Errorf("failed to decode job request: %w", err)
+		}
+		req.ResumeSince = since
+		resp, err = s.Crawl(ctx, req)
+	case JobKindSitemap:
+		var req SitemapRequest
+		if err := json.Unmarshal([]byte(job.Request), &req); err != nil {
+			return nil, fmt.Errorf("failed to decode job request: %w", err)
+		}
+		req.ResumeSince = since
+		resp, err = s.IndexSitemap(ctx, req)
+	default:
+		return nil, fmt.Errorf("unknown job kind: %s", job.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job result: %w", err)
+	}
+	return result, nil
+}
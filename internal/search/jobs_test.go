@@ -0,0 +1,232 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"github.com/cmrigney/mcp-document-search/internal/chunker"
+	"github.com/cmrigney/mcp-document-search/internal/embeddings"
+	"github.com/cmrigney/mcp-document-search/internal/fetcher"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+// newJobTestService returns a service backed by a temporary database and a
+// fake embeddings API, and a count of embedding requests made. When block is
+// non-nil, embedding requests wait for it to close.
+func newJobTestService(t *testing.T, block chan struct{}) (*Service, *int32) {
+	t.Helper()
+	s, requests, _ := newTestService(t, block)
+	return s, requests
+}
+
+// newTestService is newJobTestService that also returns the database path,
+// and lets the service fetch from loopback test servers
+func newTestService(t *testing.T, block chan struct{}) (*Service, *int32, string) {
+	t.Helper()
+
+	var requests int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if block != nil {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		type item struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		data := make([]item, len(req.Input))
+		for i := range data {
+			data[i] = item{Embedding: make([]float32, 1536), Index: i}
+			data[i].Embedding[i%1536] = 1
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(api.Close)
+
+	sqlite_vec.Auto()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := storage.NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	f := fetcher.NewFetcher()
+	if err := f.SetNetworkPolicy(fetcher.NetworkPolicy{AllowedNetworks: []string{"127.0.0.0/8", "::1/128"}}); err != nil {
+		t.Fatalf("SetNetworkPolicy failed: %v", err)
+	}
+
+	client := embeddings.NewClientWithOptions("test", embeddings.Options{Endpoint: api.URL})
+	return NewService(db, client, chunker.NewChunker(1000, 100), f), &requests, path
+}
+
+// waitForJob polls until a job reaches status or the test times out
+func waitForJob(t *testing.T, s *Service, id, status string) *JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		info, err := s.JobStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Status == status {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for job %s to be %s, last state: %+v", id, status, info)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIndexJobCompletes(t *testing.T) {
+	s, _ := newJobTestService(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.RunJobs(ctx)
+
+	if _, err := s.SubmitIndex(IndexRequest{}); err == nil {
+		t.Error("Expected an invalid request to be rejected when submitted")
+	}
+
+	info, err := s.SubmitIndex(IndexRequest{Content: "Background jobs index documents.", Source: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Kind != JobKindIndex || info.Target != "notes" {
+		t.Errorf("Unexpected submitted job: %+v", info)
+	}
+
+	info = waitForJob(t, s, info.ID, JobCompleted)
+	var result IndexResponse
+	encoded, _ := json.Marshal(info.Result)
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		t.Fatalf("Failed to decode job result: %v", err)
+	}
+	if result.Status != PageStatusIndexed || result.ChunkCount != 1 {
+		t.Errorf("Unexpected job result: %+v", result)
+	}
+	if info.Progress == nil || info.Progress.Phase != PhaseDone {
+		t.Errorf("Expected final progress to be saved, got %+v", info.Progress)
+	}
+
+	// A failing job records its error
+	info, err = s.SubmitIndex(IndexRequest{Content: "Again.", Source: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = waitForJob(t, s, info.ID, JobFailed)
+	if info.Error == "" {
+		t.Error("Expected failed job to record its error")
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	s, requests := newJobTestService(t, block)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.RunJobs(ctx)
+
+	info, err := s.SubmitIndex(IndexRequest{Content: "This never finishes embedding.", Source: "slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, s, info.ID, JobRunning)
+	for atomic.LoadInt32(requests) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := s.CancelJob(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, s, info.ID, JobCancelled)
+
+	if exists, _ := s.db.DocumentExists("slow"); exists {
+		t.Error("Expected cancelled job not to store its document")
+	}
+	if _, err := s.CancelJob("job_missing"); err == nil {
+		t.Error("Expected error cancelling an unknown job")
+	}
+}
+
+func TestResumedJobSkipsIndexedDocuments(t *testing.T) {
+	s, requests := newJobTestService(t, nil)
+
+	info, err := s.SubmitIndex(IndexRequest{Content: "Indexed before the restart.", Source: "resumed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a process that indexed the document, then stopped before
+	// recording the job as finished
+	if _, err := s.db.ClaimJob(jobStaleAfter); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Index(context.Background(), IndexRequest{Content: "Indexed before the restart.", Source: "resumed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.RequeueJob(info.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt32(requests)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.RunJobs(ctx)
+
+	info = waitForJob(t, s, info.ID, JobCompleted)
+	if info.Attempts != 2 {
+		t.Errorf("Expected the job to complete on its second attempt, got %d", info.Attempts)
+	}
+	if got := atomic.LoadInt32(requests); got != before {
+		t.Errorf("Expected the resumed job not to embed again, got %d more requests", got-before)
+	}
+}
+
+func TestShutdownRequeuesRunningJob(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	s, _ := newJobTestService(t, block)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.RunJobs(ctx)
+	}()
+
+	info, err := s.SubmitIndex(IndexRequest{Content: "Interrupted by shutdown.", Source: "shutdown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, s, info.ID, JobRunning)
+
+	cancel()
+	<-stopped
+
+	info, err = s.JobStatus(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != JobQueued || info.Attempts != 1 {
+		t.Errorf("Expected job to be queued for resumption, got %+v", info)
+	}
+}
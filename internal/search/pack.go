@@ -0,0 +1,171 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// charsPerToken approximates how many characters make up a token when a
+// budget is given in tokens
+const charsPerToken = 4
+
+// charBudget returns the content budget in characters, the tighter of
+// MaxChars and MaxTokens when both are set, or 0 when neither is
+func (req SearchRequest) charBudget() (int, error) {
+	if req.MaxChars < 0 {
+		return 0, fmt.Errorf("max_chars must not be negative")
+	}
+	if req.MaxTokens < 0 {
+		return 0, fmt.Errorf("max_tokens must not be negative")
+	}
+	budget := req.MaxChars
+	if tokenChars := req.MaxTokens * charsPerToken; tokenChars > 0 && (budget == 0 || tokenChars < budget) {
+		budget = tokenChars
+	}
+	return budget, nil
+}
+
+// packResults fits ranked results into maxChars characters of content.
+// Results are taken in rank order; a result whose chunk touches or overlaps
+// an already packed chunk of the same document is merged into it, keeping
+// the better rank and score. The first result that does not fit is trimmed
+// at a sentence boundary if enough room is left, and lower-ranked results
+// are dropped. It returns the packed results and how many were dropped.
+func packResults(items []SearchResultItem, maxChars int) ([]SearchResultItem, int) {
+	packed := []SearchResultItem{}
+	used := 0
+	for i, item := range items {
+		if j := findAdjacent(packed, item); j >= 0 {
+			merged := mergeChunks(packed[j], item)
+			growth := utf8.RuneCountInString(merged.Content) - utf8.RuneCountInString(packed[j].Content)
+			if used+growth > maxChars {
+				return packed, len(items) - i
+			}
+			packed[j] = merged
+			packed = mergeNeighbours(packed, j)
+			used = packedChars(packed)
+			continue
+		}
+
+		size := utf8.RuneCountInString(item.Content)
+		if used+size > maxChars {
+			room := maxChars - used
+			if room >= minTrimmedPassage || (len(packed) == 0 && room > 0) {
+				item.Content = trimToSentence(item.Content, room)
+				item.Truncated = true
+				packed = append(packed, item)
+				return packed, len(items) - i - 1
+			}
+			return packed, len(items) - i
+		}
+		packed = append(packed, item)
+		used += size
+	}
+	return packed, 0
+}
+
+// packedChars returns the content size of packed results in characters
+func packedChars(packed []SearchResultItem) int {
+	total := 0
+	for _, p := range packed {
+		total += utf8.RuneCountInString(p.Content)
+	}
+	return total
+}
+
+// findAdjacent returns the index of the packed result from the same
+// document whose chunk touches or overlaps item's, or -1
+func findAdjacent(packed []SearchResultItem, item SearchResultItem) int {
+	for i, p := range packed {
+		if p.Source == item.Source && !p.Truncated && adjacent(p, item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// adjacent reports whether two chunks of a document touch or overlap, and
+// their content matches their offsets so they can be spliced
+func adjacent(a, b SearchResultItem) bool {
+	if utf8.RuneCountInString(a.Content) != a.EndOffset-a.StartOffset ||
+		utf8.RuneCountInString(b.Content) != b.EndOffset-b.StartOffset {
+		return false
+	}
+	return b.StartOffset <= a.EndOffset && a.StartOffset <= b.EndOffset
+}
+
+// mergeChunks splices two adjacent chunks of a document into one result,
+// dropping their overlap. The merged result keeps a's score.
+func mergeChunks(a, b SearchResultItem) SearchResultItem {
+	first, second := a, b
+	if b.StartOffset < a.StartOffset {
+		first, second = b, a
+	}
+
+	merged := a
+	merged.StartOffset = first.StartOffset
+	merged.Content = first.Content
+	merged.EndOffset = first.EndOffset
+	if second.EndOffset > first.EndOffset {
+		merged.Content += string([]rune(second.Content)[first.EndOffset-second.StartOffset:])
+		merged.EndOffset = second.EndOffset
+	}
+
+	merged.ChunkIndex = min(a.ChunkIndex, b.ChunkIndex)
+	merged.LastChunkIndex = 0
+	if last := max(lastChunk(a), lastChunk(b)); last > merged.ChunkIndex {
+		merged.LastChunkIndex = last
+	}
+	return merged
+}
+
+// lastChunk returns the last chunk index a result covers
+func lastChunk(item SearchResultItem) int {
+	if item.LastChunkIndex > item.ChunkIndex {
+		return item.LastChunkIndex
+	}
+	return item.ChunkIndex
+}
+
+// mergeNeighbours merges any other packed results that now touch the
+// result at index j, which can happen when a chunk bridges two of them
+func mergeNeighbours(packed []SearchResultItem, j int) []SearchResultItem {
+	for i := 0; i < len(packed); i++ {
+		if i == j || packed[i].Source != packed[j].Source || packed[i].Truncated || !adjacent(packed[j], packed[i]) {
+			continue
+		}
+		// Keep the merged result at the better-ranked position
+		keep, drop := j, i
+		if i < j {
+			keep, drop = i, j
+		}
+		packed[keep] = mergeChunks(packed[keep], packed[drop])
+		packed = append(packed[:drop], packed[drop+1:]...)
+		j = keep
+		i = -1
+	}
+	return packed
+}
+
+// trimToSentence shortens text to at most maxChars characters, ending at the
+// last sentence boundary that fits. Without one, it cuts at a word boundary.
+func trimToSentence(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+
+	// A sentence ends at terminal punctuation followed by whitespace, or at
+	// a line break; keep at least half the room to avoid a tiny passage
+	for i := maxChars - 1; i >= maxChars/2; i-- {
+		switch {
+		case runes[i] == '\n':
+			return strings.TrimRightFunc(string(runes[:i]), unicode.IsSpace)
+		case strings.ContainsRune(".!?", runes[i]) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])):
+			return string(runes[:i+1])
+		}
+	}
+	return truncateText(text, maxChars)
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cmrigney/mcp-document-search/internal/chunker"
+)
+
+// chunkItems chunks text and returns the chunks at the given indexes as
+// results of source, in that rank order with descending scores
+func chunkItems(t *testing.T, text, source string, indexes ...int) []SearchResultItem {
+	t.Helper()
+	chunks := chunker.NewChunker(300, 50).ChunkText(text)
+	items := make([]SearchResultItem, len(indexes))
+	for i, n := range indexes {
+		if n >= len(chunks) {
+			t.Fatalf("Text has only %d chunks", len(chunks))
+		}
+		c := chunks[n]
+		items[i] = SearchResultItem{
+			Content:     c.Content,
+			Source:      source,
+			ChunkIndex:  c.Index,
+			StartOffset: c.StartOffset,
+			EndOffset:   c.EndOffset,
+			Score:       0.9 - float64(i)/10,
+		}
+	}
+	return items
+}
+
+func packTestText() string {
+	var b strings.Builder
+	for i := 0; i < 80; i++ {
+		b.WriteString("Sentence number ")
+		b.WriteString(strings.Repeat("x", i%5+1))
+		b.WriteString(" explains one thing. ")
+	}
+	return b.String()
+}
+
+func TestPackMergesAdjacentChunks(t *testing.T) {
+	text := packTestText()
+	items := chunkItems(t, text, "doc", 1, 2, 5)
+
+	packed, omitted := packResults(items, 10000)
+	if omitted != 0 || len(packed) != 2 {
+		t.Fatalf("Expected chunks 1 and 2 to merge, got %d results, %d omitted: %+v", len(packed), omitted, packed)
+	}
+	merged := packed[0]
+	if merged.ChunkIndex != 1 || merged.LastChunkIndex != 2 || merged.Score != 0.9 {
+		t.Errorf("Unexpected merged result: %+v", merged)
+	}
+	if want := string([]rune(text)[merged.StartOffset:merged.EndOffset]); merged.Content != want {
+		t.Errorf("Expected merged content to match the document without repeating the overlap, got %q", merged.Content)
+	}
+	if packed[1].ChunkIndex != 5 || packed[1].LastChunkIndex != 0 {
+		t.Errorf("Expected chunk 5 to stay separate, got %+v", packed[1])
+	}
+
+	// A chunk bridging two packed results joins all three
+	packed, _ = packResults(chunkItems(t, text, "doc", 1, 3, 2), 10000)
+	if len(packed) != 1 || packed[0].ChunkIndex != 1 || packed[0].LastChunkIndex != 3 {
+		t.Errorf("Expected chunks 1-3 to merge into one result, got %+v", packed)
+	}
+
+	// Chunks of different documents never merge
+	items = append(chunkItems(t, text, "a", 1), chunkItems(t, text, "b", 2)...)
+	if packed, _ := packResults(items, 10000); len(packed) != 2 {
+		t.Errorf("Expected chunks of different documents to stay separate, got %+v", packed)
+	}
+}
+
+func TestPackTrimsToBudget(t *testing.T) {
+	text := packTestText()
+	items := chunkItems(t, text, "doc", 0, 3, 6, 9)
+
+	budget := utf8.RuneCountInString(items[0].Content) + 60
+	packed, omitted := packResults(items, budget)
+	if len(packed) != 1 || omitted != 3 || packed[0].Truncated {
+		t.Errorf("Expected only the top result to fit, got %d results, %d omitted", len(packed), omitted)
+	}
+
+	// With enough room, the first result that does not fit is trimmed at a
+	// sentence boundary
+	budget = packedChars(items[:2]) + minTrimmedPassage
+	packed, omitted = packResults(items, budget)
+	if len(packed) != 3 || omitted != 1 {
+		t.Fatalf("Expected two full results and one trimmed, got %d results, %d omitted", len(packed), omitted)
+	}
+	trimmed := packed[2]
+	if !trimmed.Truncated || !strings.HasSuffix(trimmed.Content, "thing.") {
+		t.Errorf("Expected result trimmed at a sentence boundary, got %q", trimmed.Content)
+	}
+	if n := packedChars(packed); n > budget {
+		t.Errorf("Packed %d characters, over the %d budget", n, budget)
+	}
+
+	// The top result is trimmed rather than dropped
+	packed, omitted = packResults(items, 50)
+	if len(packed) != 1 || omitted != 3 || !packed[0].Truncated || utf8.RuneCountInString(packed[0].Content) > 50 {
+		t.Errorf("Expected the top result trimmed to 50 characters, got %+v", packed)
+	}
+}
+
+func TestCharBudget(t *testing.T) {
+	tests := []struct {
+		req  SearchRequest
+		want int
+	}{
+		{SearchRequest{}, 0},
+		{SearchRequest{MaxChars: 1000}, 1000},
+		{SearchRequest{MaxTokens: 100}, 400},
+		{SearchRequest{MaxChars: 300, MaxTokens: 100}, 300},
+		{SearchRequest{MaxChars: 1000, MaxTokens: 100}, 400},
+	}
+	for _, tt := range tests {
+		got, err := tt.req.charBudget()
+		if err != nil || got != tt.want {
+			t.Errorf("charBudget(%+v) = %d, %v; want %d", tt.req, got, err, tt.want)
+		}
+	}
+	if _, err := (SearchRequest{MaxTokens: -1}).charBudget(); err == nil {
+		t.Error("Expected error for a negative budget")
+	}
+}
+
+func TestTrimToSentence(t *testing.T) {
+	text := "First sentence here. Second one follows! A third, much longer sentence ends the text."
+	if got := trimToSentence(text, 50); got != "First sentence here. Second one follows!" {
+		t.Errorf("Expected cut after the last whole sentence, got %q", got)
+	}
+	if got := trimToSentence("Line one\nline two continues", 15); got != "Line one" {
+		t.Errorf("Expected cut at the line break, got %q", got)
+	}
+	// Without a sentence boundary in reach, cut at a word
+	if got := trimToSentence("no punctuation in this rather long text", 20); got != "no punctuation in…" {
+		t.Errorf("Expected cut at a word boundary, got %q", got)
+	}
+}
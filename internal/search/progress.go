@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"fmt"
+)
+
+// Indexing phases reported in Progress updates
+const (
+	PhaseFetch = "fetch"
+	PhaseChunk = "chunk"
+	PhaseEmbed = "embed"
+	PhaseStore = "store"
+	PhaseDone  = "done"
+)
+
+// Progress is an update from a running index, crawl or sitemap operation
+type Progress struct {
+	Phase  string `json:"phase"`
+	Source string `json:"source,omitempty"`
+
+	// Chunks of the current document embedded so far, and its chunk count
+	// once it has been chunked
+	ChunksEmbedded int `json:"chunks_embedded"`
+	ChunksTotal    int `json:"chunks_total"`
+
+	// For crawls and sitemaps: the 1-based page being processed, pages
+	// finished, and pages to process (0 while unknown)
+	Page       int `json:"page,omitempty"`
+	PagesDone  int `json:"pages_done,omitempty"`
+	PagesTotal int `json:"pages_total,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// ProgressFunc receives progress updates. It may be called from several
+// goroutines over the life of an operation, but never concurrently.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports index progress to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress sends p to the context's ProgressFunc, if any
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(p)
+	}
+}
+
+// withPage returns a context that tags progress for one page of a crawl or
+// sitemap with the page counts
+func withPage(ctx context.Context, page, done, total int) context.Context {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || fn == nil {
+		return ctx
+	}
+	return WithProgress(ctx, func(p Progress) {
+		p.Page, p.PagesDone, p.PagesTotal = page, done, total
+		fn(p)
+	})
+}
+
+// reportPageDone reports that a page of a crawl or sitemap has finished
+func reportPageDone(ctx context.Context, url string, done, total int) {
+	msg := fmt.Sprintf("Processed %d pages", done)
+	if total > 0 {
+		msg = fmt.Sprintf("Processed %d of %d pages", done, total)
+	}
+	reportProgress(ctx, Progress{Phase: PhaseDone, Source: url, Page: done, PagesDone: done, PagesTotal: total, Message: msg})
+}
+
+// Steps converts p into work done and total work, in units that only grow
+// over one operation; total is 0 while unknown. A single document counts
+// one step for fetching and chunking, one per chunk embedded, one for
+// storing and one for finishing. A crawl or sitemap counts one step per
+// page, with the current page's progress as a fraction.
+func (p Progress) Steps() (done, total float64) {
+	docDone, docTotal := p.documentSteps()
+	if p.Page == 0 {
+		return docDone, docTotal
+	}
+
+	// Once a page is finished it is counted in PagesDone
+	fraction := 0.0
+	if p.Page > p.PagesDone && docTotal > 0 {
+		fraction = docDone / docTotal
+	}
+	return float64(p.PagesDone) + fraction, float64(p.PagesTotal)
+}
+
+// documentSteps returns the progress through the current document
+func (p Progress) documentSteps() (done, total float64) {
+	if p.Phase == PhaseFetch {
+		return 0, 0
+	}
+	total = float64(p.ChunksTotal + 3)
+	switch p.Phase {
+	case PhaseChunk, PhaseEmbed:
+		return float64(1 + p.ChunksEmbedded), total
+	case PhaseStore:
+		return float64(2 + p.ChunksTotal), total
+	default:
+		return total, total
+	}
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"testing"
+)
+
+// documentUpdates returns the updates indexContent sends for a document
+// of n chunks embedded in batches of two
+func documentUpdates(n int) []Progress {
+	updates := []Progress{
+		{Phase: PhaseFetch},
+		{Phase: PhaseChunk, ChunksTotal: n},
+	}
+	for done := 0; done <= n; done += 2 {
+		updates = append(updates, Progress{Phase: PhaseEmbed, ChunksEmbedded: done, ChunksTotal: n})
+	}
+	updates = append(updates,
+		Progress{Phase: PhaseEmbed, ChunksEmbedded: n, ChunksTotal: n},
+		Progress{Phase: PhaseStore, ChunksEmbedded: n, ChunksTotal: n},
+		Progress{Phase: PhaseDone, ChunksEmbedded: n, ChunksTotal: n},
+	)
+	return updates
+}
+
+func checkSteps(t *testing.T, updates []Progress, wantTotal float64) {
+	t.Helper()
+	last := -1.0
+	for i, p := range updates {
+		done, total := p.Steps()
+		if done < last {
+			t.Fatalf("Update %d (%+v) went backwards: %g after %g", i, p, done, last)
+		}
+		if total > 0 && done > total {
+			t.Fatalf("Update %d (%+v) exceeds total: %g > %g", i, p, done, total)
+		}
+		last = done
+	}
+	done, total := updates[len(updates)-1].Steps()
+	if done != wantTotal || total != wantTotal {
+		t.Errorf("Expected final progress %g/%g, got %g/%g", wantTotal, wantTotal, done, total)
+	}
+}
+
+func TestProgressStepsSingleDocument(t *testing.T) {
+	checkSteps(t, documentUpdates(5), 8)
+}
+
+func TestProgressStepsPages(t *testing.T) {
+	// Route updates through withPage and reportPageDone like IndexSitemap
+	var updates []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { updates = append(updates, p) })
+
+	const pages = 3
+	for done := 0; done < pages; done++ {
+		pageCtx := withPage(ctx, done+1, done, pages)
+		for _, p := range documentUpdates(4) {
+			reportProgress(pageCtx, p)
+		}
+		reportPageDone(ctx, "https://example.com", done+1, pages)
+	}
+
+	if updates[0].Page != 1 || updates[0].PagesTotal != pages {
+		t.Errorf("Expected page counts on forwarded updates, got %+v", updates[0])
+	}
+	checkSteps(t, updates, pages)
+}
+
+func TestReportProgressWithoutReceiver(t *testing.T) {
+	// Must not panic when nobody listens
+	reportProgress(context.Background(), Progress{Phase: PhaseFetch})
+	reportProgress(withPage(context.Background(), 1, 0, 1), Progress{Phase: PhaseFetch})
+}
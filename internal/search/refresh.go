@@ -0,0 +1,110 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MinRefreshInterval is the shortest per-document refresh interval allowed
+const MinRefreshInterval = time.Minute
+
+// RefreshResponse summarizes one pass over documents due for refresh
+type RefreshResponse struct {
+	Checked   int          `json:"checked"`
+	Indexed   int          `json:"indexed"`
+	Unchanged int          `json:"unchanged"`
+	Failed    int          `json:"failed"`
+	Pages     []PageResult `json:"pages"`
+}
+
+// validateRefreshInterval checks a requested refresh interval; 0 disables refresh
+func validateRefreshInterval(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("refresh interval must be non-negative, got %s", interval)
+	}
+	if interval > 0 && interval < MinRefreshInterval {
+		return fmt.Errorf("refresh interval must be 0 or at least %s, got %s", MinRefreshInterval, interval)
+	}
+	return nil
+}
+
+// RefreshDue re-checks every url document whose refresh interval has elapsed.
+// Documents are revalidated with their stored ETag / Last-Modified and only
+// re-indexed when they changed. The outcome of each check is recorded on the
+// document so list can report it.
+func (s *Service) RefreshDue(ctx context.Context) (*RefreshResponse, error) {
+	docs, err := s.db.DocumentsDueForRefresh()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents due for refresh: %w", err)
+	}
+
+	resp := &RefreshResponse{Pages: []PageResult{}}
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+
+		page := PageResult{URL: doc.Source}
+		indexResp, indexErr := s.Index(ctx, IndexRequest{URL: doc.Source, Reindex: true})
+		if indexErr != nil {
+			if ctx.Err() != nil {
+				return resp, ctx.Err()
+			}
+			page.Status = PageStatusFailed
+			page.Error = indexErr.Error()
+		} else {
+			page.Status = indexResp.Status
+			page.ChunkCount = indexResp.ChunkCount
+		}
+
+		switch page.Status {
+		case PageStatusIndexed:
+			resp.Indexed++
+		case PageStatusUnchanged:
+			resp.Unchanged++
+		default:
+			resp.Failed++
+		}
+		resp.Checked++
+		resp.Pages = append(resp.Pages, page)
+
+		if err := s.db.RecordRefreshCheck(doc.Source, page.Status, page.Error); err != nil {
+			log.Printf("Failed to record refresh check for %s: %v", doc.Source, err)
+		}
+	}
+
+	return resp, nil
+}
+
+// RunRefreshScheduler calls RefreshDue every checkInterval until ctx is
+// cancelled. A non-positive checkInterval disables the scheduler.
+func (s *Service) RunRefreshScheduler(ctx context.Context, checkInterval time.Duration) {
+	if checkInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resp, err := s.RefreshDue(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Scheduled refresh failed: %v", err)
+			}
+			continue
+		}
+		if resp.Checked > 0 {
+			log.Printf("Scheduled refresh checked %d documents (%d re-indexed, %d unchanged, %d failed)",
+				resp.Checked, resp.Indexed, resp.Unchanged, resp.Failed)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateRefreshInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		wantErr  bool
+	}{
+		{0, false},
+		{MinRefreshInterval, false},
+		{24 * time.Hour, false},
+		{30 * time.Second, true},
+		{-time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		err := validateRefreshInterval(tt.interval)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateRefreshInterval(%s) error = %v, wantErr %v", tt.interval, err, tt.wantErr)
+		}
+	}
+}
+
+// testPage is an HTML page served with an ETag. Requests carrying the
+// current ETag get 304 Not Modified; a non-zero status fails every request.
+type testPage struct {
+	mu      sync.Mutex
+	body    string
+	etag    string
+	status  int
+	fetches int
+}
+
+// set changes what the page serves
+func (p *testPage) set(body, etag string, status int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.body, p.etag, p.status = body, etag, status
+}
+
+// newTestPage serves p and returns its URL
+func newTestPage(t *testing.T, p *testPage) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.fetches++
+		if p.status != 0 {
+			http.Error(w, "unavailable", p.status)
+			return
+		}
+		w.Header().Set("ETag", p.etag)
+		if r.Header.Get("If-None-Match") == p.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page</title></head><body><p>%s</p></body></html>", p.body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/page"
+}
+
+// backdateDocuments moves every document's index and last check times into
+// the past, as if ago had passed
+func backdateDocuments(t *testing.T, path string, ago time.Duration) {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	modifier := fmt.Sprintf("-%d seconds", int(ago/time.Second))
+	if _, err := conn.Exec("UPDATE documents SET indexed_at = datetime('now', ?), last_checked_at = datetime('now', ?)", modifier, modifier); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	s, requests, path := newTestService(t, nil)
+	ctx := context.Background()
+
+	page := &testPage{body: "The first version of the page.", etag: `"v1"`}
+	url := newTestPage(t, page)
+	interval := time.Minute
+	if _, err := s.Index(ctx, IndexRequest{URL: url, RefreshInterval: &interval}); err != nil {
+		t.Fatal(err)
+	}
+	// Documents without a schedule are never refreshed
+	if _, err := s.Index(ctx, IndexRequest{Content: "Not scheduled.", Source: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := func() *RefreshResponse {
+		t.Helper()
+		resp, err := s.RefreshDue(ctx)
+		if err != nil {
+			t.Fatalf("RefreshDue failed: %v", err)
+		}
+		return resp
+	}
+
+	if resp := refresh(); resp.Checked != 0 {
+		t.Fatalf("Expected nothing due right after indexing, got %+v", resp)
+	}
+
+	// Once the interval has passed, an unchanged page answers 304
+	backdateDocuments(t, path, 2*time.Minute)
+	before := atomic.LoadInt32(requests)
+	resp := refresh()
+	if resp.Checked != 1 || resp.Unchanged != 1 || resp.Pages[0].URL != url {
+		t.Fatalf("Expected one unchanged check, got %+v", resp)
+	}
+	if got := atomic.LoadInt32(requests); got != before {
+		t.Errorf("Expected an unchanged page not to be embedded again, got %d requests", got-before)
+	}
+	doc, err := s.db.GetDocument(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.LastCheckStatus != PageStatusUnchanged || time.Since(doc.LastCheckedAt) > time.Minute {
+		t.Errorf("Expected the unchanged check to be recorded, got %+v", doc)
+	}
+
+	// The check restarts the interval
+	if resp := refresh(); resp.Checked != 0 {
+		t.Errorf("Expected nothing due right after a check, got %+v", resp)
+	}
+
+	// A changed page is re-indexed
+	page.set("The second version of the page.", `"v2"`, 0)
+	backdateDocuments(t, path, 2*time.Minute)
+	if resp := refresh(); resp.Checked != 1 || resp.Indexed != 1 {
+		t.Fatalf("Expected the changed page to be re-indexed, got %+v", resp)
+	}
+	if doc, _ := s.db.GetDocument(url); doc.ETag != `"v2"` || doc.LastCheckStatus != PageStatusIndexed {
+		t.Errorf("Expected the new version to be stored, got %+v", doc)
+	}
+
+	// A failed fetch is recorded with its error and keeps the stored chunks
+	page.set("", "", http.StatusInternalServerError)
+	backdateDocuments(t, path, 2*time.Minute)
+	resp = refresh()
+	if resp.Checked != 1 || resp.Failed != 1 || resp.Pages[0].Error == "" {
+		t.Fatalf("Expected one failed check, got %+v", resp)
+	}
+	doc, err = s.db.GetDocument(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.LastCheckStatus != PageStatusFailed || doc.LastCheckError == "" || doc.ChunkCount == 0 {
+		t.Errorf("Expected the failure to be recorded on the document, got %+v", doc)
+	}
+	if resp := refresh(); resp.Checked != 0 {
+		t.Errorf("Expected a failed check to wait for the next interval, got %+v", resp)
+	}
+}
+
+func TestIndexSetsRefreshIntervalWithoutFetching(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	ctx := context.Background()
+
+	page := &testPage{body: "A page indexed before it had a schedule.", etag: `"v1"`}
+	url := newTestPage(t, page)
+	if _, err := s.Index(ctx, IndexRequest{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+
+	interval := time.Hour
+	resp, err := s.Index(ctx, IndexRequest{URL: url, RefreshInterval: &interval})
+	if err != nil {
+		t.Fatalf("Expected setting a refresh interval not to need reindex, got %v", err)
+	}
+	if resp.Status != PageStatusUnchanged || resp.ChunkCount == 0 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if doc, _ := s.db.GetDocument(url); doc.RefreshInterval != interval {
+		t.Errorf("Expected refresh interval %s, got %s", interval, doc.RefreshInterval)
+	}
+
+	// Without a new interval, an indexed page is still refused
+	if _, err := s.Index(ctx, IndexRequest{URL: url}); !errors.Is(err, ErrAlreadyIndexed) {
+		t.Errorf("Expected ErrAlreadyIndexed, got %v", err)
+	}
+
+	page.mu.Lock()
+	defer page.mu.Unlock()
+	if page.fetches != 1 {
+		t.Errorf("Expected the page to be fetched once, got %d", page.fetches)
+	}
+}
+
+func TestIndexDoesNotRecordRefreshChecks(t *testing.T) {
+	s, _, path := newTestService(t, nil)
+	ctx := context.Background()
+
+	if _, err := s.Index(ctx, IndexRequest{Content: "Never checked for changes.", Source: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+	page := &testPage{body: "A scheduled page.", etag: `"v1"`}
+	url := newTestPage(t, page)
+	interval := time.Minute
+	if _, err := s.Index(ctx, IndexRequest{URL: url, RefreshInterval: &interval}); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := func() map[string]DocumentInfo {
+		t.Helper()
+		list, err := s.List(ctx, ListRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos := make(map[string]DocumentInfo)
+		for _, doc := range list.Documents {
+			infos[doc.Source] = doc
+		}
+		return infos
+	}
+	for source, doc := range checks() {
+		if doc.LastCheckedAt != "" || doc.LastCheckStatus != "" {
+			t.Errorf("Expected no refresh check for %s, got %q at %q", source, doc.LastCheckStatus, doc.LastCheckedAt)
+		}
+	}
+
+	// A manual reindex keeps the result of the last scheduled check
+	page.set("", "", http.StatusInternalServerError)
+	backdateDocuments(t, path, 2*time.Minute)
+	if resp, err := s.RefreshDue(ctx); err != nil || resp.Failed != 1 {
+		t.Fatalf("Expected one failed check, got %+v, %v", resp, err)
+	}
+	page.set("The page is back.", `"v2"`, 0)
+	backdateDocuments(t, path, 2*time.Minute)
+	if _, err := s.Index(ctx, IndexRequest{URL: url, Reindex: true}); err != nil {
+		t.Fatal(err)
+	}
+	if doc := checks()[url]; doc.LastCheckStatus != PageStatusFailed || doc.LastCheckError == "" {
+		t.Errorf("Expected the failed check to survive a reindex, got %+v", doc)
+	}
+
+	// The reindex still restarts the interval
+	if resp, err := s.RefreshDue(ctx); err != nil || resp.Checked != 0 {
+		t.Errorf("Expected nothing due right after a reindex, got %+v, %v", resp, err)
+	}
+}
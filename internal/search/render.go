@@ -0,0 +1,161 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search output formats
+const (
+	FormatJSON      = "json"
+	FormatMarkdown  = "markdown"
+	FormatCitations = "citations"
+)
+
+// minTrimmedPassage is the shortest passage worth including, trimmed, when
+// the next result does not fit the output budget in full
+const minTrimmedPassage = 200
+
+// SetOutputMaxChars sets the character budget for rendered search results;
+// 0 means unlimited
+func (s *Service) SetOutputMaxChars(n int) {
+	s.outputMaxChars = n
+}
+
+// RenderResults renders search results as markdown or citations within the
+// configured character budget. It also returns the results as rendered: the
+// ones that fit, with a trimmed result's content cut and marked truncated.
+func (s *Service) RenderResults(query string, items []SearchResultItem, format string) (string, []SearchResultItem, error) {
+	return renderResults(query, items, format, s.outputMaxChars)
+}
+
+// renderedResult is one result's share of rendered output: its passage, and
+// for citations its entry in the source list
+type renderedResult struct {
+	passage string
+	source  string
+}
+
+// renderResults renders items as numbered passages. Results are included
+// in rank order until maxChars (0 for unlimited) is used up; the result that
+// does not fit is trimmed if enough room is left, and lower-ranked results
+// are dropped with a note saying how many.
+func renderResults(query string, items []SearchResultItem, format string, maxChars int) (string, []SearchResultItem, error) {
+	var header, sourcesHeader string
+	var render func(n int, item SearchResultItem, content string) renderedResult
+	switch format {
+	case FormatMarkdown:
+		header = fmt.Sprintf("# Search results for %q\n\n", query)
+		render = func(n int, item SearchResultItem, content string) renderedResult {
+			return renderedResult{passage: fmt.Sprintf("## [%d] %s\n\nSource: %s · %s · score %.3f\n\n%s\n\n",
+				n, resultTitle(item), item.Source, resultLocation(item), item.Score, content)}
+		}
+	case FormatCitations:
+		sourcesHeader = "Sources:\n"
+		render = func(n int, item SearchResultItem, content string) renderedResult {
+			source := item.Source
+			if item.Title != "" {
+				source = fmt.Sprintf("%s — %s", item.Title, item.Source)
+			}
+			return renderedResult{
+				passage: fmt.Sprintf("[%d] %s\n\n", n, content),
+				source:  fmt.Sprintf("[%d] %s (%s, score %.3f)\n", n, source, resultLocation(item), item.Score),
+			}
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid format %q (use %s, %s or %s)", format, FormatJSON, FormatMarkdown, FormatCitations)
+	}
+
+	if len(items) == 0 {
+		return header + "No results found.\n", []SearchResultItem{}, nil
+	}
+
+	// Leave room for the note about dropped results
+	used := utf8.RuneCountInString(header) + utf8.RuneCountInString(sourcesHeader)
+	if maxChars > 0 {
+		used += utf8.RuneCountInString(omittedNote(len(items), maxChars))
+	}
+
+	var passages, sources strings.Builder
+	rendered := []SearchResultItem{}
+	for i, item := range items {
+		r := render(i+1, item, item.Content)
+		size := utf8.RuneCountInString(r.passage) + utf8.RuneCountInString(r.source)
+		if maxChars > 0 && used+size > maxChars {
+			room := maxChars - used - (size - utf8.RuneCountInString(item.Content))
+			if room >= minTrimmedPassage || (len(rendered) == 0 && room > 0) {
+				item.Content = truncateText(item.Content, room)
+				item.Truncated = true
+				r = render(i+1, item, item.Content)
+				passages.WriteString(r.passage)
+				sources.WriteString(r.source)
+				rendered = append(rendered, item)
+			}
+			break
+		}
+		passages.WriteString(r.passage)
+		sources.WriteString(r.source)
+		used += size
+		rendered = append(rendered, item)
+	}
+
+	out := header + passages.String()
+	if sourcesHeader != "" && len(rendered) > 0 {
+		out += sourcesHeader + sources.String()
+	}
+	if omitted := len(items) - len(rendered); omitted > 0 {
+		out = strings.TrimRight(out, "\n") + "\n\n" + omittedNote(omitted, maxChars)
+	}
+	return out, rendered, nil
+}
+
+// resultTitle returns the document title, or the source when it has none
+func resultTitle(item SearchResultItem) string {
+	if item.Title != "" {
+		return item.Title
+	}
+	return item.Source
+}
+
+// resultLocation describes where a result sits in its document
+func resultLocation(item SearchResultItem) string {
+	if item.LastChunkIndex > item.ChunkIndex {
+		return fmt.Sprintf("chunks %d-%d, chars %d-%d", item.ChunkIndex, item.LastChunkIndex, item.StartOffset, item.EndOffset)
+	}
+	return fmt.Sprintf("chunk %d, chars %d-%d", item.ChunkIndex, item.StartOffset, item.EndOffset)
+}
+
+// omittedNote explains that lower-ranked results were left out
+func omittedNote(omitted, maxChars int) string {
+	if omitted == 1 {
+		return fmt.Sprintf("(1 lower-ranked result omitted to fit the %d character budget)\n", maxChars)
+	}
+	return fmt.Sprintf("(%d lower-ranked results omitted to fit the %d character budget)\n", omitted, maxChars)
+}
+
+// truncateText shortens text to at most maxChars characters, cutting at a
+// word boundary where possible and marking the cut with an ellipsis
+func truncateText(text string, maxChars int) string {
+	if utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	if maxChars <= 1 {
+		return "…"
+	}
+
+	all := []rune(text)
+	runes := all[:maxChars-1]
+	// Back up to the last space when the cut splits a word, unless that
+	// would drop most of the text
+	if !unicode.IsSpace(all[maxChars-1]) {
+		for i := len(runes) - 1; i > len(runes)*3/4; i-- {
+			if unicode.IsSpace(runes[i]) {
+				runes = runes[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(runes), unicode.IsSpace) + "…"
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func renderTestItems() []SearchResultItem {
+	return []SearchResultItem{
+		{Content: "Install the server with go build.", Source: "https://example.com/install", Title: "Installation", ChunkIndex: 2, StartOffset: 1800, EndOffset: 2800, Score: 0.91},
+		{Content: strings.Repeat("Configuration is read from the environment. ", 20), Source: "docs/config.md", ChunkIndex: 0, EndOffset: 880, Score: 0.72},
+		{Content: "Troubleshooting tips.", Source: "docs/faq.md", ChunkIndex: 5, StartOffset: 5000, EndOffset: 5021, Score: 0.45},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	out, _, err := renderResults("install", renderTestItems(), FormatMarkdown, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`# Search results for "install"`,
+		"## [1] Installation",
+		"Source: https://example.com/install · chunk 2, chars 1800-2800 · score 0.910",
+		"## [2] docs/config.md",
+		"## [3] docs/faq.md",
+		"Troubleshooting tips.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "omitted") {
+		t.Errorf("Expected no omitted note without a budget, got:\n%s", out)
+	}
+}
+
+func TestRenderCitations(t *testing.T) {
+	out, _, err := renderResults("install", renderTestItems(), FormatCitations, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"[1] Install the server with go build.",
+		"Sources:\n[1] Installation — https://example.com/install (chunk 2, chars 1800-2800, score 0.910)\n",
+		"[3] docs/faq.md (chunk 5, chars 5000-5021, score 0.450)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected citations to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "[3] Troubleshooting") > strings.Index(out, "Sources:") {
+		t.Errorf("Expected passages before the source list, got:\n%s", out)
+	}
+}
+
+func TestRenderBudgetDropsLowerRankedResults(t *testing.T) {
+	items := renderTestItems()
+	for _, format := range []string{FormatMarkdown, FormatCitations} {
+		full, _, _ := renderResults("install", items, format, 0)
+		for _, budget := range []int{300, 600, 900} {
+			out, rendered, err := renderResults("install", items, format, budget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rendered) == 0 || len(rendered) == len(items) || rendered[0].Content != items[0].Content {
+				t.Errorf("%s/%d: expected the rendered results to be the ones that fit, got %+v", format, budget, rendered)
+			}
+			if n := utf8.RuneCountInString(out); n > budget {
+				t.Errorf("%s/%d: output is %d characters, over budget:\n%s", format, budget, n, out)
+			}
+			if !strings.Contains(out, "Install the server with go build.") {
+				t.Errorf("%s/%d: expected the top result in full, got:\n%s", format, budget, out)
+			}
+			if !strings.Contains(out, "omitted to fit the") || strings.Contains(out, "Troubleshooting tips.") {
+				t.Errorf("%s/%d: expected lower-ranked results to be dropped with a note, got:\n%s", format, budget, out)
+			}
+		}
+
+		// A budget the full output fits in changes nothing
+		out, _, _ := renderResults("install", items, format, utf8.RuneCountInString(full)+200)
+		if out != full {
+			t.Errorf("%s: expected output within budget to be unchanged, got:\n%s", format, out)
+		}
+	}
+
+	// The top result is trimmed rather than dropped
+	out, rendered, _ := renderResults("install", items[1:], FormatCitations, 250)
+	if !strings.Contains(out, "[1] Configuration is read") || !strings.Contains(out, "…") {
+		t.Errorf("Expected the top result to be trimmed, got:\n%s", out)
+	}
+	if len(rendered) != 1 || !rendered[0].Truncated || !strings.Contains(out, rendered[0].Content) {
+		t.Errorf("Expected the rendered result to hold the trimmed content, got %+v", rendered)
+	}
+}
+
+func TestRenderErrorsAndEmpty(t *testing.T) {
+	if _, _, err := renderResults("q", nil, "xml", 0); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	out, _, err := renderResults("q", nil, FormatCitations, 0)
+	if err != nil || out != "No results found.\n" {
+		t.Errorf("Unexpected empty rendering %q, %v", out, err)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if got := truncateText("short", 10); got != "short" {
+		t.Errorf("Expected text within limit unchanged, got %q", got)
+	}
+	got := truncateText("the quick brown fox jumps over the lazy dog", 20)
+	if got != "the quick brown fox…" {
+		t.Errorf("Expected cut at a word boundary, got %q", got)
+	}
+	if n := utf8.RuneCountInString(truncateText("ééééééééééééé", 5)); n != 5 {
+		t.Errorf("Expected 5 characters, got %d", n)
+	}
+}
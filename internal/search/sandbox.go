@@ -0,0 +1,216 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathNotAllowed is returned when a file path is outside the allowed
+// roots or matches a denied pattern
+var ErrPathNotAllowed = errors.New("file path not allowed")
+
+// DefaultDeniedPatterns are sensitive paths that are never indexed.
+// Patterns without a slash match any single path component; absolute
+// patterns match that path and everything below it.
+var DefaultDeniedPatterns = []string{
+	".ssh",
+	".gnupg",
+	".aws",
+	".azure",
+	".kube",
+	".docker",
+	".git-credentials",
+	".netrc",
+	".pgpass",
+	".npmrc",
+	".pypirc",
+	".env",
+	".env.*",
+	"id_rsa*",
+	"id_dsa*",
+	"id_ecdsa*",
+	"id_ed25519*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"*.kdbx",
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d",
+	"/proc",
+	"/sys",
+	"/dev",
+}
+
+// FilePolicy controls which local files may be indexed. The zero value
+// allows any path except those matching DefaultDeniedPatterns.
+type FilePolicy struct {
+	// AllowedRoots, when non-empty, are the only directories files may be
+	// read from. Symlinks are resolved before the check.
+	AllowedRoots []string
+	// DeniedPatterns are checked in addition to DefaultDeniedPatterns
+	DeniedPatterns []string
+}
+
+// fileSandbox enforces a FilePolicy
+type fileSandbox struct {
+	// roots are the allowed roots as given (made absolute) and with their
+	// symlinks resolved
+	lexicalRoots []string
+	roots        []string
+	denied       []string
+}
+
+// newFileSandbox validates a policy and resolves its roots
+func newFileSandbox(policy FilePolicy) (*fileSandbox, error) {
+	sb := &fileSandbox{}
+	for _, root := range policy.AllowedRoots {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed root %q: %w", root, err)
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed root %q: %w", root, err)
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed root %q: %w", root, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid allowed root %q: not a directory", root)
+		}
+		sb.lexicalRoots = append(sb.lexicalRoots, abs, resolved)
+		sb.roots = append(sb.roots, resolved)
+	}
+
+	for _, pattern := range append(append([]string{}, DefaultDeniedPatterns...), policy.DeniedPatterns...) {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid denied pattern %q: %w", pattern, err)
+		}
+		sb.denied = append(sb.denied, filepath.Clean(pattern))
+	}
+	return sb, nil
+}
+
+// open checks a path against the policy and opens the real file. Every
+// refusal is the same ErrPathNotAllowed naming only the requested path, so
+// callers cannot probe which files exist outside the sandbox or where
+// symlinks lead. The opened handle is verified against the checked path,
+// so swapping a symlink after the check cannot escape the sandbox.
+func (sb *fileSandbox) open(path string) (*os.File, error) {
+	notAllowed := fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, notAllowed
+	}
+
+	// Check the requested path before touching the filesystem. Deny
+	// patterns apply to it too, so a symlink named like a secret is refused
+	// even if its target is harmless.
+	if len(sb.roots) > 0 && !withinRoots(sb.lexicalRoots, abs) {
+		return nil, notAllowed
+	}
+	if _, ok := sb.deniedBy(abs); ok {
+		return nil, notAllowed
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		// A link inside a root may point anywhere, so only an unrestricted
+		// sandbox reports why resolving failed
+		if len(sb.roots) > 0 {
+			return nil, notAllowed
+		}
+		return nil, fmt.Errorf("failed to resolve file path: %w", err)
+	}
+	if _, ok := sb.deniedBy(resolved); ok {
+		return nil, notAllowed
+	}
+	if len(sb.roots) > 0 && !withinRoots(sb.roots, resolved) {
+		return nil, notAllowed
+	}
+
+	// Refuse special files before opening, which could block on a FIFO
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, notAllowed
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	// The path must still resolve to the file that was opened
+	again, err := filepath.EvalSymlinks(abs)
+	if err != nil || again != resolved || !opened.Mode().IsRegular() || !os.SameFile(info, opened) {
+		f.Close()
+		return nil, notAllowed
+	}
+	return f, nil
+}
+
+// deniedBy returns the first denied pattern matching an absolute path
+func (sb *fileSandbox) deniedBy(path string) (string, bool) {
+	components := strings.Split(filepath.ToSlash(path), "/")
+	for _, pattern := range sb.denied {
+		if filepath.IsAbs(pattern) {
+			if path == pattern || strings.HasPrefix(path, pattern+string(filepath.Separator)) {
+				return pattern, true
+			}
+			if ok, _ := filepath.Match(pattern, path); ok {
+				return pattern, true
+			}
+			continue
+		}
+		for _, component := range components {
+			if ok, _ := filepath.Match(pattern, component); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// withinRoots reports whether an absolute path is inside one of roots
+func withinRoots(roots []string, path string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilePolicy replaces the policy applied to file_path indexing
+func (s *Service) SetFilePolicy(policy FilePolicy) error {
+	sb, err := newFileSandbox(policy)
+	if err != nil {
+		return err
+	}
+	s.files = sb
+	return nil
+}
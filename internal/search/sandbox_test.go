@@ -0,0 +1,174 @@
+package search
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// check opens path through the sandbox and closes it again
+func check(sb *fileSandbox, path string) error {
+	f, err := sb.open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func TestFileSandboxAllowedRoots(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "docs")
+	outside := filepath.Join(base, "outside")
+	writeFile(t, filepath.Join(root, "guide.md"))
+	writeFile(t, filepath.Join(root, "nested", "api.md"))
+	writeFile(t, filepath.Join(outside, "private.txt"))
+	writeFile(t, filepath.Join(base, "docs-sibling", "x.md"))
+
+	// Symlinks inside the root pointing outside it must not escape
+	if err := os.Symlink(filepath.Join(outside, "private.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := newFileSandbox(FilePolicy{AllowedRoots: []string{root}})
+	if err != nil {
+		t.Fatalf("newFileSandbox failed: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{filepath.Join(root, "guide.md"), true},
+		{filepath.Join(root, "nested", "api.md"), true},
+		{filepath.Join(root, "nested", "..", "guide.md"), true},
+		{filepath.Join(outside, "private.txt"), false},
+		{filepath.Join(root, "..", "outside", "private.txt"), false},
+		{filepath.Join(base, "docs-sibling", "x.md"), false},
+		{filepath.Join(root, "link.txt"), false},
+		{filepath.Join(root, "linkdir", "private.txt"), false},
+		{root, false},
+	}
+
+	for _, tt := range tests {
+		err := check(sb, tt.path)
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %s to be allowed, got %v", tt.path, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("Expected ErrPathNotAllowed for %s, got %v", tt.path, err)
+		}
+	}
+}
+
+func TestFileSandboxDeniedPatterns(t *testing.T) {
+	base := t.TempDir()
+	writeFile(t, filepath.Join(base, ".ssh", "config"))
+	writeFile(t, filepath.Join(base, "id_rsa"))
+	writeFile(t, filepath.Join(base, "project", ".env"))
+	writeFile(t, filepath.Join(base, "server.pem"))
+	writeFile(t, filepath.Join(base, "notes", "secret.txt"))
+	writeFile(t, filepath.Join(base, "readme.md"))
+
+	// A harmless-looking name pointing at a secret is refused too
+	if err := os.Symlink(filepath.Join(base, "id_rsa"), filepath.Join(base, "innocent.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := newFileSandbox(FilePolicy{DeniedPatterns: []string{"secret.*"}})
+	if err != nil {
+		t.Fatalf("newFileSandbox failed: %v", err)
+	}
+
+	for _, denied := range []string{
+		filepath.Join(base, ".ssh", "config"),
+		filepath.Join(base, "id_rsa"),
+		filepath.Join(base, "project", ".env"),
+		filepath.Join(base, "server.pem"),
+		filepath.Join(base, "notes", "secret.txt"),
+		filepath.Join(base, "innocent.txt"),
+		"/etc/shadow",
+		"/proc/self/environ",
+	} {
+		if err := check(sb, denied); !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("Expected ErrPathNotAllowed for %s, got %v", denied, err)
+		}
+	}
+
+	if err := check(sb, filepath.Join(base, "readme.md")); err != nil {
+		t.Errorf("Expected readme.md to be allowed, got %v", err)
+	}
+}
+
+func TestFileSandboxRefusalsAreUniform(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "docs")
+	writeFile(t, filepath.Join(root, "guide.md"))
+	writeFile(t, filepath.Join(base, "outside", "exists.txt"))
+	writeFile(t, filepath.Join(base, "secret.txt"))
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(root, "notes.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(root, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := newFileSandbox(FilePolicy{AllowedRoots: []string{root}, DeniedPatterns: []string{"secret.*"}})
+	if err != nil {
+		t.Fatalf("newFileSandbox failed: %v", err)
+	}
+
+	// Whether or not a refused file exists, the error only repeats the
+	// requested path
+	for _, path := range []string{
+		filepath.Join(base, "outside", "exists.txt"),
+		filepath.Join(base, "outside", "missing.txt"),
+		filepath.Join(root, "linkdir", "exists.txt"),
+		filepath.Join(root, "linkdir", "missing.txt"),
+		filepath.Join(root, "notes.md"),
+	} {
+		err := check(sb, path)
+		if want := "file path not allowed: " + path; err == nil || err.Error() != want {
+			t.Errorf("Expected %q, got %v", want, err)
+		}
+	}
+
+	f, err := sb.open(filepath.Join(root, "guide.md"))
+	if err != nil {
+		t.Fatalf("Expected guide.md to open, got %v", err)
+	}
+	defer f.Close()
+	if content, err := io.ReadAll(f); err != nil || string(content) != "content" {
+		t.Errorf("Expected to read the checked file, got %q, %v", content, err)
+	}
+}
+
+func TestFileSandboxInvalidPolicy(t *testing.T) {
+	base := t.TempDir()
+	writeFile(t, filepath.Join(base, "file.txt"))
+
+	tests := []FilePolicy{
+		{AllowedRoots: []string{filepath.Join(base, "missing")}},
+		{AllowedRoots: []string{filepath.Join(base, "file.txt")}},
+		{DeniedPatterns: []string{"[unclosed"}},
+	}
+	for _, policy := range tests {
+		if _, err := newFileSandbox(policy); err == nil {
+			t.Errorf("Expected error for policy %+v", policy)
+		}
+	}
+}
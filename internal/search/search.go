@@ -2,53 +2,94 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log"
+	"sort"
+	"time"
 
+	"github.com/cmrigney/mcp-document-search/internal/chat"
 	"github.com/cmrigney/mcp-document-search/internal/chunker"
 	"github.com/cmrigney/mcp-document-search/internal/embeddings"
 	"github.com/cmrigney/mcp-document-search/internal/fetcher"
 	"github.com/cmrigney/mcp-document-search/internal/storage"
 )
 
+// ErrAlreadyIndexed is returned when indexing an existing source without reindex
+var ErrAlreadyIndexed = errors.New("document already indexed")
+
 // Service orchestrates search operations
 type Service struct {
-	db             *storage.Database
+	db              *storage.Database
 	embeddingClient *embeddings.Client
-	chunker        *chunker.Chunker
-	fetcher        *fetcher.Fetcher
+	chunker         *chunker.Chunker
+	fetcher         *fetcher.Fetcher
+	chatClient      *chat.Client
+	files           *fileSandbox
+	backups         *BackupPolicy
+	jobs            *jobRunner
+	outputMaxChars  int
 }
 
 // NewService creates a new search service
 func NewService(db *storage.Database, embClient *embeddings.Client, c *chunker.Chunker, f *fetcher.Fetcher) *Service {
+	files, _ := newFileSandbox(FilePolicy{})
 	return &Service{
-		db:             db,
+		db:              db,
 		embeddingClient: embClient,
-		chunker:        c,
-		fetcher:        f,
+		chunker:         c,
+		fetcher:         f,
+		files:           files,
+		jobs:            newJobRunner(),
 	}
 }
 
+// SetChatClient configures the chat client used for query transformation
+func (s *Service) SetChatClient(c *chat.Client) {
+	s.chatClient = c
+}
+
 // SearchRequest represents a search request
 type SearchRequest struct {
 	Query        string
 	TopK         int
 	MinScore     float64
 	SourceFilter string
+	QueryMode    string
+	NumQueries   int
+
+	// MaxChars and MaxTokens budget the content of the results (0 for no
+	// limit); results are then merged and trimmed to fit
+	MaxChars  int
+	MaxTokens int
 }
 
 // SearchResponse represents a search response
 type SearchResponse struct {
-	Results []SearchResultItem
-	Count   int
+	Results         []SearchResultItem `json:"results"`
+	Count           int                `json:"count"`
+	ExpandedQueries []string           `json:"expanded_queries,omitempty"`
+	Usage           *embeddings.Usage  `json:"usage,omitempty"`
+
+	// Omitted counts lower-ranked results dropped to fit the budget
+	Omitted int `json:"omitted,omitempty"`
 }
 
 // SearchResultItem represents a single search result
 type SearchResultItem struct {
-	Content    string  `json:"content"`
-	Source     string  `json:"source"`
-	ChunkIndex int     `json:"chunk_index"`
-	Score      float64 `json:"score"`
+	Content     string  `json:"content"`
+	Source      string  `json:"source"`
+	Title       string  `json:"title,omitempty"`
+	ChunkIndex  int     `json:"chunk_index"`
+	StartOffset int     `json:"start_offset"`
+	EndOffset   int     `json:"end_offset"`
+	Score       float64 `json:"score"`
+
+	// LastChunkIndex is set when adjacent chunks were merged into this
+	// result, and Truncated when its content was trimmed to fit the budget
+	LastChunkIndex int  `json:"last_chunk_index,omitempty"`
+	Truncated      bool `json:"truncated,omitempty"`
 }
 
 // IndexRequest represents an index request
@@ -58,14 +99,34 @@ type IndexRequest struct {
 	Content  string
 	Source   string
 	Reindex  bool
+
+	// RefreshInterval sets how often a url document is re-checked in the
+	// background (0 disables); nil leaves the current schedule unchanged
+	RefreshInterval *time.Duration
+
+	// ResumeSince marks a resumed job: a document indexed at or after this
+	// time was written by an earlier attempt and is not indexed again
+	ResumeSince time.Time `json:"-"`
 }
 
+// Index statuses, also reported per page by bulk indexing (crawl and sitemap)
+const (
+	PageStatusIndexed   = "indexed"
+	PageStatusSkipped   = "skipped"
+	PageStatusUnchanged = "unchanged"
+	PageStatusFailed    = "failed"
+)
+
 // IndexResponse represents an index response
 type IndexResponse struct {
 	Source     string `json:"source"`
 	SourceType string `json:"source_type"`
+	Status     string `json:"status"`
 	ChunkCount int    `json:"chunk_count"`
 	Message    string `json:"message"`
+
+	EmbeddingTokens int     `json:"embedding_tokens"`
+	EstimatedCost   float64 `json:"estimated_cost_usd"`
 }
 
 // ListRequest represents a list request
@@ -87,6 +148,16 @@ type DocumentInfo struct {
 	ContentSize int    `json:"content_size"`
 	IndexedAt   string `json:"indexed_at"`
 	Title       string `json:"title,omitempty"`
+
+	EmbeddingTokens int     `json:"embedding_tokens"`
+	EmbeddingCost   float64 `json:"estimated_cost_usd"`
+
+	// Scheduled refresh (url documents only)
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+	LastCheckedAt   string `json:"last_checked_at,omitempty"`
+	LastCheckStatus string `json:"last_check_status,omitempty"`
+	LastCheckError  string `json:"last_check_error,omitempty"`
+	NextRefreshAt   string `json:"next_refresh_at,omitempty"`
 }
 
 // DeleteRequest represents a delete request
@@ -101,81 +172,224 @@ type DeleteResponse struct {
 	Message string `json:"message"`
 }
 
+// StatsRequest represents a stats request
+type StatsRequest struct{}
+
+// StatsResponse represents index, spend and cache statistics
+type StatsResponse struct {
+	DocumentCount  int                    `json:"document_count"`
+	ChunkCount     int                    `json:"chunk_count"`
+	TotalTokens    int                    `json:"total_tokens"`
+	TotalCost      float64                `json:"total_estimated_cost_usd"`
+	Operations     []OperationSpend       `json:"operations"`
+	SpendBySource  []SourceSpend          `json:"spend_by_source"`
+	EmbeddingCache *embeddings.CacheStats `json:"embedding_cache,omitempty"`
+}
+
+// OperationSpend is the cumulative embedding spend for index or search calls
+type OperationSpend struct {
+	Operation string  `json:"operation"`
+	Tokens    int     `json:"tokens"`
+	Cost      float64 `json:"estimated_cost_usd"`
+}
+
+// SourceSpend is the cumulative indexing spend for a single document
+type SourceSpend struct {
+	Source string  `json:"source"`
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"estimated_cost_usd"`
+}
+
 // Search performs semantic search
 func (s *Service) Search(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
 	// Set defaults
 	if req.TopK <= 0 {
 		req.TopK = 5
 	}
+	budget, err := req.charBudget()
+	if err != nil {
+		return nil, err
+	}
+
+	// Transform query into the texts to embed
+	queries, err := s.expandQuery(ctx, req.Query, req.QueryMode, req.NumQueries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %w", err)
+	}
 
 	// Embed query
-	embeddings, err := s.embeddingClient.Embed(ctx, []string{req.Query})
+	embeddings, usage, err := s.embeddingClient.EmbedWithUsage(ctx, queries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
+	s.recordUsage("search", usage)
 
 	if len(embeddings) == 0 {
 		return nil, fmt.Errorf("no embedding returned for query")
 	}
 
-	queryEmbedding := embeddings[0]
+	// Search database once per query embedding
+	resultSets := make([][]storage.SearchResult, len(embeddings))
+	for i, queryEmbedding := range embeddings {
+		resultSets[i], err = s.db.Search(queryEmbedding, req.TopK, req.MinScore, req.SourceFilter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search database: %w", err)
+		}
+	}
 
-	// Search database
-	results, err := s.db.Search(queryEmbedding, req.TopK, req.MinScore, req.SourceFilter)
-	if err != nil {
-		return nil, fmt.Errorf("failed to search database: %w", err)
+	results := resultSets[0]
+	if len(resultSets) > 1 {
+		results = fuseResults(resultSets, req.TopK)
 	}
 
 	// Convert to response format
-	items := make([]SearchResultItem, len(results))
-	for i, result := range results {
-		items[i] = SearchResultItem{
-			Content:    result.Content,
-			Source:     result.Source,
-			ChunkIndex: result.ChunkIndex,
-			Score:      result.Score,
-		}
+	items := toResultItems(results)
+
+	// Fit results to the budget
+	omitted := 0
+	if budget > 0 {
+		items, omitted = packResults(items, budget)
 	}
 
-	return &SearchResponse{
+	resp := &SearchResponse{
 		Results: items,
 		Count:   len(items),
-	}, nil
+		Usage:   &usage,
+		Omitted: omitted,
+	}
+	if req.QueryMode != QueryModeNone {
+		resp.ExpandedQueries = queries
+	}
+
+	return resp, nil
 }
 
-// Index indexes content for search
-func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse, error) {
-	// Validate exactly one source is provided
-	hasFilePath := req.FilePath != ""
-	hasURL := req.URL != ""
-	hasContent := req.Content != "" && req.Source != ""
+// toResultItems converts storage results to response items
+func toResultItems(results []storage.SearchResult) []SearchResultItem {
+	items := make([]SearchResultItem, len(results))
+	for i, result := range results {
+		items[i] = SearchResultItem{
+			Content:     result.Content,
+			Source:      result.Source,
+			Title:       result.Title,
+			ChunkIndex:  result.ChunkIndex,
+			StartOffset: result.StartOffset,
+			EndOffset:   result.EndOffset,
+			Score:       result.Score,
+		}
+	}
+	return items
+}
 
+// validate checks that exactly one source is provided, and the refresh
+// interval if one is set
+func (req IndexRequest) validate() error {
 	sourceCount := 0
-	if hasFilePath {
+	if req.FilePath != "" {
 		sourceCount++
 	}
-	if hasURL {
+	if req.URL != "" {
 		sourceCount++
 	}
-	if hasContent {
+	if req.Content != "" && req.Source != "" {
 		sourceCount++
 	}
 
 	if sourceCount == 0 {
-		return nil, fmt.Errorf("must provide exactly one of: file_path, url, or (content + source)")
+		return fmt.Errorf("must provide exactly one of: file_path, url, or (content + source)")
 	}
 	if sourceCount > 1 {
-		return nil, fmt.Errorf("provide exactly one of: file_path, url, or (content + source)")
+		return fmt.Errorf("provide exactly one of: file_path, url, or (content + source)")
+	}
+	if req.RefreshInterval != nil {
+		if req.URL == "" {
+			return fmt.Errorf("refresh interval is only supported for url sources")
+		}
+		if err := validateRefreshInterval(*req.RefreshInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target returns the source a request indexes
+func (req IndexRequest) target() string {
+	switch {
+	case req.FilePath != "":
+		return req.FilePath
+	case req.URL != "":
+		return req.URL
+	default:
+		return req.Source
+	}
+}
+
+// Index indexes content for search
+func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	hasFilePath := req.FilePath != ""
+	hasURL := req.URL != ""
+
+	// A resumed job does not redo a document it already indexed
+	done, err := s.indexedSince(req.target(), req.ResumeSince)
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		if err := s.applyRefreshInterval(done.Source, req.RefreshInterval); err != nil {
+			return nil, err
+		}
+		reportProgress(ctx, Progress{Phase: PhaseDone, Source: done.Source, Message: fmt.Sprintf("%s was already indexed", done.Source)})
+		return &IndexResponse{
+			Source:     done.Source,
+			SourceType: done.SourceType,
+			Status:     PageStatusIndexed,
+			ChunkCount: done.ChunkCount,
+			Message:    fmt.Sprintf("Successfully indexed %s (%d chunks) before the job was interrupted", done.Source, done.ChunkCount),
+		}, nil
+	}
+
+	// Without reindex an existing document is not fetched again; a request
+	// that only sets its refresh interval applies it
+	if !req.Reindex {
+		existing, err := s.db.GetDocument(req.target())
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up document: %w", err)
+		}
+		if existing != nil {
+			if req.RefreshInterval == nil {
+				return nil, fmt.Errorf("%w: %s (use reindex=true to force re-indexing)", ErrAlreadyIndexed, existing.Source)
+			}
+			if err := s.applyRefreshInterval(existing.Source, req.RefreshInterval); err != nil {
+				return nil, err
+			}
+			reportProgress(ctx, Progress{Phase: PhaseDone, Source: existing.Source, Message: fmt.Sprintf("%s was already indexed", existing.Source)})
+			return &IndexResponse{
+				Source:     existing.Source,
+				SourceType: existing.SourceType,
+				Status:     PageStatusUnchanged,
+				ChunkCount: existing.ChunkCount,
+				Message:    fmt.Sprintf("%s is already indexed; refresh interval set to %s", existing.Source, *req.RefreshInterval),
+			}, nil
+		}
 	}
 
 	var content, source, sourceType, title string
-	var err error
+	var validators fetcher.Validators
 
 	// Fetch content based on source type
 	if hasFilePath {
 		source = req.FilePath
 		sourceType = "file"
-		contentBytes, readErr := os.ReadFile(req.FilePath)
+		file, openErr := s.files.open(req.FilePath)
+		if openErr != nil {
+			return nil, openErr
+		}
+		reportProgress(ctx, Progress{Phase: PhaseFetch, Source: source, Message: fmt.Sprintf("Reading %s", source)})
+		contentBytes, readErr := io.ReadAll(file)
+		file.Close()
 		if readErr != nil {
 			return nil, fmt.Errorf("failed to read file: %w", readErr)
 		}
@@ -183,12 +397,44 @@ func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse,
 	} else if hasURL {
 		source = req.URL
 		sourceType = "url"
-		fetchResult, fetchErr := s.fetcher.FetchURL(ctx, req.URL)
+
+		// On reindex, revalidate with the stored ETag / Last-Modified
+		var existing *storage.Document
+		if req.Reindex {
+			var err error
+			existing, err = s.db.GetDocument(req.URL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up document: %w", err)
+			}
+			if existing != nil {
+				validators = fetcher.Validators{ETag: existing.ETag, LastModified: existing.LastModified}
+			}
+		}
+
+		reportProgress(ctx, Progress{Phase: PhaseFetch, Source: source, Message: fmt.Sprintf("Fetching %s", source)})
+		fetchResult, fetchErr := s.fetcher.FetchURLConditional(ctx, req.URL, validators)
 		if fetchErr != nil {
 			return nil, fmt.Errorf("failed to fetch URL: %w", fetchErr)
 		}
+		if fetchResult.NotModified && existing != nil {
+			if err := s.db.UpdateValidators(source, fetchResult.ETag, fetchResult.LastModified); err != nil {
+				return nil, err
+			}
+			if err := s.applyRefreshInterval(source, req.RefreshInterval); err != nil {
+				return nil, err
+			}
+			reportProgress(ctx, Progress{Phase: PhaseDone, Source: source, Message: fmt.Sprintf("%s is unchanged", source)})
+			return &IndexResponse{
+				Source:     source,
+				SourceType: sourceType,
+				Status:     PageStatusUnchanged,
+				ChunkCount: existing.ChunkCount,
+				Message:    fmt.Sprintf("%s is unchanged since it was last indexed", source),
+			}, nil
+		}
 		content = fetchResult.Content
 		title = fetchResult.Title
+		validators = fetcher.Validators{ETag: fetchResult.ETag, LastModified: fetchResult.LastModified}
 	} else {
 		// Direct content
 		source = req.Source
@@ -196,14 +442,63 @@ func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse,
 		content = req.Content
 	}
 
+	resp, err := s.indexContent(ctx, storage.DocumentMeta{
+		Source:     source,
+		SourceType: sourceType,
+		Title:      title,
+
+		ETag:         validators.ETag,
+		LastModified: validators.LastModified,
+	}, content, req.Reindex)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyRefreshInterval(source, req.RefreshInterval); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// indexedSince returns the document for source if it was indexed at or after
+// since, or nil; a zero since always returns nil
+func (s *Service) indexedSince(source string, since time.Time) (*storage.Document, error) {
+	if since.IsZero() {
+		return nil, nil
+	}
+	doc, err := s.db.GetDocument(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up document: %w", err)
+	}
+	if doc == nil || doc.IndexedAt.Before(since) {
+		return nil, nil
+	}
+	return doc, nil
+}
+
+// applyRefreshInterval stores a requested refresh interval for a document
+func (s *Service) applyRefreshInterval(source string, interval *time.Duration) error {
+	if interval == nil {
+		return nil
+	}
+	if err := s.db.SetRefreshInterval(source, *interval); err != nil {
+		return fmt.Errorf("failed to set refresh interval: %w", err)
+	}
+	return nil
+}
+
+// indexContent chunks, embeds and stores content under meta.Source
+func (s *Service) indexContent(ctx context.Context, meta storage.DocumentMeta, content string, reindex bool) (*IndexResponse, error) {
+	source := meta.Source
+
 	// Check if already indexed
-	if !req.Reindex {
+	if !reindex {
 		exists, err := s.db.DocumentExists(source)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check if document exists: %w", err)
 		}
 		if exists {
-			return nil, fmt.Errorf("document already indexed: %s (use reindex=true to force re-indexing)", source)
+			return nil, fmt.Errorf("%w: %s (use reindex=true to force re-indexing)", ErrAlreadyIndexed, source)
 		}
 	}
 
@@ -213,6 +508,13 @@ func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse,
 		return nil, fmt.Errorf("no chunks generated from content (is the content empty?)")
 	}
 
+	reportProgress(ctx, Progress{
+		Phase:       PhaseChunk,
+		Source:      source,
+		ChunksTotal: len(chunks),
+		Message:     fmt.Sprintf("Split %s into %d chunks", source, len(chunks)),
+	})
+
 	// Extract chunk texts for embedding
 	chunkTexts := make([]string, len(chunks))
 	for i, chunk := range chunks {
@@ -220,7 +522,16 @@ func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse,
 	}
 
 	// Embed all chunks
-	chunkEmbeddings, err := s.embeddingClient.Embed(ctx, chunkTexts)
+	chunkEmbeddings, usage, err := s.embeddingClient.EmbedWithProgress(ctx, chunkTexts, func(done, total int) {
+		reportProgress(ctx, Progress{
+			Phase:          PhaseEmbed,
+			Source:         source,
+			ChunksEmbedded: done,
+			ChunksTotal:    total,
+			Message:        fmt.Sprintf("Embedded %d of %d chunks", done, total),
+		})
+	})
+	s.recordUsage("index", usage)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed chunks: %w", err)
 	}
@@ -242,16 +553,36 @@ func (s *Service) Index(ctx context.Context, req IndexRequest) (*IndexResponse,
 	}
 
 	// Store in database
-	err = s.db.IndexDocument(source, sourceType, title, storageChunks)
+	reportProgress(ctx, Progress{
+		Phase:          PhaseStore,
+		Source:         source,
+		ChunksEmbedded: len(chunks),
+		ChunksTotal:    len(chunks),
+		Message:        fmt.Sprintf("Storing %d chunks", len(chunks)),
+	})
+	meta.EmbeddingTokens = usage.Tokens
+	meta.EmbeddingCost = usage.Cost
+	err = s.db.IndexDocument(meta, storageChunks)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store document: %w", err)
 	}
+	reportProgress(ctx, Progress{
+		Phase:          PhaseDone,
+		Source:         source,
+		ChunksEmbedded: len(chunks),
+		ChunksTotal:    len(chunks),
+		Message:        fmt.Sprintf("Indexed %s (%d chunks)", source, len(chunks)),
+	})
 
 	return &IndexResponse{
 		Source:     source,
-		SourceType: sourceType,
+		SourceType: meta.SourceType,
+		Status:     PageStatusIndexed,
 		ChunkCount: len(chunks),
 		Message:    fmt.Sprintf("Successfully indexed %s (%d chunks)", source, len(chunks)),
+
+		EmbeddingTokens: usage.Tokens,
+		EstimatedCost:   usage.Cost,
 	}, nil
 }
 
@@ -271,6 +602,23 @@ func (s *Service) List(ctx context.Context, req ListRequest) (*ListResponse, err
 			ContentSize: doc.ContentSize,
 			IndexedAt:   doc.IndexedAt.Format("2006-01-02 15:04:05"),
 			Title:       doc.Title,
+
+			EmbeddingTokens: doc.EmbeddingTokens,
+			EmbeddingCost:   doc.EmbeddingCost,
+
+			LastCheckStatus: doc.LastCheckStatus,
+			LastCheckError:  doc.LastCheckError,
+		}
+		if !doc.LastCheckedAt.IsZero() {
+			docInfos[i].LastCheckedAt = doc.LastCheckedAt.Format("2006-01-02 15:04:05")
+		}
+		if doc.RefreshInterval > 0 {
+			lastChecked := doc.IndexedAt
+			if doc.LastCheckedAt.After(lastChecked) {
+				lastChecked = doc.LastCheckedAt
+			}
+			docInfos[i].RefreshInterval = doc.RefreshInterval.String()
+			docInfos[i].NextRefreshAt = lastChecked.Add(doc.RefreshInterval).Format("2006-01-02 15:04:05")
 		}
 	}
 
@@ -297,3 +645,63 @@ func (s *Service) Delete(ctx context.Context, req DeleteRequest) (*DeleteRespons
 		Message: fmt.Sprintf("Successfully deleted %s", req.Source),
 	}, nil
 }
+
+// Stats returns index size, embedding spend and cache statistics
+func (s *Service) Stats(ctx context.Context, req StatsRequest) (*StatsResponse, error) {
+	documents, chunks, err := s.db.CountDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	totals, err := s.db.UsageTotals()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage totals: %w", err)
+	}
+
+	docs, err := s.db.ListDocuments("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	resp := &StatsResponse{
+		DocumentCount:  documents,
+		ChunkCount:     chunks,
+		Operations:     make([]OperationSpend, len(totals)),
+		SpendBySource:  make([]SourceSpend, len(docs)),
+		EmbeddingCache: s.embeddingClient.CacheStats(),
+	}
+
+	for i, total := range totals {
+		resp.Operations[i] = OperationSpend{
+			Operation: total.Operation,
+			Tokens:    total.Tokens,
+			Cost:      total.Cost,
+		}
+		resp.TotalTokens += total.Tokens
+		resp.TotalCost += total.Cost
+	}
+
+	for i, doc := range docs {
+		resp.SpendBySource[i] = SourceSpend{
+			Source: doc.Source,
+			Tokens: doc.EmbeddingTokens,
+			Cost:   doc.EmbeddingCost,
+		}
+	}
+	sort.SliceStable(resp.SpendBySource, func(i, j int) bool {
+		return resp.SpendBySource[i].Tokens > resp.SpendBySource[j].Tokens
+	})
+
+	return resp, nil
+}
+
+// recordUsage adds embedding spend to the persisted per-operation totals.
+// Accounting failures are logged rather than failing the operation.
+func (s *Service) recordUsage(operation string, usage embeddings.Usage) {
+	if usage.Tokens == 0 && usage.Requests == 0 {
+		return
+	}
+	if err := s.db.RecordUsage(operation, usage.Tokens, usage.Cost); err != nil {
+		log.Printf("Failed to record %s usage: %v", operation, err)
+	}
+}
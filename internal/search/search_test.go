@@ -0,0 +1,130 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReindexUnchangedURL(t *testing.T) {
+	s, requests, path := newTestService(t, nil)
+	ctx := context.Background()
+
+	page := &testPage{body: "The original text of the page.", etag: `"v1"`}
+	url := newTestPage(t, page)
+	first, err := s.Index(ctx, IndexRequest{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != PageStatusIndexed {
+		t.Fatalf("Expected the page to be indexed, got %+v", first)
+	}
+	// Move indexed_at back so a rewrite would show
+	backdateDocuments(t, path, time.Hour)
+	before, _ := s.db.GetDocument(url)
+	embedded := atomic.LoadInt32(requests)
+
+	// The stored ETag is sent back and the 304 leaves the chunks alone
+	resp, err := s.Index(ctx, IndexRequest{URL: url, Reindex: true})
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if resp.Status != PageStatusUnchanged || resp.ChunkCount != first.ChunkCount {
+		t.Errorf("Expected an unchanged response with %d chunks, got %+v", first.ChunkCount, resp)
+	}
+	if n := atomic.LoadInt32(requests); n != embedded {
+		t.Errorf("Expected no embedding requests for an unchanged page, got %d", n-embedded)
+	}
+	after, _ := s.db.GetDocument(url)
+	if !after.IndexedAt.Equal(before.IndexedAt) || after.ChunkCount != before.ChunkCount || after.ETag != `"v1"` {
+		t.Errorf("Expected the document to be untouched, was %+v, now %+v", before, after)
+	}
+	if text := searchText(t, s); !strings.Contains(text, "original text") {
+		t.Errorf("Expected the original chunks to remain, got %q", text)
+	}
+
+	// A changed page is fetched in full and replaces the chunks
+	page.set("The rewritten text of the page.", `"v2"`, 0)
+	resp, err = s.Index(ctx, IndexRequest{URL: url, Reindex: true})
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if resp.Status != PageStatusIndexed || atomic.LoadInt32(requests) == embedded {
+		t.Errorf("Expected a changed page to be re-embedded, got %+v", resp)
+	}
+	if text := searchText(t, s); !strings.Contains(text, "rewritten text") {
+		t.Errorf("Expected the new chunks, got %q", text)
+	}
+
+	page.mu.Lock()
+	defer page.mu.Unlock()
+	if page.fetches != 3 {
+		t.Errorf("Expected 3 fetches, got %d", page.fetches)
+	}
+}
+
+func TestReindexStoresValidatorsFrom304(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	ctx := context.Background()
+
+	// The server answers every revalidation with 304 and a fresh ETag
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			sent = append(sent, inm)
+			w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, len(sent)+1))
+			w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body><p>A page with rotating validators.</p></body></html>")
+	}))
+	defer server.Close()
+	url := server.URL + "/page"
+
+	if _, err := s.Index(ctx, IndexRequest{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := s.Index(ctx, IndexRequest{URL: url, Reindex: true}); err != nil || resp.Status != PageStatusUnchanged {
+			t.Fatalf("Expected an unchanged reindex, got %+v, %v", resp, err)
+		}
+	}
+
+	doc, err := s.db.GetDocument(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ETag != `"v3"` || doc.LastModified != "Wed, 01 Jan 2025 00:00:00 GMT" {
+		t.Errorf("Expected the validators from the last 304 to be stored, got %q and %q", doc.ETag, doc.LastModified)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(sent, ",") != `"v1","v2"` {
+		t.Errorf("Expected each revalidation to send the latest ETag, got %v", sent)
+	}
+}
+
+// searchText returns the content of the top search result
+func searchText(t *testing.T, s *Service) string {
+	t.Helper()
+	resp, err := s.Search(context.Background(), SearchRequest{Query: "text", TopK: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) == 0 {
+		return ""
+	}
+	return resp.Results[0].Content
+}
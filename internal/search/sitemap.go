@@ -0,0 +1,253 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/fetcher"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+)
+
+const (
+	defaultSitemapConcurrency = 4
+	maxSitemapConcurrency     = 16
+)
+
+// SitemapRequest represents a request to index every URL in a sitemap
+type SitemapRequest struct {
+	SitemapURL  string
+	MaxPages    int
+	Concurrency int
+	Reindex     bool
+
+	// ResumeSince marks a resumed job: pages indexed at or after this time
+	// were written by an earlier attempt and are not indexed again
+	ResumeSince time.Time `json:"-"`
+}
+
+// SitemapResponse summarizes a sitemap ingestion
+type SitemapResponse struct {
+	SitemapURL     string       `json:"sitemap_url"`
+	URLsListed     int          `json:"urls_listed"`
+	PagesIndexed   int          `json:"pages_indexed"`
+	PagesUnchanged int          `json:"pages_unchanged"`
+	PagesSkipped   int          `json:"pages_skipped"`
+	PagesFailed    int          `json:"pages_failed"`
+	ChunkCount     int          `json:"chunk_count"`
+	Pages          []PageResult `json:"pages"`
+	Message        string       `json:"message"`
+
+	// FailedSitemaps lists nested sitemap files that could not be read;
+	// the pages they list are missing from the ingestion
+	FailedSitemaps []PageResult `json:"failed_sitemaps,omitempty"`
+
+	EmbeddingTokens int     `json:"embedding_tokens"`
+	EstimatedCost   float64 `json:"estimated_cost_usd"`
+}
+
+// sitemapFetch is the outcome of fetching one sitemap URL
+type sitemapFetch struct {
+	entry  fetcher.SitemapEntry
+	result *fetcher.FetchResult
+	err    error
+}
+
+// IndexSitemap fetches a sitemap (or sitemap index) and indexes each listed
+// URL as its own document. Pages whose <lastmod> is not newer than their
+// stored indexed_at are reported as unchanged; pages with a newer lastmod are
+// re-indexed even without Reindex.
+func (s *Service) IndexSitemap(ctx context.Context, req SitemapRequest) (*SitemapResponse, error) {
+	if req.SitemapURL == "" {
+		return nil, fmt.Errorf("sitemap URL is required")
+	}
+
+	maxPages := req.MaxPages
+	if maxPages <= 0 {
+		maxPages = maxCrawlPages
+	}
+	if maxPages > maxCrawlPages {
+		return nil, fmt.Errorf("max_pages must be at most %d, got %d", maxCrawlPages, maxPages)
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultSitemapConcurrency
+	}
+	if concurrency > maxSitemapConcurrency {
+		concurrency = maxSitemapConcurrency
+	}
+
+	entries, failures, err := s.fetcher.FetchSitemap(ctx, req.SitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sitemap: %w", err)
+	}
+
+	resp := &SitemapResponse{
+		SitemapURL: req.SitemapURL,
+		URLsListed: len(entries),
+		Pages:      []PageResult{},
+	}
+	for _, failure := range failures {
+		resp.FailedSitemaps = append(resp.FailedSitemaps, PageResult{URL: failure.URL, Status: PageStatusFailed, Error: failure.Err.Error()})
+	}
+
+	// Decide which entries need fetching
+	var pending []fetcher.SitemapEntry
+	reindex := make(map[string]bool)
+	validators := make(map[string]fetcher.Validators)
+	seen := make(map[string]bool)
+	resumed := 0
+	for _, entry := range entries {
+		if seen[entry.Loc] {
+			continue
+		}
+		seen[entry.Loc] = true
+
+		existing, err := s.db.GetDocument(entry.Loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", entry.Loc, err)
+		}
+
+		// Pages a resumed job indexed before it was interrupted count
+		// towards max_pages as they did the first time
+		if existing != nil && !req.ResumeSince.IsZero() && !existing.IndexedAt.Before(req.ResumeSince) {
+			if len(pending)+resumed >= maxPages {
+				break
+			}
+			resumed++
+			resp.PagesIndexed++
+			resp.ChunkCount += existing.ChunkCount
+			resp.Pages = append(resp.Pages, PageResult{URL: entry.Loc, Status: PageStatusIndexed, ChunkCount: existing.ChunkCount})
+			continue
+		}
+
+		if existing != nil && !req.Reindex {
+			lastMod, ok := entry.LastModified()
+			switch {
+			case !ok:
+				resp.PagesSkipped++
+				resp.Pages = append(resp.Pages, PageResult{URL: entry.Loc, Status: PageStatusSkipped, Error: "already indexed"})
+				continue
+			case !lastMod.After(existing.IndexedAt):
+				resp.PagesUnchanged++
+				resp.Pages = append(resp.Pages, PageResult{URL: entry.Loc, Status: PageStatusUnchanged})
+				continue
+			}
+		}
+
+		if len(pending)+resumed >= maxPages {
+			break
+		}
+		if existing != nil {
+			reindex[entry.Loc] = true
+			validators[entry.Loc] = fetcher.Validators{ETag: existing.ETag, LastModified: existing.LastModified}
+		}
+		pending = append(pending, entry)
+	}
+
+	// Fetch concurrently; index sequentially as results arrive
+	jobs := make(chan fetcher.SitemapEntry)
+	fetched := make(chan sitemapFetch)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				result, err := s.fetcher.FetchURLConditional(ctx, entry.Loc, validators[entry.Loc])
+				fetched <- sitemapFetch{entry: entry, result: result, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, entry := range pending {
+			select {
+			case jobs <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(fetched)
+	}()
+
+	pagesDone := 0
+	for f := range fetched {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		result := PageResult{URL: f.entry.Loc}
+		if f.err != nil {
+			result.Status = PageStatusFailed
+			result.Error = f.err.Error()
+			resp.PagesFailed++
+			resp.Pages = append(resp.Pages, result)
+			pagesDone++
+			reportPageDone(ctx, f.entry.Loc, pagesDone, len(pending))
+			continue
+		}
+		if f.result.NotModified {
+			if err := s.db.UpdateValidators(f.entry.Loc, f.result.ETag, f.result.LastModified); err != nil {
+				log.Printf("Failed to update validators for %s: %v", f.entry.Loc, err)
+			}
+			result.Status = PageStatusUnchanged
+			resp.PagesUnchanged++
+			resp.Pages = append(resp.Pages, result)
+			pagesDone++
+			reportPageDone(ctx, f.entry.Loc, pagesDone, len(pending))
+			continue
+		}
+
+		pageCtx := withPage(ctx, pagesDone+1, pagesDone, len(pending))
+		reportProgress(pageCtx, Progress{Phase: PhaseFetch, Source: f.entry.Loc, Message: fmt.Sprintf("Fetched %s", f.entry.Loc)})
+		indexResp, err := s.indexContent(pageCtx, storage.DocumentMeta{
+			Source:     f.entry.Loc,
+			SourceType: "url",
+			Title:      f.result.Title,
+
+			ETag:         f.result.ETag,
+			LastModified: f.result.LastModified,
+		}, f.result.Content, req.Reindex || reindex[f.entry.Loc])
+
+		switch {
+		case err == nil:
+			result.Status = PageStatusIndexed
+			result.ChunkCount = indexResp.ChunkCount
+			resp.PagesIndexed++
+			resp.ChunkCount += indexResp.ChunkCount
+			resp.EmbeddingTokens += indexResp.EmbeddingTokens
+			resp.EstimatedCost += indexResp.EstimatedCost
+		case errors.Is(err, ErrAlreadyIndexed):
+			result.Status = PageStatusSkipped
+			result.Error = "already indexed"
+			resp.PagesSkipped++
+		default:
+			result.Status = PageStatusFailed
+			result.Error = err.Error()
+			resp.PagesFailed++
+		}
+		resp.Pages = append(resp.Pages, result)
+		pagesDone++
+		reportPageDone(ctx, f.entry.Loc, pagesDone, len(pending))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resp.Message = fmt.Sprintf("Indexed sitemap %s: %d indexed, %d unchanged, %d skipped, %d failed (%d chunks)",
+		req.SitemapURL, resp.PagesIndexed, resp.PagesUnchanged, resp.PagesSkipped, resp.PagesFailed, resp.ChunkCount)
+	if n := len(resp.FailedSitemaps); n > 0 {
+		resp.Message += fmt.Sprintf("; %d nested sitemap files could not be read", n)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIndexSitemapUsesLastmod(t *testing.T) {
+	s, _, _ := newTestService(t, nil)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset>
+  <url><loc>%[1]s/old</loc><lastmod>2000-01-01</lastmod></url>
+  <url><loc>%[1]s/new</loc><lastmod>%[2]s</lastmod></url>
+  <url><loc>%[1]s/undated</loc></url>
+</urlset>`, server.URL, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	})
+	for _, path := range []string{"/old", "/new", "/undated"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			fetches[r.URL.Path]++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><body><p>The %s page.</p></body></html>", r.URL.Path)
+		})
+	}
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	// A missing child sitemap is reported without failing the ingestion
+	resp, err := s.IndexSitemap(ctx, SitemapRequest{SitemapURL: server.URL + "/sitemap_index.xml"})
+	if err != nil {
+		t.Fatalf("IndexSitemap failed: %v", err)
+	}
+	if resp.PagesIndexed != 3 || len(resp.FailedSitemaps) != 1 || resp.FailedSitemaps[0].URL != server.URL+"/missing.xml" {
+		t.Fatalf("Expected 3 pages indexed and the missing sitemap recorded, got %+v", resp)
+	}
+
+	// Without reindex, only the page whose lastmod is newer than its
+	// indexed_at is fetched again
+	resp, err = s.IndexSitemap(ctx, SitemapRequest{SitemapURL: server.URL + "/sitemap_index.xml"})
+	if err != nil {
+		t.Fatalf("IndexSitemap failed: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, page := range resp.Pages {
+		statuses[page.URL[len(server.URL):]] = page.Status
+	}
+	want := map[string]string{"/old": PageStatusUnchanged, "/new": PageStatusIndexed, "/undated": PageStatusSkipped}
+	for path, status := range want {
+		if statuses[path] != status {
+			t.Errorf("Expected %s to be %s, got %q", path, status, statuses[path])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches["/old"] != 1 || fetches["/undated"] != 1 || fetches["/new"] != 2 {
+		t.Errorf("Expected only the newer page to be fetched again, got %v", fetches)
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+)
+
+// newTestDatabase opens a database in a temporary directory
+func newTestDatabase(t *testing.T, path string) *Database {
+	t.Helper()
+	sqlite_vec.Auto()
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testEmbedding returns a distinct unit-ish vector for seed
+func testEmbedding(seed int) []float32 {
+	embedding := make([]float32, embeddingDimension)
+	embedding[seed%embeddingDimension] = 1
+	embedding[(seed+1)%embeddingDimension] = 0.5
+	return embedding
+}
+
+func testDocumentChunks(seed, n int) []Chunk {
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{ChunkIndex: i, Content: fmt.Sprintf("chunk %d of %d", i, seed), EndOffset: 10, Embedding: testEmbedding(seed + i)}
+	}
+	return chunks
+}
+
+func TestConnectionSettings(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	for name, conn := range map[string]*sql.DB{"reader": db.db, "writer": db.writer} {
+		var mode string
+		if err := conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatal(err)
+		}
+		if mode != "wal" {
+			t.Errorf("%s: expected WAL journal mode, got %q", name, mode)
+		}
+
+		var foreignKeys, timeout int
+		if err := conn.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+			t.Fatal(err)
+		}
+		if foreignKeys != 1 || timeout != int(busyTimeout.Milliseconds()) {
+			t.Errorf("%s: expected foreign_keys=1 busy_timeout=%d, got %d and %d", name, busyTimeout.Milliseconds(), foreignKeys, timeout)
+		}
+	}
+}
+
+func TestDeleteDocumentCascadesToChunks(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	if err := db.IndexDocument(DocumentMeta{Source: "a", SourceType: "file"}, testDocumentChunks(1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	// Re-indexing replaces the chunks rather than adding to them
+	if err := db.IndexDocument(DocumentMeta{Source: "a", SourceType: "file"}, testDocumentChunks(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, chunks, _ := db.CountDocuments(); chunks != 2 {
+		t.Errorf("Expected 2 chunks after re-index, got %d", chunks)
+	}
+
+	if err := db.DeleteDocument("a"); err != nil {
+		t.Fatal(err)
+	}
+	documents, chunks, err := db.CountDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documents != 0 || chunks != 0 {
+		t.Errorf("Expected delete to remove document and chunks, got %d documents and %d chunks", documents, chunks)
+	}
+}
+
+func TestMigrationsRemoveOrphanedChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db := newTestDatabase(t, path)
+	db.Close()
+
+	// Simulate a chunk left behind before foreign keys were enforced
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Exec("INSERT INTO chunks (document_id, chunk_index, content, start_offset, end_offset, embedding) VALUES (42, 0, 'orphan', 0, 6, x'00')"); err != nil {
+		t.Fatal(err)
+	}
+	raw.Close()
+
+	db = newTestDatabase(t, path)
+	if _, chunks, _ := db.CountDocuments(); chunks != 0 {
+		t.Errorf("Expected orphaned chunk to be removed, got %d chunks", chunks)
+	}
+}
+
+func TestConcurrentIndexAndSearch(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	const writers, readers, perWriter = 4, 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*2+readers*perWriter)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				seed := w*perWriter + i
+				meta := DocumentMeta{Source: fmt.Sprintf("doc-%d", seed), SourceType: "file", EmbeddingTokens: 1}
+				if err := db.IndexDocument(meta, testDocumentChunks(seed, 3)); err != nil {
+					errs <- fmt.Errorf("index %d: %w", seed, err)
+				}
+				if err := db.RecordUsage("index", 1, 0.001); err != nil {
+					errs <- fmt.Errorf("usage %d: %w", seed, err)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := db.Search(testEmbedding(r+i), 5, 0, ""); err != nil {
+					errs <- fmt.Errorf("search: %w", err)
+				}
+				if _, err := db.ListDocuments(""); err != nil {
+					errs <- fmt.Errorf("list: %w", err)
+				}
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	documents, chunks, err := db.CountDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documents != writers*perWriter || chunks != writers*perWriter*3 {
+		t.Errorf("Expected %d documents and %d chunks, got %d and %d", writers*perWriter, writers*perWriter*3, documents, chunks)
+	}
+}
+
+func TestConcurrentWritersAcrossConnections(t *testing.T) {
+	// Two Database values on one file stand in for two server processes
+	path := filepath.Join(t.TempDir(), "test.db")
+	dbs := []*Database{newTestDatabase(t, path), newTestDatabase(t, path)}
+
+	const perDB = 15
+	var wg sync.WaitGroup
+	errs := make(chan error, len(dbs)*perDB)
+	for n, db := range dbs {
+		wg.Add(1)
+		go func(n int, db *Database) {
+			defer wg.Done()
+			for i := 0; i < perDB; i++ {
+				// Both write the same sources, so each index also replaces
+				meta := DocumentMeta{Source: fmt.Sprintf("doc-%d", i), SourceType: "file"}
+				if err := db.IndexDocument(meta, testDocumentChunks(n*perDB+i, 2)); err != nil {
+					errs <- err
+				}
+			}
+		}(n, db)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+
+	documents, chunks, err := dbs[0].CountDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documents != perDB || chunks != perDB*2 {
+		t.Errorf("Expected %d documents and %d chunks, got %d and %d", perDB, perDB*2, documents, chunks)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/binary"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -12,11 +13,28 @@ import (
 
 const (
 	embeddingDimension = 1536
+
+	// busyTimeout is how long a connection waits for a lock held by another
+	// connection or process before failing with "database is locked"
+	busyTimeout = 5 * time.Second
+
+	// cacheLookupBatch is the number of hashes per embedding cache query,
+	// well under SQLite's limit on bound parameters
+	cacheLookupBatch = 500
 )
 
-// Database handles SQLite operations with vector search
+// Database handles SQLite operations with vector search.
+//
+// The database runs in WAL mode so searches never wait for an index in
+// progress. Reads use a pool of connections; every write goes through a
+// single writer connection, so writes from this process queue up instead of
+// failing with "database is locked", and writer transactions take the write
+// lock up front so they wait (up to busyTimeout) on writers in other
+// processes rather than deadlocking with them.
 type Database struct {
-	db *sql.DB
+	db     *sql.DB
+	writer *sql.DB
+	path   string
 }
 
 // Document represents a document in the database
@@ -28,6 +46,42 @@ type Document struct {
 	ContentSize int
 	ChunkCount  int
 	Title       string
+
+	// Cumulative embedding spend across all (re)indexes of this source
+	EmbeddingTokens int
+	EmbeddingCost   float64
+
+	// HTTP validators from the last fetch of a url document
+	ETag         string
+	LastModified string
+
+	// Scheduled refresh: interval (0 disables) and the outcome of the last check
+	RefreshInterval time.Duration
+	LastCheckedAt   time.Time
+	LastCheckStatus string
+	LastCheckError  string
+}
+
+// DocumentMeta describes a document being indexed
+type DocumentMeta struct {
+	Source     string
+	SourceType string
+	Title      string
+
+	// Embedding spend for this indexing run, added to the document's totals
+	EmbeddingTokens int
+	EmbeddingCost   float64
+
+	// HTTP validators for conditional re-fetching
+	ETag         string
+	LastModified string
+}
+
+// UsageTotal is the cumulative embedding spend for an operation type
+type UsageTotal struct {
+	Operation string
+	Tokens    int
+	Cost      float64
 }
 
 // Chunk represents a text chunk with its embedding
@@ -43,41 +97,86 @@ type Chunk struct {
 
 // SearchResult represents a search result
 type SearchResult struct {
-	Content    string
-	Source     string
-	ChunkIndex int
-	Score      float64
+	Content     string
+	Source      string
+	Title       string
+	ChunkIndex  int
+	StartOffset int
+	EndOffset   int
+	Score       float64
 }
 
 // NewDatabase creates a new database connection and initializes schema
 // Note: sqlite_vec.Auto() must be called before creating the database
 func NewDatabase(dbPath string) (*Database, error) {
-	// Open database
-	db, err := sql.Open("sqlite3", dbPath)
+	// Open the writer first: it switches the file to WAL, which persists
+	writer, err := sql.Open("sqlite3", dsn(dbPath, true))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	writer.SetMaxOpenConns(1)
 
 	// Test if vec extension is loaded (should be auto-loaded via sqlite_vec.Auto())
 	var version string
-	err = db.QueryRow("SELECT vec_version()").Scan(&version)
+	err = writer.QueryRow("SELECT vec_version()").Scan(&version)
 	if err != nil {
-		db.Close()
+		writer.Close()
 		return nil, fmt.Errorf("sqlite-vec not available (did you call sqlite_vec.Auto()?): %w", err)
 	}
 
 	// Run migrations
-	if err := runMigrations(db); err != nil {
-		db.Close()
+	if err := runMigrations(writer); err != nil {
+		writer.Close()
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	return &Database{db: db}, nil
+	// An in-memory database is private to its connection, so it cannot be
+	// shared with a reader pool
+	if isMemoryDSN(dbPath) {
+		return &Database{db: writer, writer: writer, path: dbPath}, nil
+	}
+
+	db, err := sql.Open("sqlite3", dsn(dbPath, false))
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Database{db: db, writer: writer, path: dbPath}, nil
 }
 
-// Close closes the database connection
+// dsn adds connection settings to a database path. Writer connections also
+// switch the journal to WAL and begin transactions with BEGIN IMMEDIATE.
+func dsn(dbPath string, writer bool) string {
+	params := []string{
+		"_foreign_keys=on",
+		fmt.Sprintf("_busy_timeout=%d", busyTimeout.Milliseconds()),
+	}
+	if writer {
+		params = append(params, "_journal_mode=WAL", "_synchronous=NORMAL", "_txlock=immediate")
+	}
+
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + strings.Join(params, "&")
+}
+
+// isMemoryDSN reports whether a database path names an in-memory database
+func isMemoryDSN(dbPath string) bool {
+	return dbPath == ":memory:" || strings.HasPrefix(dbPath, ":memory:?") || strings.Contains(dbPath, "mode=memory")
+}
+
+// Close closes the database connections
 func (d *Database) Close() error {
-	return d.db.Close()
+	err := d.writer.Close()
+	if d.db != d.writer {
+		if closeErr := d.db.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // runMigrations creates the database schema
@@ -105,22 +204,128 @@ func runMigrations(db *sql.DB) error {
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_chunks_document_id ON chunks(document_id);
+
+	CREATE TABLE IF NOT EXISTS embedding_cache (
+		model TEXT NOT NULL,
+		text_hash TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (model, text_hash)
+	);
+
+	CREATE TABLE IF NOT EXISTS embedding_usage (
+		operation TEXT PRIMARY KEY,
+		tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		target TEXT NOT NULL,
+		request TEXT NOT NULL,
+		status TEXT NOT NULL,
+		progress TEXT NOT NULL DEFAULT '',
+		result TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		cancel_requested INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP,
+		heartbeat_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
 	`
 
-	_, err := db.Exec(schema)
-	return err
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema
+	columns := []struct{ table, name, decl string }{
+		{"documents", "embedding_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"documents", "embedding_cost", "REAL NOT NULL DEFAULT 0"},
+		{"documents", "etag", "TEXT NOT NULL DEFAULT ''"},
+		{"documents", "last_modified", "TEXT NOT NULL DEFAULT ''"},
+		{"documents", "refresh_interval", "INTEGER NOT NULL DEFAULT 0"},
+		{"documents", "last_checked_at", "TIMESTAMP"},
+		{"documents", "last_check_status", "TEXT NOT NULL DEFAULT ''"},
+		{"documents", "last_check_error", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(db, col.table, col.name, col.decl); err != nil {
+			return err
+		}
+	}
+
+	// Before foreign keys were enforced, deleting a document left its
+	// chunks behind; drop them so they no longer show up in searches
+	if _, err := db.Exec("DELETE FROM chunks WHERE document_id NOT IN (SELECT id FROM documents)"); err != nil {
+		return fmt.Errorf("failed to remove orphaned chunks: %w", err)
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present
+func addColumnIfMissing(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan column name: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating columns: %w", err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // IndexDocument stores a document with its chunks and embeddings
-func (d *Database) IndexDocument(source, sourceType, title string, chunks []Chunk) error {
-	tx, err := d.db.Begin()
+func (d *Database) IndexDocument(meta DocumentMeta, chunks []Chunk) error {
+	tx, err := d.writer.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Carry over spend, refresh schedule and the last refresh check from a
+	// previous index of this source; only RecordRefreshCheck writes checks
+	var priorTokens int
+	var priorCost float64
+	var refreshSeconds int64
+	var lastChecked sql.NullTime
+	var checkStatus, checkErr string
+	err = tx.QueryRow(
+		"SELECT embedding_tokens, embedding_cost, refresh_interval, last_checked_at, last_check_status, last_check_error FROM documents WHERE source = ?",
+		meta.Source,
+	).Scan(&priorTokens, &priorCost, &refreshSeconds, &lastChecked, &checkStatus, &checkErr)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read existing document: %w", err)
+	}
+	var priorCheck interface{}
+	if lastChecked.Valid {
+		priorCheck = lastChecked.Time.UTC().Format(timestampLayout)
+	}
+
 	// Delete existing document if it exists (for reindexing)
-	_, err = tx.Exec("DELETE FROM documents WHERE source = ?", source)
+	_, err = tx.Exec("DELETE FROM documents WHERE source = ?", meta.Source)
 	if err != nil {
 		return fmt.Errorf("failed to delete existing document: %w", err)
 	}
@@ -133,8 +338,11 @@ func (d *Database) IndexDocument(source, sourceType, title string, chunks []Chun
 
 	// Insert document
 	result, err := tx.Exec(
-		"INSERT INTO documents (source, source_type, content_size, chunk_count, title) VALUES (?, ?, ?, ?, ?)",
-		source, sourceType, contentSize, len(chunks), title,
+		`INSERT INTO documents (source, source_type, content_size, chunk_count, title, embedding_tokens, embedding_cost, etag, last_modified,
+			refresh_interval, last_checked_at, last_check_status, last_check_error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		meta.Source, meta.SourceType, contentSize, len(chunks), meta.Title,
+		priorTokens+meta.EmbeddingTokens, priorCost+meta.EmbeddingCost,
+		meta.ETag, meta.LastModified, refreshSeconds, priorCheck, checkStatus, checkErr,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert document: %w", err)
@@ -180,7 +388,7 @@ func (d *Database) Search(queryEmbedding []float32, topK int, minScore float64,
 
 	// Build query with optional source filter
 	query := `
-		SELECT c.content, d.source, c.chunk_index,
+		SELECT c.content, d.source, COALESCE(d.title, ''), c.chunk_index, c.start_offset, c.end_offset,
 		       (1 - vec_distance_cosine(c.embedding, ?)) AS score
 		FROM chunks c
 		JOIN documents d ON c.document_id = d.id
@@ -205,7 +413,7 @@ func (d *Database) Search(queryEmbedding []float32, topK int, minScore float64,
 	var results []SearchResult
 	for rows.Next() {
 		var result SearchResult
-		err := rows.Scan(&result.Content, &result.Source, &result.ChunkIndex, &result.Score)
+		err := rows.Scan(&result.Content, &result.Source, &result.Title, &result.ChunkIndex, &result.StartOffset, &result.EndOffset, &result.Score)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan search result: %w", err)
 		}
@@ -225,11 +433,7 @@ func (d *Database) Search(queryEmbedding []float32, topK int, minScore float64,
 
 // ListDocuments returns all indexed documents with optional source type filter
 func (d *Database) ListDocuments(sourceTypeFilter string) ([]Document, error) {
-	query := `
-		SELECT id, source, source_type, indexed_at, content_size, chunk_count, COALESCE(title, '')
-		FROM documents
-		WHERE 1=1
-	`
+	query := "SELECT " + documentColumns + " FROM documents WHERE 1=1"
 	args := []interface{}{}
 
 	if sourceTypeFilter != "" {
@@ -247,12 +451,11 @@ func (d *Database) ListDocuments(sourceTypeFilter string) ([]Document, error) {
 
 	var documents []Document
 	for rows.Next() {
-		var doc Document
-		err := rows.Scan(&doc.ID, &doc.Source, &doc.SourceType, &doc.IndexedAt, &doc.ContentSize, &doc.ChunkCount, &doc.Title)
+		doc, err := scanDocument(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan document: %w", err)
 		}
-		documents = append(documents, doc)
+		documents = append(documents, *doc)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -262,9 +465,49 @@ func (d *Database) ListDocuments(sourceTypeFilter string) ([]Document, error) {
 	return documents, nil
 }
 
+// GetDocument returns a document by source, or nil if it is not indexed
+func (d *Database) GetDocument(source string) (*Document, error) {
+	doc, err := scanDocument(d.db.QueryRow("SELECT "+documentColumns+" FROM documents WHERE source = ?", source))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return doc, nil
+}
+
+// documentColumns lists the columns read by scanDocument, in order
+const documentColumns = `id, source, source_type, indexed_at, content_size, chunk_count, COALESCE(title, ''),
+	embedding_tokens, embedding_cost, etag, last_modified,
+	refresh_interval, last_checked_at, last_check_status, last_check_error`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDocument reads a document selected with documentColumns
+func scanDocument(row rowScanner) (*Document, error) {
+	var doc Document
+	var refreshSeconds int64
+	var lastChecked sql.NullTime
+	err := row.Scan(&doc.ID, &doc.Source, &doc.SourceType, &doc.IndexedAt, &doc.ContentSize, &doc.ChunkCount, &doc.Title,
+		&doc.EmbeddingTokens, &doc.EmbeddingCost, &doc.ETag, &doc.LastModified,
+		&refreshSeconds, &lastChecked, &doc.LastCheckStatus, &doc.LastCheckError)
+	if err != nil {
+		return nil, err
+	}
+	doc.RefreshInterval = time.Duration(refreshSeconds) * time.Second
+	if lastChecked.Valid {
+		doc.LastCheckedAt = lastChecked.Time
+	}
+	return &doc, nil
+}
+
 // DeleteDocument deletes a document and its chunks by source
 func (d *Database) DeleteDocument(source string) error {
-	result, err := d.db.Exec("DELETE FROM documents WHERE source = ?", source)
+	result, err := d.writer.Exec("DELETE FROM documents WHERE source = ?", source)
 	if err != nil {
 		return fmt.Errorf("failed to delete document: %w", err)
 	}
@@ -281,6 +524,123 @@ func (d *Database) DeleteDocument(source string) error {
 	return nil
 }
 
+// SetRefreshInterval sets how often a document is re-checked; 0 disables
+// scheduled refresh
+func (d *Database) SetRefreshInterval(source string, interval time.Duration) error {
+	result, err := d.writer.Exec("UPDATE documents SET refresh_interval = ? WHERE source = ?", int64(interval/time.Second), source)
+	if err != nil {
+		return fmt.Errorf("failed to set refresh interval: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("document not found: %s", source)
+	}
+
+	return nil
+}
+
+// UpdateValidators stores the ETag and Last-Modified a server sent with a
+// 304 response, which may replace the ones from the last full fetch
+func (d *Database) UpdateValidators(source, etag, lastModified string) error {
+	_, err := d.writer.Exec("UPDATE documents SET etag = ?, last_modified = ? WHERE source = ?", etag, lastModified, source)
+	if err != nil {
+		return fmt.Errorf("failed to update validators: %w", err)
+	}
+	return nil
+}
+
+// RecordRefreshCheck stores the outcome of a scheduled refresh check
+func (d *Database) RecordRefreshCheck(source, status, checkErr string) error {
+	_, err := d.writer.Exec(
+		"UPDATE documents SET last_checked_at = CURRENT_TIMESTAMP, last_check_status = ?, last_check_error = ? WHERE source = ?",
+		status, checkErr, source,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record refresh check: %w", err)
+	}
+	return nil
+}
+
+// DocumentsDueForRefresh returns url documents whose refresh interval has
+// elapsed since they were last checked or indexed, whichever is later,
+// oldest first
+func (d *Database) DocumentsDueForRefresh() ([]Document, error) {
+	rows, err := d.db.Query(`
+		SELECT ` + documentColumns + ` FROM documents
+		WHERE source_type = 'url' AND refresh_interval > 0
+		  AND datetime(MAX(indexed_at, COALESCE(last_checked_at, indexed_at)), '+' || refresh_interval || ' seconds') <= datetime('now')
+		ORDER BY MAX(indexed_at, COALESCE(last_checked_at, indexed_at))
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents due for refresh: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []Document
+	for rows.Next() {
+		doc, err := scanDocument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		documents = append(documents, *doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating documents: %w", err)
+	}
+
+	return documents, nil
+}
+
+// CountDocuments returns the number of indexed documents and chunks
+func (d *Database) CountDocuments() (documents int, chunks int, err error) {
+	err = d.db.QueryRow("SELECT (SELECT COUNT(*) FROM documents), (SELECT COUNT(*) FROM chunks)").Scan(&documents, &chunks)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return documents, chunks, nil
+}
+
+// RecordUsage adds embedding spend to the running total for an operation
+func (d *Database) RecordUsage(operation string, tokens int, cost float64) error {
+	_, err := d.writer.Exec(`
+		INSERT INTO embedding_usage (operation, tokens, cost) VALUES (?, ?, ?)
+		ON CONFLICT(operation) DO UPDATE SET tokens = tokens + excluded.tokens, cost = cost + excluded.cost
+	`, operation, tokens, cost)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// UsageTotals returns the cumulative embedding spend per operation
+func (d *Database) UsageTotals() ([]UsageTotal, error) {
+	rows, err := d.db.Query("SELECT operation, tokens, cost FROM embedding_usage ORDER BY operation")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var total UsageTotal
+		if err := rows.Scan(&total.Operation, &total.Tokens, &total.Cost); err != nil {
+			return nil, fmt.Errorf("failed to scan usage total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage totals: %w", err)
+	}
+
+	return totals, nil
+}
+
 // DocumentExists checks if a document exists by source
 func (d *Database) DocumentExists(source string) (bool, error) {
 	var count int
@@ -291,6 +651,91 @@ func (d *Database) DocumentExists(source string) (bool, error) {
 	return count > 0, nil
 }
 
+// GetCachedEmbeddings returns cached embeddings for the given text hashes.
+// Hashes without a cached embedding are omitted from the result.
+func (d *Database) GetCachedEmbeddings(model string, hashes []string) (map[string][]float32, error) {
+	found := make(map[string][]float32, len(hashes))
+	if len(hashes) == 0 {
+		return found, nil
+	}
+
+	for start := 0; start < len(hashes); start += cacheLookupBatch {
+		end := start + cacheLookupBatch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		if err := d.getCachedEmbeddings(model, hashes[start:end], found); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// getCachedEmbeddings looks up one batch of hashes, adding hits to found
+func (d *Database) getCachedEmbeddings(model string, hashes []string, found map[string][]float32) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(hashes)), ",")
+	args := make([]interface{}, 0, len(hashes)+1)
+	args = append(args, model)
+	for _, hash := range hashes {
+		args = append(args, hash)
+	}
+
+	rows, err := d.db.Query(
+		"SELECT text_hash, embedding FROM embedding_cache WHERE model = ? AND text_hash IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query embedding cache: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var blob []byte
+		if err := rows.Scan(&hash, &blob); err != nil {
+			return fmt.Errorf("failed to scan cached embedding: %w", err)
+		}
+		embedding, err := deserializeEmbedding(blob)
+		if err != nil {
+			return fmt.Errorf("failed to deserialize cached embedding: %w", err)
+		}
+		found[hash] = embedding
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating cached embeddings: %w", err)
+	}
+	return nil
+}
+
+// PutCachedEmbeddings stores embeddings in the cache table, replacing any
+// existing entries for the same model and hash
+func (d *Database) PutCachedEmbeddings(model string, entries map[string][]float32) error {
+	tx, err := d.writer.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO embedding_cache (model, text_hash, embedding) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare cache insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for hash, embedding := range entries {
+		blob, err := serializeEmbedding(embedding)
+		if err != nil {
+			return fmt.Errorf("failed to serialize embedding: %w", err)
+		}
+		if _, err := stmt.Exec(model, hash, blob); err != nil {
+			return fmt.Errorf("failed to insert cached embedding: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // serializeEmbedding converts float32 slice to binary blob
 func serializeEmbedding(embedding []float32) ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a background operation persisted so it survives restarts. Request,
+// Progress and Result hold JSON owned by the caller.
+type Job struct {
+	ID       string
+	Kind     string
+	Target   string
+	Request  string
+	Status   string
+	Progress string
+	Result   string
+	Error    string
+
+	// Attempts counts how many times the job has been started; more than one
+	// means it was resumed after an interruption
+	Attempts        int
+	CancelRequested bool
+
+	CreatedAt   time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	HeartbeatAt time.Time
+}
+
+// jobColumns lists the columns read by scanJob, in order
+const jobColumns = `id, kind, target, request, status, progress, result, error, attempts, cancel_requested,
+	created_at, started_at, finished_at, heartbeat_at`
+
+// scanJob reads a job selected with jobColumns
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var started, finished, heartbeat sql.NullTime
+	err := row.Scan(&job.ID, &job.Kind, &job.Target, &job.Request, &job.Status, &job.Progress, &job.Result, &job.Error,
+		&job.Attempts, &job.CancelRequested, &job.CreatedAt, &started, &finished, &heartbeat)
+	if err != nil {
+		return nil, err
+	}
+	job.StartedAt = started.Time
+	job.FinishedAt = finished.Time
+	job.HeartbeatAt = heartbeat.Time
+	return &job, nil
+}
+
+// CreateJob queues a new job
+func (d *Database) CreateJob(id, kind, target, request string) error {
+	_, err := d.writer.Exec(
+		"INSERT INTO jobs (id, kind, target, request, status) VALUES (?, ?, ?, ?, ?)",
+		id, kind, target, request, JobQueued,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// GetJob returns a job by id, or nil if there is no such job
+func (d *Database) GetJob(id string) (*Job, error) {
+	job, err := scanJob(d.db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns jobs, newest first, optionally filtered by status;
+// limit <= 0 returns all of them
+func (d *Database) ListJobs(status string, limit int) ([]Job, error) {
+	query := "SELECT " + jobColumns + " FROM jobs"
+	var args []interface{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, rowid DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ClaimJob marks the oldest runnable job as running and returns it, or nil
+// when there is none. Runnable jobs are queued ones and running ones whose
+// heartbeat is older than staleAfter, which were left behind by a process
+// that stopped without finishing them. Stale jobs that were asked to cancel
+// are cancelled instead.
+func (d *Database) ClaimJob(staleAfter time.Duration) (*Job, error) {
+	stale := fmt.Sprintf("-%d seconds", int64(staleAfter/time.Second))
+
+	_, err := d.writer.Exec(`
+		UPDATE jobs SET status = ?, finished_at = CURRENT_TIMESTAMP, error = 'cancelled'
+		WHERE status = ? AND cancel_requested = 1 AND heartbeat_at < datetime('now', ?)
+	`, JobCancelled, JobRunning, stale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel stale jobs: %w", err)
+	}
+
+	var id string
+	err = d.writer.QueryRow(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1,
+			started_at = COALESCE(started_at, CURRENT_TIMESTAMP), heartbeat_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? OR (status = ? AND heartbeat_at < datetime('now', ?))
+			ORDER BY created_at, rowid LIMIT 1
+		)
+		RETURNING id
+	`, JobRunning, JobQueued, JobRunning, stale).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	job, err := scanJob(d.writer.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// HeartbeatJob records that a running job is alive, along with its latest
+// progress. It reports whether the job should stop: because cancellation was
+// requested, or because it is no longer running.
+func (d *Database) HeartbeatJob(id, progress string) (stop bool, err error) {
+	var cancelRequested bool
+	err = d.writer.QueryRow(
+		"UPDATE jobs SET heartbeat_at = CURRENT_TIMESTAMP, progress = ? WHERE id = ? AND status = ? RETURNING cancel_requested",
+		progress, id, JobRunning,
+	).Scan(&cancelRequested)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update job: %w", err)
+	}
+	return cancelRequested, nil
+}
+
+// FinishJob records the final status of a running job
+func (d *Database) FinishJob(id, status, progress, result, jobErr string) error {
+	_, err := d.writer.Exec(
+		"UPDATE jobs SET status = ?, progress = ?, result = ?, error = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?",
+		status, progress, result, jobErr, id, JobRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+	return nil
+}
+
+// RequeueJob puts a running job back in the queue, to be resumed later
+func (d *Database) RequeueJob(id, progress string) error {
+	_, err := d.writer.Exec(
+		"UPDATE jobs SET status = ?, progress = ? WHERE id = ? AND status = ?",
+		JobQueued, progress, id, JobRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	return nil
+}
+
+// CancelJob cancels a queued job immediately, or asks a running job to stop.
+// It returns the updated job, or nil if there is no such job; finished jobs
+// are returned unchanged.
+func (d *Database) CancelJob(id string) (*Job, error) {
+	tx, err := d.writer.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE jobs SET status = ?, error = 'cancelled', finished_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?",
+		JobCancelled, id, JobQueued,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE jobs SET cancel_requested = 1 WHERE id = ? AND status = ?", id, JobRunning); err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	job, err := scanJob(tx.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return job, nil
+}
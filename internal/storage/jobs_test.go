@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	for _, id := range []string{"job_a", "job_b"} {
+		if err := db.CreateJob(id, "index", "source-"+id, `{}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Jobs are claimed oldest first, once each
+	job, err := db.ClaimJob(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil || job.ID != "job_a" || job.Status != JobRunning || job.Attempts != 1 || job.StartedAt.IsZero() {
+		t.Fatalf("Expected job_a to be claimed, got %+v", job)
+	}
+	if next, err := db.ClaimJob(time.Minute); err != nil || next == nil || next.ID != "job_b" {
+		t.Fatalf("Expected job_b to be claimed next, got %+v, %v", next, err)
+	}
+	if next, err := db.ClaimJob(time.Minute); err != nil || next != nil {
+		t.Fatalf("Expected no job left to claim, got %+v, %v", next, err)
+	}
+
+	if stop, err := db.HeartbeatJob("job_a", `{"phase":"embed"}`); err != nil || stop {
+		t.Fatalf("Expected heartbeat to keep job running, got %v, %v", stop, err)
+	}
+	if err := db.FinishJob("job_a", JobCompleted, `{"phase":"done"}`, `{"ok":true}`, ""); err != nil {
+		t.Fatal(err)
+	}
+	job, err = db.GetJob("job_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobCompleted || job.Result != `{"ok":true}` || job.Progress != `{"phase":"done"}` || job.FinishedAt.IsZero() {
+		t.Errorf("Unexpected finished job: %+v", job)
+	}
+
+	// A finished job no longer heartbeats
+	if stop, err := db.HeartbeatJob("job_a", ""); err != nil || !stop {
+		t.Errorf("Expected heartbeat on a finished job to stop it, got %v, %v", stop, err)
+	}
+
+	jobs, err := db.ListJobs("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "job_b" {
+		t.Errorf("Expected jobs newest first, got %+v", jobs)
+	}
+	if jobs, err := db.ListJobs(JobCompleted, 0); err != nil || len(jobs) != 1 {
+		t.Errorf("Expected 1 completed job, got %d, %v", len(jobs), err)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	if err := db.CreateJob("running", "crawl", "https://example.com", `{}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ClaimJob(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateJob("queued", "crawl", "https://example.com", `{}`); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := db.CancelJob("queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobCancelled || job.FinishedAt.IsZero() {
+		t.Errorf("Expected queued job to be cancelled, got %+v", job)
+	}
+
+	// A running job is asked to stop and sees it at its next heartbeat
+	job, err = db.CancelJob("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobRunning || !job.CancelRequested {
+		t.Errorf("Expected running job to have cancellation requested, got %+v", job)
+	}
+	if stop, err := db.HeartbeatJob("running", ""); err != nil || !stop {
+		t.Errorf("Expected heartbeat to report cancellation, got %v, %v", stop, err)
+	}
+
+	if job, err := db.CancelJob("missing"); err != nil || job != nil {
+		t.Errorf("Expected nil for a missing job, got %+v, %v", job, err)
+	}
+}
+
+func TestClaimJobResumesStaleJobs(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	for _, id := range []string{"stale", "cancelled"} {
+		if err := db.CreateJob(id, "sitemap", "https://example.com/sitemap.xml", `{}`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ClaimJob(time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.CancelJob("cancelled"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fresh heartbeats belong to a live process
+	if job, err := db.ClaimJob(time.Minute); err != nil || job != nil {
+		t.Fatalf("Expected running jobs not to be claimed, got %+v, %v", job, err)
+	}
+
+	// Simulate the process that ran them stopping two minutes ago
+	if _, err := db.writer.Exec("UPDATE jobs SET heartbeat_at = datetime('now', '-2 minutes')"); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := db.ClaimJob(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil || job.ID != "stale" || job.Attempts != 2 {
+		t.Fatalf("Expected stale job to be resumed on its second attempt, got %+v", job)
+	}
+	if cancelled, err := db.GetJob("cancelled"); err != nil || cancelled.Status != JobCancelled {
+		t.Errorf("Expected stale job with cancellation requested to be cancelled, got %+v, %v", cancelled, err)
+	}
+
+	// Requeued jobs keep their attempt count and first start time
+	if err := db.RequeueJob("stale", ""); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := db.ClaimJob(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed == nil || resumed.Attempts != 3 || !resumed.StartedAt.Equal(job.StartedAt) {
+		t.Errorf("Expected requeued job to resume with its original start time, got %+v", resumed)
+	}
+}
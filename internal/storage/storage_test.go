@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 )
 
@@ -50,3 +52,34 @@ func TestRunMigrations(t *testing.T) {
 
 // Additional integration tests would go here
 // They would require sqlite-vec to be properly installed
+
+func TestGetCachedEmbeddingsManyHashes(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// More hashes than SQLite accepts as bound parameters in one query
+	hashes := make([]string, 40000)
+	for i := range hashes {
+		hashes[i] = fmt.Sprintf("hash-%d", i)
+	}
+	cached := map[string][]float32{
+		hashes[0]:     testEmbedding(0),
+		hashes[20000]: testEmbedding(1),
+		hashes[39999]: testEmbedding(2),
+	}
+	if err := db.PutCachedEmbeddings("model", cached); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.GetCachedEmbeddings("model", hashes)
+	if err != nil {
+		t.Fatalf("GetCachedEmbeddings failed: %v", err)
+	}
+	if len(found) != len(cached) {
+		t.Fatalf("Expected %d hits, got %d", len(cached), len(found))
+	}
+	for hash, embedding := range cached {
+		if got := found[hash]; len(got) != len(embedding) || got[0] != embedding[0] || got[1] != embedding[1] {
+			t.Errorf("Unexpected embedding for %s", hash)
+		}
+	}
+}
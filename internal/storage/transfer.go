@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// timestampLayout matches the format CURRENT_TIMESTAMP stores
+const timestampLayout = "2006-01-02 15:04:05"
+
+// ExportDocuments calls fn for every document, oldest first, with its chunks
+// and embeddings. All documents are read from a single snapshot.
+func (d *Database) ExportDocuments(fn func(doc Document, chunks []Chunk) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT " + documentColumns + " FROM documents ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+	var documents []Document
+	for rows.Next() {
+		doc, err := scanDocument(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan document: %w", err)
+		}
+		documents = append(documents, *doc)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("error iterating documents: %w", err)
+	}
+	rows.Close()
+
+	for _, doc := range documents {
+		chunks, err := documentChunks(tx, doc.ID)
+		if err != nil {
+			return err
+		}
+		if err := fn(doc, chunks); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// documentChunks returns the chunks of a document in order
+func documentChunks(tx *sql.Tx, documentID int64) ([]Chunk, error) {
+	rows, err := tx.Query(
+		"SELECT id, chunk_index, content, start_offset, end_offset, embedding FROM chunks WHERE document_id = ? ORDER BY chunk_index",
+		documentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		chunk := Chunk{DocumentID: documentID}
+		var blob []byte
+		if err := rows.Scan(&chunk.ID, &chunk.ChunkIndex, &chunk.Content, &chunk.StartOffset, &chunk.EndOffset, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunk.Embedding, err = deserializeEmbedding(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize embedding: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// Importer writes documents from an archive in a single transaction
+type Importer struct {
+	tx    *sql.Tx
+	chunk *sql.Stmt
+}
+
+// BeginImport starts an import. With replace, every existing document is
+// removed first and the number removed is returned.
+func (d *Database) BeginImport(replace bool) (*Importer, int, error) {
+	tx, err := d.writer.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	removed := 0
+	if replace {
+		if _, err := tx.Exec("DELETE FROM chunks"); err != nil {
+			tx.Rollback()
+			return nil, 0, fmt.Errorf("failed to delete chunks: %w", err)
+		}
+		result, err := tx.Exec("DELETE FROM documents")
+		if err != nil {
+			tx.Rollback()
+			return nil, 0, fmt.Errorf("failed to delete documents: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return nil, 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		removed = int(n)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO chunks (document_id, chunk_index, content, start_offset, end_offset, embedding) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, fmt.Errorf("failed to prepare chunk insert: %w", err)
+	}
+
+	return &Importer{tx: tx, chunk: stmt}, removed, nil
+}
+
+// Put stores a document exactly as exported, keeping its timestamps, spend
+// and refresh settings. An existing document with the same source is
+// replaced, unless onlyIfNewer is set and the existing copy was indexed at
+// or after doc.IndexedAt. It reports whether the document was written and
+// whether it replaced an existing one.
+func (im *Importer) Put(doc Document, chunks []Chunk, onlyIfNewer bool) (written, replaced bool, err error) {
+	var existingID int64
+	var existingIndexedAt time.Time
+	err = im.tx.QueryRow("SELECT id, indexed_at FROM documents WHERE source = ?", doc.Source).Scan(&existingID, &existingIndexedAt)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return false, false, fmt.Errorf("failed to read existing document: %w", err)
+	default:
+		if onlyIfNewer && !doc.IndexedAt.Truncate(time.Second).After(existingIndexedAt) {
+			return false, false, nil
+		}
+		if _, err := im.tx.Exec("DELETE FROM chunks WHERE document_id = ?", existingID); err != nil {
+			return false, false, fmt.Errorf("failed to delete existing chunks: %w", err)
+		}
+		if _, err := im.tx.Exec("DELETE FROM documents WHERE id = ?", existingID); err != nil {
+			return false, false, fmt.Errorf("failed to delete existing document: %w", err)
+		}
+		replaced = true
+	}
+
+	contentSize := doc.ContentSize
+	if contentSize == 0 {
+		for _, chunk := range chunks {
+			contentSize += len(chunk.Content)
+		}
+	}
+
+	var lastChecked interface{}
+	if !doc.LastCheckedAt.IsZero() {
+		lastChecked = doc.LastCheckedAt.UTC().Format(timestampLayout)
+	}
+
+	result, err := im.tx.Exec(
+		`INSERT INTO documents (source, source_type, indexed_at, content_size, chunk_count, title, embedding_tokens, embedding_cost,
+			etag, last_modified, refresh_interval, last_checked_at, last_check_status, last_check_error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.Source, doc.SourceType, doc.IndexedAt.UTC().Format(timestampLayout), contentSize, len(chunks), doc.Title,
+		doc.EmbeddingTokens, doc.EmbeddingCost, doc.ETag, doc.LastModified,
+		int64(doc.RefreshInterval/time.Second), lastChecked, doc.LastCheckStatus, doc.LastCheckError,
+	)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to insert document: %w", err)
+	}
+	documentID, err := result.LastInsertId()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get document ID: %w", err)
+	}
+
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != embeddingDimension {
+			return false, false, fmt.Errorf("invalid embedding dimension for chunk %d of %s: got %d, expected %d", chunk.ChunkIndex, doc.Source, len(chunk.Embedding), embeddingDimension)
+		}
+		embeddingBlob, err := serializeEmbedding(chunk.Embedding)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to serialize embedding: %w", err)
+		}
+		if _, err := im.chunk.Exec(documentID, chunk.ChunkIndex, chunk.Content, chunk.StartOffset, chunk.EndOffset, embeddingBlob); err != nil {
+			return false, false, fmt.Errorf("failed to insert chunk: %w", err)
+		}
+	}
+
+	return true, replaced, nil
+}
+
+// Commit makes the import visible
+func (im *Importer) Commit() error {
+	im.chunk.Close()
+	if err := im.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the import; it is a no-op after Commit
+func (im *Importer) Rollback() error {
+	im.chunk.Close()
+	return im.tx.Rollback()
+}
+
+// Backup writes a consistent copy of the database to path with VACUUM INTO,
+// which is safe while other connections read and write. The copy is built
+// under a temporary name and renamed into place, so path is either the
+// previous file or a complete snapshot.
+func (d *Database) Backup(path string) error {
+	if info, err := os.Stat(path); err == nil {
+		if live, err := os.Stat(d.path); err == nil && os.SameFile(info, live) {
+			return fmt.Errorf("backup path %s is the live database", path)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// VACUUM INTO refuses to overwrite, so reserve a name and free it
+	tmp, err := os.CreateTemp(dir, ".doc-search-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	if _, err := d.db.Exec("VACUUM INTO ?", tmpPath); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	return nil
+}
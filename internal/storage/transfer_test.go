@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBackupWhileIndexing(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "test.db")
+	db := newTestDatabase(t, livePath)
+
+	for i := 0; i < 5; i++ {
+		if err := db.IndexDocument(DocumentMeta{Source: fmt.Sprintf("doc-%d", i), SourceType: "file"}, testDocumentChunks(i, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keep a writer indexing while the snapshot is taken
+	stop := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 100; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := db.IndexDocument(DocumentMeta{Source: fmt.Sprintf("doc-%d", i), SourceType: "file"}, testDocumentChunks(i, 2)); err != nil {
+				t.Errorf("index %d: %v", i, err)
+				return
+			}
+			if i == 100 {
+				close(started)
+			}
+		}
+	}()
+	<-started
+
+	snapshot := filepath.Join(dir, "backups", "snapshot.db")
+	backupErr := db.Backup(snapshot)
+	close(stop)
+	wg.Wait()
+	if backupErr != nil {
+		t.Fatalf("Backup failed: %v", backupErr)
+	}
+
+	// The snapshot is a consistent database: whole documents only
+	copied := newTestDatabase(t, snapshot)
+	documents, chunks, err := copied.CountDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documents < 6 || chunks != documents*2 {
+		t.Errorf("Expected at least 6 whole documents in the snapshot, got %d documents and %d chunks", documents, chunks)
+	}
+	if results, err := copied.Search(testEmbedding(0), 1, 0, ""); err != nil || len(results) != 1 {
+		t.Errorf("Expected the snapshot to be searchable, got %d results, %v", len(results), err)
+	}
+
+	// A second backup replaces the first and leaves no temporary file behind
+	if err := db.Backup(snapshot); err != nil {
+		t.Fatalf("Backup over an existing snapshot failed: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".doc-search-backup-") {
+			t.Errorf("Expected no temporary backup files, found %s", e.Name())
+		}
+	}
+
+	// The live database is never overwritten, however its path is spelled
+	for _, path := range []string{livePath, filepath.Join(dir, ".", "..", filepath.Base(dir), "test.db")} {
+		if err := db.Backup(path); err == nil || !strings.Contains(err.Error(), "live database") {
+			t.Errorf("Expected Backup(%s) to refuse the live database, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReindexAccumulatesSpend(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	meta := DocumentMeta{Source: "https://example.com/guide", SourceType: "url", EmbeddingTokens: 100, EmbeddingCost: 0.002}
+	if err := db.IndexDocument(meta, testDocumentChunks(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetRefreshInterval(meta.Source, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each reindex adds its spend to the document's totals and keeps the
+	// refresh schedule
+	for i, tokens := range []int{40, 60} {
+		meta.EmbeddingTokens = tokens
+		meta.EmbeddingCost = float64(tokens) * 0.00002
+		if err := db.IndexDocument(meta, testDocumentChunks(10+i, 3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	doc, err := db.GetDocument(meta.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.EmbeddingTokens != 200 || math.Abs(doc.EmbeddingCost-0.004) > 1e-9 {
+		t.Errorf("Expected 200 tokens and $0.004 across three indexes, got %d and %f", doc.EmbeddingTokens, doc.EmbeddingCost)
+	}
+	if doc.ChunkCount != 3 || doc.RefreshInterval != time.Hour {
+		t.Errorf("Expected the latest chunks and the kept schedule, got %+v", doc)
+	}
+
+	// Deleting and indexing again starts the totals over
+	if err := db.DeleteDocument(meta.Source); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.IndexDocument(meta, testDocumentChunks(20, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if doc, _ := db.GetDocument(meta.Source); doc.EmbeddingTokens != 60 {
+		t.Errorf("Expected totals to restart after delete, got %d tokens", doc.EmbeddingTokens)
+	}
+}
+
+func TestUsageTotals(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.db"))
+
+	if totals, err := db.UsageTotals(); err != nil || len(totals) != 0 {
+		t.Fatalf("Expected no usage in a new database, got %+v, %v", totals, err)
+	}
+
+	for _, u := range []UsageTotal{
+		{"index", 100, 0.002},
+		{"search", 5, 0.0001},
+		{"index", 50, 0.001},
+		{"search", 7, 0.00014},
+	} {
+		if err := db.RecordUsage(u.Operation, u.Tokens, u.Cost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	totals, err := db.UsageTotals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UsageTotal{{"index", 150, 0.003}, {"search", 12, 0.00024}}
+	if len(totals) != len(want) {
+		t.Fatalf("Expected %d operations, got %+v", len(want), totals)
+	}
+	for i, w := range want {
+		if totals[i].Operation != w.Operation || totals[i].Tokens != w.Tokens || math.Abs(totals[i].Cost-w.Cost) > 1e-9 {
+			t.Errorf("Expected %+v, got %+v", w, totals[i])
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package wait provides context-aware delays shared by the fetcher and the
+// embeddings client
+package wait
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep sleeps for d or until ctx is done, returning ctx's error in that case
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/cmrigney/mcp-document-search/internal/search"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// withProgress returns a context that forwards index progress to the client
+// as MCP progress notifications, when the call carries a progress token
+func withProgress(ctx context.Context, request *mcp.CallToolRequest) context.Context {
+	if request == nil || request.Session == nil || request.Params == nil {
+		return ctx
+	}
+	token := request.Params.GetProgressToken()
+	if token == nil {
+		return ctx
+	}
+
+	notifier := &progressNotifier{
+		send: func(params *mcp.ProgressNotificationParams) error {
+			params.ProgressToken = token
+			return request.Session.NotifyProgress(ctx, params)
+		},
+		last: -1,
+	}
+	return search.WithProgress(ctx, notifier.notify)
+}
+
+// progressNotifier turns search progress into notifications whose progress
+// value strictly increases, as the protocol requires
+type progressNotifier struct {
+	send func(*mcp.ProgressNotificationParams) error
+
+	mu   sync.Mutex
+	last float64
+}
+
+// notify sends p unless it does not advance past the last notification
+func (n *progressNotifier) notify(p search.Progress) {
+	done, total := p.Steps()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if done <= n.last {
+		return
+	}
+	n.last = done
+
+	err := n.send(&mcp.ProgressNotificationParams{
+		Progress: done,
+		Total:    total,
+		Message:  p.Message,
+	})
+	if err != nil {
+		log.Printf("Failed to send progress notification: %v", err)
+	}
+}
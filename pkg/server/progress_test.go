@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cmrigney/mcp-document-search/internal/search"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestProgressNotifierOnlyAdvances(t *testing.T) {
+	var sent []*mcp.ProgressNotificationParams
+	notifier := &progressNotifier{
+		send: func(params *mcp.ProgressNotificationParams) error {
+			sent = append(sent, params)
+			return nil
+		},
+		last: -1,
+	}
+
+	for _, p := range []search.Progress{
+		{Phase: search.PhaseFetch, Message: "Fetching notes"},
+		{Phase: search.PhaseChunk, ChunksTotal: 4, Message: "Split notes into 4 chunks"},
+		{Phase: search.PhaseEmbed, ChunksTotal: 4, Message: "Embedded 0 of 4 chunks"},
+		{Phase: search.PhaseEmbed, ChunksEmbedded: 2, ChunksTotal: 4, Message: "Embedded 2 of 4 chunks"},
+		{Phase: search.PhaseEmbed, ChunksEmbedded: 2, ChunksTotal: 4, Message: "Embedded 2 of 4 chunks"},
+		{Phase: search.PhaseStore, ChunksEmbedded: 4, ChunksTotal: 4, Message: "Storing 4 chunks"},
+		{Phase: search.PhaseDone, ChunksEmbedded: 4, ChunksTotal: 4, Message: "Indexed notes"},
+	} {
+		notifier.notify(p)
+	}
+
+	want := []struct {
+		progress, total float64
+		message         string
+	}{
+		{0, 0, "Fetching notes"},
+		{1, 7, "Split notes into 4 chunks"},
+		{3, 7, "Embedded 2 of 4 chunks"},
+		{6, 7, "Storing 4 chunks"},
+		{7, 7, "Indexed notes"},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("Expected %d notifications, got %d: %+v", len(want), len(sent), sent)
+	}
+	for i, w := range want {
+		if sent[i].Progress != w.progress || sent[i].Total != w.total || sent[i].Message != w.message {
+			t.Errorf("Notification %d: expected %+v, got %+v", i, w, sent[i])
+		}
+	}
+}
+
+func TestWithProgressNeedsToken(t *testing.T) {
+	ctx := context.Background()
+	if got := withProgress(ctx, nil); got != ctx {
+		t.Error("Expected a call without a request to keep its context")
+	}
+	if got := withProgress(ctx, &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{}}); got != ctx {
+		t.Error("Expected a call without a progress token to keep its context")
+	}
+}
+
+func TestIndexSendsProgressNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var received []*mcp.ProgressNotificationParams
+	session := connect(t, NewServer(newTestService(t)), &mcp.ClientOptions{
+		ProgressNotificationHandler: func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, req.Params)
+		},
+	})
+
+	// SetProgressToken drops the token when Meta is nil, so set it directly
+	params := &mcp.CallToolParams{
+		Meta:      mcp.Meta{"progressToken": "index-1"},
+		Name:      "index",
+		Arguments: map[string]any{"content": "Progress is reported while indexing.", "source": "notes"},
+	}
+	if result, err := session.CallTool(context.Background(), params); err != nil || result.IsError {
+		t.Fatalf("index failed: %v %+v", err, result)
+	}
+
+	// Notifications are handled asynchronously; wait for the final one
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		finished := n > 0 && received[n-1].Total > 0 && received[n-1].Progress == received[n-1].Total
+		mu.Unlock()
+		if finished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the final progress notification, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	notifications := append([]*mcp.ProgressNotificationParams(nil), received...)
+	mu.Unlock()
+
+	var messages []string
+	for i, p := range notifications {
+		if p.ProgressToken != "index-1" {
+			t.Errorf("Expected progress token index-1, got %v", p.ProgressToken)
+		}
+		if i > 0 && p.Progress <= notifications[i-1].Progress {
+			t.Errorf("Expected progress to increase, got %v after %v", p.Progress, notifications[i-1].Progress)
+		}
+		messages = append(messages, p.Message)
+	}
+	if joined := strings.Join(messages, "\n"); !strings.Contains(joined, "Split notes into 1 chunks") || !strings.Contains(joined, "Embedded 1 of 1 chunks") {
+		t.Errorf("Expected phase messages, got:\n%s", joined)
+	}
+
+	// Without a token, no notifications are sent
+	_, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "index",
+		Arguments: map[string]any{"content": "No progress requested.", "source": "quiet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != len(notifications) {
+		t.Errorf("Expected no notifications without a progress token, got %d", len(received)-len(notifications))
+	}
+}
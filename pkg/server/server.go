@@ -46,10 +46,17 @@ func (s *Server) registerTools(mcpServer *mcp.Server) {
 	}
 	mcp.AddTool(mcpServer, searchTool, s.handleSearch)
 
+	// Batch search tool
+	searchBatchTool := &mcp.Tool{
+		Name:        "search_batch",
+		Description: "Run several semantic searches in one call, embedding all queries together and grouping results per query",
+	}
+	mcp.AddTool(mcpServer, searchBatchTool, s.handleSearchBatch)
+
 	// Index tool
 	indexTool := &mcp.Tool{
 		Name:        "index",
-		Description: "Index a file, URL, or content for semantic search. Provide exactly one of: file_path, url, or (content + source)",
+		Description: "Index a file, URL, sitemap, or content for semantic search. Provide exactly one of: file_path, url, sitemap, or (content + source). Set crawl=true with url to index a whole site, and async=true to run in the background as a job",
 	}
 	mcp.AddTool(mcpServer, indexTool, s.handleIndex)
 
@@ -66,6 +73,41 @@ func (s *Server) registerTools(mcpServer *mcp.Server) {
 		Description: "Remove an indexed document from the database",
 	}
 	mcp.AddTool(mcpServer, deleteTool, s.handleDelete)
+
+	// Stats tool
+	statsTool := &mcp.Tool{
+		Name:        "stats",
+		Description: "Report index size, embedding token spend by source, and embedding cache hit/miss statistics",
+	}
+	mcp.AddTool(mcpServer, statsTool, s.handleStats)
+
+	// Backup tool
+	backupTool := &mcp.Tool{
+		Name:        "backup",
+		Description: "Write a consistent online snapshot of the index database to the backup directory without stopping the server",
+	}
+	mcp.AddTool(mcpServer, backupTool, s.handleBackup)
+
+	// Job status tool
+	jobStatusTool := &mcp.Tool{
+		Name:        "job_status",
+		Description: "Report the status, progress and result of a background indexing job started with index async=true",
+	}
+	mcp.AddTool(mcpServer, jobStatusTool, s.handleJobStatus)
+
+	// List jobs tool
+	listJobsTool := &mcp.Tool{
+		Name:        "list_jobs",
+		Description: "List background indexing jobs, newest first",
+	}
+	mcp.AddTool(mcpServer, listJobsTool, s.handleListJobs)
+
+	// Cancel job tool
+	cancelJobTool := &mcp.Tool{
+		Name:        "cancel_job",
+		Description: "Cancel a queued or running background indexing job; documents it already indexed are kept",
+	}
+	mcp.AddTool(mcpServer, cancelJobTool, s.handleCancelJob)
 }
 
 // Run starts the MCP server on stdio transport
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"github.com/cmrigney/mcp-document-search/internal/chunker"
+	"github.com/cmrigney/mcp-document-search/internal/embeddings"
+	"github.com/cmrigney/mcp-document-search/internal/fetcher"
+	"github.com/cmrigney/mcp-document-search/internal/search"
+	"github.com/cmrigney/mcp-document-search/internal/storage"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// newTestService returns a search service backed by a temporary database and
+// a fake embeddings API that gives every text the same vector, so every
+// chunk matches every query
+func newTestService(t *testing.T) *search.Service {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		type item struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		data := make([]item, len(req.Input))
+		for i := range data {
+			data[i] = item{Embedding: make([]float32, 1536), Index: i}
+			data[i].Embedding[0] = 1
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(api.Close)
+
+	sqlite_vec.Auto()
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	client := embeddings.NewClientWithOptions("test", embeddings.Options{Endpoint: api.URL})
+	return search.NewService(db, client, chunker.NewChunker(200, 20), fetcher.NewFetcher())
+}
+
+// connect serves s over an in-memory transport and returns a connected
+// client session
+func connect(t *testing.T, s *Server, opts *mcp.ClientOptions) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	serverSession, err := s.mcpServer.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("Server connect failed: %v", err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "1.0.0"}, opts)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("Client connect failed: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// outputSchemas returns the resolved output schema of every tool that
+// publishes one
+func outputSchemas(t *testing.T, session *mcp.ClientSession) map[string]*jsonschema.Resolved {
+	t.Helper()
+	tools, err := session.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+
+	schemas := make(map[string]*jsonschema.Resolved)
+	for _, tool := range tools.Tools {
+		if tool.OutputSchema == nil {
+			continue
+		}
+		encoded, err := json.Marshal(tool.OutputSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema jsonschema.Schema
+		if err := json.Unmarshal(encoded, &schema); err != nil {
+			t.Fatalf("Invalid output schema for %s: %v", tool.Name, err)
+		}
+		resolved, err := schema.Resolve(nil)
+		if err != nil {
+			t.Fatalf("Failed to resolve output schema for %s: %v", tool.Name, err)
+		}
+		schemas[tool.Name] = resolved
+	}
+	return schemas
+}
+
+// callTool calls a tool and returns its structured content, checked against
+// the tool's output schema, and its JSON text fallback
+func callTool(t *testing.T, session *mcp.ClientSession, schemas map[string]*jsonschema.Resolved, name string, args map[string]any) (map[string]any, map[string]any) {
+	t.Helper()
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if result.IsError {
+		t.Fatalf("%s returned an error: %+v", name, result.Content)
+	}
+
+	schema, ok := schemas[name]
+	if !ok {
+		t.Fatalf("Expected %s to publish an output schema", name)
+	}
+	structured, ok := result.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected %s to return structured content, got %T", name, result.StructuredContent)
+	}
+	if err := schema.Validate(structured); err != nil {
+		t.Errorf("%s structured content does not match its output schema: %v", name, err)
+	}
+
+	if len(result.Content) != 1 {
+		t.Fatalf("Expected %s to return one text content, got %d", name, len(result.Content))
+	}
+	text, ok := result.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("Expected %s text content, got %T", name, result.Content[0])
+	}
+	var fallback map[string]any
+	if err := json.Unmarshal([]byte(text.Text), &fallback); err != nil {
+		t.Fatalf("Expected %s text fallback to be JSON: %v", name, err)
+	}
+	return structured, fallback
+}
+
+func TestToolsReturnStructuredContent(t *testing.T) {
+	session := connect(t, NewServer(newTestService(t)), nil)
+	schemas := outputSchemas(t, session)
+
+	// The index text keeps the plain document response older clients read
+	out, text := callTool(t, session, schemas, "index", map[string]any{
+		"content": "Structured output lets agents read tool results without parsing text.",
+		"source":  "notes",
+	})
+	if doc, _ := out["document"].(map[string]any); doc["source"] != "notes" || !reflect.DeepEqual(doc, text) {
+		t.Errorf("Unexpected index output %v with text %v", out, text)
+	}
+
+	out, text = callTool(t, session, schemas, "search", map[string]any{"query": "structured output"})
+	if !reflect.DeepEqual(out, text) {
+		t.Errorf("Expected search text to match structured content, got %v and %v", text, out)
+	}
+	if out["count"] != float64(1) {
+		t.Errorf("Expected 1 search result, got %v", out)
+	}
+	results, _ := out["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["source"] != "notes" {
+		t.Errorf("Unexpected search results: %v", out["results"])
+	}
+
+	out, text = callTool(t, session, schemas, "list", nil)
+	if out["count"] != float64(1) || !reflect.DeepEqual(out, text) {
+		t.Errorf("Expected 1 listed document in both outputs, got %v and %v", out, text)
+	}
+
+	out, text = callTool(t, session, schemas, "delete", map[string]any{"source": "notes"})
+	if out["deleted"] != true || !reflect.DeepEqual(out, text) {
+		t.Errorf("Expected delete to report the deletion in both outputs, got %v and %v", out, text)
+	}
+	out, _ = callTool(t, session, schemas, "list", nil)
+	if out["count"] != float64(0) {
+		t.Errorf("Expected no documents after delete, got %v", out)
+	}
+}
+
+func TestRenderedSearchStructuredContentMatchesText(t *testing.T) {
+	service := newTestService(t)
+	service.SetOutputMaxChars(500)
+	session := connect(t, NewServer(service), nil)
+	schemas := outputSchemas(t, session)
+
+	for _, source := range []string{"a", "b", "c"} {
+		content := strings.Repeat("Budgeted output keeps an agent's context small. ", 4)
+		if _, err := service.Index(context.Background(), search.IndexRequest{Content: content, Source: source}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "search",
+		Arguments: map[string]any{"query": "budget", "format": "citations"},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("search failed: %v %+v", err, result)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	structured := result.StructuredContent.(map[string]any)
+	if err := schemas["search"].Validate(structured); err != nil {
+		t.Errorf("Structured content does not match the output schema: %v", err)
+	}
+
+	results, _ := structured["results"].([]any)
+	if len(results) == 0 || len(results) == 3 {
+		t.Fatalf("Expected the budget to drop some results, got %d:\n%s", len(results), text)
+	}
+	if structured["count"] != float64(len(results)) || structured["omitted"] != float64(3-len(results)) {
+		t.Errorf("Expected count and omitted to describe the rendered results, got %v", structured)
+	}
+	for _, r := range results {
+		if content := r.(map[string]any)["content"].(string); !strings.Contains(text, content) {
+			t.Errorf("Expected structured result %q to appear in the text:\n%s", content, text)
+		}
+	}
+}
@@ -4,18 +4,41 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/cmrigney/mcp-document-search/internal/search"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// textResult renders a response as indented JSON text. Tools with an output
+// schema also return the response as structured content; the text keeps
+// older clients, which only read content, working unchanged.
+func textResult(resp any) (*mcp.CallToolResult, error) {
+	resultJSON, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: string(resultJSON)},
+		},
+	}, nil
+}
+
 // handleSearch handles the search tool
-func (s *Server) handleSearch(ctx context.Context, request *mcp.CallToolRequest, args SearchArgs) (*mcp.CallToolResult, any, error) {
+func (s *Server) handleSearch(ctx context.Context, request *mcp.CallToolRequest, args SearchArgs) (*mcp.CallToolResult, *search.SearchResponse, error) {
 	// Validate query
 	if args.Query == "" {
 		return nil, nil, fmt.Errorf("query is required")
 	}
 
+	switch args.Format {
+	case "", search.FormatJSON, search.FormatMarkdown, search.FormatCitations:
+	default:
+		return nil, nil, fmt.Errorf("invalid format %q (use %s, %s or %s)", args.Format, search.FormatJSON, search.FormatMarkdown, search.FormatCitations)
+	}
+
 	// Set defaults
 	if args.TopK <= 0 {
 		args.TopK = 5
@@ -32,6 +55,10 @@ func (s *Server) handleSearch(ctx context.Context, request *mcp.CallToolRequest,
 		TopK:         args.TopK,
 		MinScore:     minScore,
 		SourceFilter: args.SourceFilter,
+		QueryMode:    args.QueryMode,
+		NumQueries:   args.NumQueries,
+		MaxChars:     args.MaxChars,
+		MaxTokens:    args.MaxTokens,
 	}
 
 	resp, err := s.searchService.Search(ctx, searchReq)
@@ -39,25 +66,75 @@ func (s *Server) handleSearch(ctx context.Context, request *mcp.CallToolRequest,
 		return nil, nil, fmt.Errorf("search failed: %w", err)
 	}
 
-	// Format response as JSON
-	resultJSON, err := json.MarshalIndent(resp, "", "  ")
+	// Rendered formats replace the JSON text; structured content holds the
+	// same results, so both stay within the output budget
+	if args.Format == search.FormatMarkdown || args.Format == search.FormatCitations {
+		text, rendered, err := s.searchService.RenderResults(args.Query, resp.Results, args.Format)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp.Omitted += len(resp.Results) - len(rendered)
+		resp.Results = rendered
+		resp.Count = len(rendered)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: text},
+			},
+		}, resp, nil
+	}
+
+	result, err := textResult(resp)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to format results: %w", err)
+		return nil, nil, err
+	}
+	return result, resp, nil
+}
+
+// handleSearchBatch handles the search_batch tool
+func (s *Server) handleSearchBatch(ctx context.Context, request *mcp.CallToolRequest, args SearchBatchArgs) (*mcp.CallToolResult, any, error) {
+	// Validate queries
+	if len(args.Queries) == 0 {
+		return nil, nil, fmt.Errorf("queries is required")
 	}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(resultJSON)},
-		},
-	}, nil, nil
+	// Set defaults
+	if args.TopK <= 0 {
+		args.TopK = 5
+	}
+
+	minScore := 0.3
+	if args.MinScore != nil {
+		minScore = *args.MinScore
+	}
+
+	// Execute batch search
+	batchReq := search.SearchBatchRequest{
+		Queries:      args.Queries,
+		TopK:         args.TopK,
+		MinScore:     minScore,
+		SourceFilter: args.SourceFilter,
+		Deduplicate:  args.Deduplicate,
+	}
+
+	resp, err := s.searchService.SearchBatch(ctx, batchReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("batch search failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
 }
 
 // handleIndex handles the index tool
-func (s *Server) handleIndex(ctx context.Context, request *mcp.CallToolRequest, args IndexArgs) (*mcp.CallToolResult, any, error) {
+func (s *Server) handleIndex(ctx context.Context, request *mcp.CallToolRequest, args IndexArgs) (*mcp.CallToolResult, *IndexOutput, error) {
+	// Report fetch, chunk, embed and store progress if the client asked for it
+	ctx = withProgress(ctx, request)
+
 	// Validate exactly one source
 	hasFilePath := args.FilePath != ""
 	hasURL := args.URL != ""
 	hasContent := args.Content != "" && args.Source != ""
+	hasSitemap := args.Sitemap != ""
 
 	sourceCount := 0
 	if hasFilePath {
@@ -69,12 +146,26 @@ func (s *Server) handleIndex(ctx context.Context, request *mcp.CallToolRequest,
 	if hasContent {
 		sourceCount++
 	}
+	if hasSitemap {
+		sourceCount++
+	}
 
 	if sourceCount == 0 {
-		return nil, nil, fmt.Errorf("must provide exactly one of: file_path, url, or (content + source)")
+		return nil, nil, fmt.Errorf("must provide exactly one of: file_path, url, sitemap, or (content + source)")
 	}
 	if sourceCount > 1 {
-		return nil, nil, fmt.Errorf("provide exactly one of: file_path, url, or (content + source)")
+		return nil, nil, fmt.Errorf("provide exactly one of: file_path, url, sitemap, or (content + source)")
+	}
+
+	if args.RefreshIntervalMinutes != nil && (args.Crawl || hasSitemap) {
+		return nil, nil, fmt.Errorf("refresh_interval_minutes is only supported when indexing a single url")
+	}
+
+	if args.Crawl {
+		return s.handleCrawl(ctx, args)
+	}
+	if hasSitemap {
+		return s.handleSitemap(ctx, args)
 	}
 
 	// Execute index
@@ -85,27 +176,99 @@ func (s *Server) handleIndex(ctx context.Context, request *mcp.CallToolRequest,
 		Source:   args.Source,
 		Reindex:  args.Reindex,
 	}
+	if args.RefreshIntervalMinutes != nil {
+		interval := time.Duration(*args.RefreshIntervalMinutes) * time.Minute
+		indexReq.RefreshInterval = &interval
+	}
+	if args.Async {
+		return jobResult(s.searchService.SubmitIndex(indexReq))
+	}
 
 	resp, err := s.searchService.Index(ctx, indexReq)
 	if err != nil {
 		return nil, nil, fmt.Errorf("indexing failed: %w", err)
 	}
 
-	// Format response as JSON
-	resultJSON, err := json.MarshalIndent(resp, "", "  ")
+	result, err := textResult(resp)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to format results: %w", err)
+		return nil, nil, err
 	}
+	return result, &IndexOutput{Document: resp}, nil
+}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(resultJSON)},
-		},
-	}, nil, nil
+// handleCrawl handles the index tool in crawl mode
+func (s *Server) handleCrawl(ctx context.Context, args IndexArgs) (*mcp.CallToolResult, *IndexOutput, error) {
+	if args.URL == "" {
+		return nil, nil, fmt.Errorf("crawl requires url")
+	}
+
+	crawlReq := search.CrawlRequest{
+		URL:      args.URL,
+		MaxDepth: args.MaxDepth,
+		MaxPages: args.MaxPages,
+		Include:  args.IncludePatterns,
+		Exclude:  args.ExcludePatterns,
+		Reindex:  args.Reindex,
+	}
+	if args.CrawlDelayMs != nil {
+		delay := time.Duration(*args.CrawlDelayMs) * time.Millisecond
+		crawlReq.Delay = &delay
+	}
+	if args.Async {
+		return jobResult(s.searchService.SubmitCrawl(crawlReq))
+	}
+
+	resp, err := s.searchService.Crawl(ctx, crawlReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("indexing failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, &IndexOutput{Crawl: resp}, nil
+}
+
+// handleSitemap handles the index tool for sitemap URLs
+func (s *Server) handleSitemap(ctx context.Context, args IndexArgs) (*mcp.CallToolResult, *IndexOutput, error) {
+	sitemapReq := search.SitemapRequest{
+		SitemapURL:  args.Sitemap,
+		MaxPages:    args.MaxPages,
+		Concurrency: args.Concurrency,
+		Reindex:     args.Reindex,
+	}
+	if args.Async {
+		return jobResult(s.searchService.SubmitSitemap(sitemapReq))
+	}
+
+	resp, err := s.searchService.IndexSitemap(ctx, sitemapReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("indexing failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, &IndexOutput{Sitemap: resp}, nil
+}
+
+// jobResult formats a job submitted by the index tool with async=true
+func jobResult(info *search.JobInfo, err error) (*mcp.CallToolResult, *IndexOutput, error) {
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start job: %w", err)
+	}
+
+	result, err := textResult(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, &IndexOutput{Job: info}, nil
 }
 
 // handleList handles the list tool
-func (s *Server) handleList(ctx context.Context, request *mcp.CallToolRequest, args ListArgs) (*mcp.CallToolResult, any, error) {
+func (s *Server) handleList(ctx context.Context, request *mcp.CallToolRequest, args ListArgs) (*mcp.CallToolResult, *search.ListResponse, error) {
 	// Execute list
 	listReq := search.ListRequest{
 		SourceType: args.SourceType,
@@ -116,21 +279,15 @@ func (s *Server) handleList(ctx context.Context, request *mcp.CallToolRequest, a
 		return nil, nil, fmt.Errorf("list failed: %w", err)
 	}
 
-	// Format response as JSON
-	resultJSON, err := json.MarshalIndent(resp, "", "  ")
+	result, err := textResult(resp)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to format results: %w", err)
+		return nil, nil, err
 	}
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(resultJSON)},
-		},
-	}, nil, nil
+	return result, resp, nil
 }
 
 // handleDelete handles the delete tool
-func (s *Server) handleDelete(ctx context.Context, request *mcp.CallToolRequest, args DeleteArgs) (*mcp.CallToolResult, any, error) {
+func (s *Server) handleDelete(ctx context.Context, request *mcp.CallToolRequest, args DeleteArgs) (*mcp.CallToolResult, *search.DeleteResponse, error) {
 	// Validate source
 	if args.Source == "" {
 		return nil, nil, fmt.Errorf("source is required")
@@ -146,15 +303,78 @@ func (s *Server) handleDelete(ctx context.Context, request *mcp.CallToolRequest,
 		return nil, nil, fmt.Errorf("delete failed: %w", err)
 	}
 
-	// Format response as JSON
-	resultJSON, err := json.MarshalIndent(resp, "", "  ")
+	result, err := textResult(resp)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to format results: %w", err)
+		return nil, nil, err
 	}
+	return result, resp, nil
+}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(resultJSON)},
-		},
-	}, nil, nil
+// handleStats handles the stats tool
+func (s *Server) handleStats(ctx context.Context, request *mcp.CallToolRequest, args StatsArgs) (*mcp.CallToolResult, any, error) {
+	resp, err := s.searchService.Stats(ctx, search.StatsRequest{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("stats failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
+}
+
+// handleBackup handles the backup tool
+func (s *Server) handleBackup(ctx context.Context, request *mcp.CallToolRequest, args BackupArgs) (*mcp.CallToolResult, any, error) {
+	resp, err := s.searchService.Backup(ctx, search.BackupRequest{
+		Name:      args.Name,
+		Overwrite: args.Overwrite,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
+}
+
+// handleJobStatus handles the job_status tool
+func (s *Server) handleJobStatus(ctx context.Context, request *mcp.CallToolRequest, args JobStatusArgs) (*mcp.CallToolResult, any, error) {
+	if args.JobID == "" {
+		return nil, nil, fmt.Errorf("job_id is required")
+	}
+
+	resp, err := s.searchService.JobStatus(args.JobID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("job status failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
+}
+
+// handleListJobs handles the list_jobs tool
+func (s *Server) handleListJobs(ctx context.Context, request *mcp.CallToolRequest, args ListJobsArgs) (*mcp.CallToolResult, any, error) {
+	resp, err := s.searchService.ListJobs(search.ListJobsRequest{
+		Status: args.Status,
+		Limit:  args.Limit,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("list jobs failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
+}
+
+// handleCancelJob handles the cancel_job tool
+func (s *Server) handleCancelJob(ctx context.Context, request *mcp.CallToolRequest, args CancelJobArgs) (*mcp.CallToolResult, any, error) {
+	if args.JobID == "" {
+		return nil, nil, fmt.Errorf("job_id is required")
+	}
+
+	resp, err := s.searchService.CancelJob(args.JobID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cancel job failed: %w", err)
+	}
+
+	result, err := textResult(resp)
+	return result, nil, err
 }
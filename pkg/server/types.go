@@ -1,20 +1,61 @@
 package server
 
+import "github.com/cmrigney/mcp-document-search/internal/search"
+
 // SearchArgs represents arguments for the search tool
 type SearchArgs struct {
 	Query        string   `json:"query" jsonschema:"Search query"`
 	TopK         int      `json:"top_k,omitempty" jsonschema:"Number of results to return (default: 5)"`
 	MinScore     *float64 `json:"min_score,omitempty" jsonschema:"Minimum similarity score 0-1 (default: 0.3)"`
 	SourceFilter string   `json:"source_filter,omitempty" jsonschema:"Filter results to specific source (file path or URL)"`
+	QueryMode    string   `json:"query_mode,omitempty" jsonschema:"Query transformation: 'hyde' (embed a drafted answer) or 'multi' (fuse several rephrasings); empty for none"`
+	NumQueries   int      `json:"num_queries,omitempty" jsonschema:"Number of rephrasings for multi mode (default: 3, max: 10)"`
+	Format       string   `json:"format,omitempty" jsonschema:"Text output: 'json' (default), 'markdown' or 'citations' (numbered passages with titles, locations and scores, trimmed to the configured character budget)"`
+	MaxChars     int      `json:"max_chars,omitempty" jsonschema:"Budget for result content in characters; adjacent chunks are merged and the last result trimmed at a sentence to fit (default: no limit)"`
+	MaxTokens    int      `json:"max_tokens,omitempty" jsonschema:"Budget for result content in tokens (about 4 characters each); the tighter of max_chars and max_tokens applies"`
+}
+
+// SearchBatchArgs represents arguments for the search_batch tool
+type SearchBatchArgs struct {
+	Queries      []string `json:"queries" jsonschema:"Search queries to run in one call"`
+	TopK         int      `json:"top_k,omitempty" jsonschema:"Number of results to return per query (default: 5)"`
+	MinScore     *float64 `json:"min_score,omitempty" jsonschema:"Minimum similarity score 0-1 (default: 0.3)"`
+	SourceFilter string   `json:"source_filter,omitempty" jsonschema:"Filter results to specific source (file path or URL)"`
+	Deduplicate  bool     `json:"deduplicate,omitempty" jsonschema:"Return each chunk only once, under the query it scored highest for (default: false)"`
 }
 
 // IndexArgs represents arguments for the index tool
 type IndexArgs struct {
 	FilePath string `json:"file_path,omitempty" jsonschema:"Path to file to index"`
 	URL      string `json:"url,omitempty" jsonschema:"URL to fetch and index"`
+	Sitemap  string `json:"sitemap,omitempty" jsonschema:"Sitemap URL (sitemap index and .gz supported); every listed page is indexed as its own document"`
 	Content  string `json:"content,omitempty" jsonschema:"Direct content to index (requires source)"`
 	Source   string `json:"source,omitempty" jsonschema:"Source identifier when using content parameter"`
 	Reindex  bool   `json:"reindex,omitempty" jsonschema:"Force re-index if already indexed (default: false)"`
+	Async    bool   `json:"async,omitempty" jsonschema:"Run in the background and return a job id immediately; poll job_status for progress and the result (default: false)"`
+
+	// Scheduled refresh (url only)
+	RefreshIntervalMinutes *int `json:"refresh_interval_minutes,omitempty" jsonschema:"Re-check the url in the background every N minutes and re-index it when it changed (0 disables)"`
+
+	// Crawl options (url only)
+	Crawl           bool     `json:"crawl,omitempty" jsonschema:"Crawl same-origin links from url and index each page as its own document (default: false)"`
+	MaxDepth        *int     `json:"max_depth,omitempty" jsonschema:"Maximum link depth to follow when crawling (default: 2)"`
+	MaxPages        int      `json:"max_pages,omitempty" jsonschema:"Maximum number of pages to fetch when crawling or indexing a sitemap (crawl default: 50, max: 1000)"`
+	IncludePatterns []string `json:"include_patterns,omitempty" jsonschema:"Regular expressions; when crawling, only follow URLs matching one of them"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty" jsonschema:"Regular expressions; when crawling, skip URLs matching any of them"`
+	CrawlDelayMs    *int     `json:"crawl_delay_ms,omitempty" jsonschema:"Minimum delay between crawl requests in milliseconds (default: 500; robots.txt Crawl-delay wins if longer)"`
+
+	// Sitemap options
+	Concurrency int `json:"concurrency,omitempty" jsonschema:"Number of sitemap pages fetched in parallel (default: 4, max: 16)"`
+}
+
+// IndexOutput is the structured result of the index tool. Exactly one field
+// is set, depending on what was indexed.
+type IndexOutput struct {
+	Document *search.IndexResponse   `json:"document,omitempty" jsonschema:"Result of indexing a single file_path, url or content"`
+	Crawl    *search.CrawlResponse   `json:"crawl,omitempty" jsonschema:"Result of crawling a site from url with crawl=true"`
+	Sitemap  *search.SitemapResponse `json:"sitemap,omitempty" jsonschema:"Result of indexing the pages listed in a sitemap"`
+	Job      *search.JobInfo         `json:"job,omitempty" jsonschema:"Background job started with async=true; poll job_status for its result"`
 }
 
 // ListArgs represents arguments for the list tool
@@ -26,3 +67,28 @@ type ListArgs struct {
 type DeleteArgs struct {
 	Source string `json:"source" jsonschema:"Source to delete (file path or URL)"`
 }
+
+// StatsArgs represents arguments for the stats tool
+type StatsArgs struct{}
+
+// BackupArgs represents arguments for the backup tool
+type BackupArgs struct {
+	Name      string `json:"name,omitempty" jsonschema:"File name for the backup inside the configured backup directory (default: a timestamped snapshot; old snapshots are pruned to the retention limit)"`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema:"Replace an existing backup with the same name (default: false)"`
+}
+
+// JobStatusArgs represents arguments for the job_status tool
+type JobStatusArgs struct {
+	JobID string `json:"job_id" jsonschema:"Job id returned by index with async=true"`
+}
+
+// ListJobsArgs represents arguments for the list_jobs tool
+type ListJobsArgs struct {
+	Status string `json:"status,omitempty" jsonschema:"Filter by status: 'queued', 'running', 'completed', 'failed' or 'cancelled' (empty for all)"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"Maximum number of jobs to return, newest first (default: 20)"`
+}
+
+// CancelJobArgs represents arguments for the cancel_job tool
+type CancelJobArgs struct {
+	JobID string `json:"job_id" jsonschema:"Job id to cancel"`
+}